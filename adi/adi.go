@@ -0,0 +1,405 @@
+// Package adi converts videos to and from CableLabs ADI 1.1 packages, the XML
+// format cable and VOD partners use for delivering titles
+package adi
+
+import (
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/metadata"
+	"github.com/SYNQfm/SYNQ-Golang/synq"
+	"github.com/buger/jsonparser"
+)
+
+const (
+	APP           = "MOD"
+	SPEC_VERSION  = "CableLabsVOD1.1"
+	DATE_FORMAT   = "2006-01-02"
+	DOCTYPE       = `<!DOCTYPE ADI SYSTEM "ADI.DTD">`
+	TITLE_BRIEF   = 19
+	TITLE_MAX     = 128
+	SUMMARY_SHORT = 256
+
+	CLASS_PACKAGE = "package"
+	CLASS_TITLE   = "title"
+	CLASS_MOVIE   = "movie"
+	CLASS_PREVIEW = "preview"
+	CLASS_POSTER  = "poster"
+)
+
+// the synq asset types that become each ADI asset class
+var assetClasses = map[string]string{
+	"source":              CLASS_MOVIE,
+	"trailer":             CLASS_PREVIEW,
+	"thumbnail":           CLASS_POSTER,
+	synq.IMAGE_ASSET_TYPE: CLASS_POSTER,
+}
+
+// the default end of licensing windows that never end
+var windowEnd = time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC)
+
+type AMS struct {
+	Provider     string `xml:"Provider,attr"`
+	Product      string `xml:"Product,attr"`
+	AssetName    string `xml:"Asset_Name,attr"`
+	VersionMajor int    `xml:"Version_Major,attr"`
+	VersionMinor int    `xml:"Version_Minor,attr"`
+	Description  string `xml:"Description,attr"`
+	CreationDate string `xml:"Creation_Date,attr"`
+	ProviderId   string `xml:"Provider_ID,attr"`
+	AssetId      string `xml:"Asset_ID,attr"`
+	AssetClass   string `xml:"Asset_Class,attr"`
+}
+
+type AppData struct {
+	App   string `xml:"App,attr"`
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+type Metadata struct {
+	AMS     AMS       `xml:"AMS"`
+	AppData []AppData `xml:"App_Data"`
+}
+
+type Content struct {
+	Value string `xml:"Value,attr"`
+}
+
+type Asset struct {
+	Metadata Metadata `xml:"Metadata"`
+	Assets   []Asset  `xml:"Asset"`
+	Content  *Content `xml:"Content,omitempty"`
+}
+
+// ADI is the root of an ADI 1.1 package
+type ADI struct {
+	XMLName  xml.Name `xml:"ADI"`
+	Metadata Metadata `xml:"Metadata"`
+	Asset    Asset    `xml:"Asset"`
+}
+
+// Get returns the first value of the App_Data called name
+func (m Metadata) Get(name string) string {
+	values := m.All(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// All returns every value of the App_Data called name
+func (m Metadata) All(name string) (values []string) {
+	for _, a := range m.AppData {
+		if a.Name == name {
+			values = append(values, a.Value)
+		}
+	}
+	return values
+}
+
+func (m *Metadata) add(name string, values ...string) {
+	for _, v := range values {
+		if v != "" {
+			m.AppData = append(m.AppData, AppData{App: APP, Name: name, Value: v})
+		}
+	}
+}
+
+// Options configures the package Export creates, zero values use the defaults
+type Options struct {
+	// Provider and ProviderId identify who delivers the package (Provider_ID
+	// is usually a domain name)
+	Provider   string
+	ProviderId string
+	Product    string
+	// AssetIdPrefix is the 4 letters every generated Asset_ID starts with
+	AssetIdPrefix string
+	// AssetId returns the Asset_ID for the asset of class (n counts assets of
+	// the same class), by default it is AssetIdPrefix and 16 digits derived
+	// from the video id
+	AssetId func(video synq.VideoV2, class string, n int) string
+	// Language is the language the title and summaries are in
+	Language string
+	Resolver metadata.Resolver
+	// VersionMajor and VersionMinor are the package version, bump them when
+	// redelivering
+	VersionMajor int
+	VersionMinor int
+	Created      time.Time
+	// BillingId, Category, ProviderQAContact, PreviewPeriod (seconds) and
+	// SuggestedPrice are required by ADI but not part of the video
+	BillingId         string
+	Category          string
+	ProviderQAContact string
+	PreviewPeriod     int
+	SuggestedPrice    string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Provider == "" {
+		o.Provider = "SYNQ"
+	}
+	if o.ProviderId == "" {
+		o.ProviderId = "synq.fm"
+	}
+	if o.Product == "" {
+		o.Product = APP
+	}
+	if len(o.AssetIdPrefix) != 4 {
+		o.AssetIdPrefix = "SYNQ"
+	}
+	if o.Language == "" {
+		o.Language = "en"
+	}
+	if o.Resolver.Fallbacks == nil && o.Resolver.Chains == nil {
+		o.Resolver = metadata.DefaultResolver
+	}
+	if o.VersionMajor == 0 && o.VersionMinor == 0 {
+		o.VersionMajor = 1
+	}
+	if o.Created.IsZero() {
+		o.Created = time.Now()
+	}
+	if o.BillingId == "" {
+		o.BillingId = "00000"
+	}
+	if o.Category == "" {
+		o.Category = "Movies"
+	}
+	if o.PreviewPeriod == 0 {
+		o.PreviewPeriod = 300
+	}
+	if o.SuggestedPrice == "" {
+		o.SuggestedPrice = "0.00"
+	}
+	return o
+}
+
+func (o Options) assetId(video synq.VideoV2, class string, n int) string {
+	if o.AssetId != nil {
+		return o.AssetId(video, class, n)
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%s/%d", video.Id, class, n)
+	return fmt.Sprintf("%s%016d", o.AssetIdPrefix, h.Sum64()%1e16)
+}
+
+func (o Options) ams(video synq.VideoV2, name, class string, n int) AMS {
+	return AMS{
+		Provider:     o.Provider,
+		Product:      o.Product,
+		AssetName:    name,
+		VersionMajor: o.VersionMajor,
+		VersionMinor: o.VersionMinor,
+		Description:  name + " " + class,
+		CreationDate: o.Created.Format(DATE_FORMAT),
+		ProviderId:   o.ProviderId,
+		AssetId:      o.assetId(video, class, n),
+		AssetClass:   class,
+	}
+}
+
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) > max {
+		return string(r[:max])
+	}
+	return s
+}
+
+func runTime(d time.Duration) string {
+	s := int64(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// lastFirst turns "Derek de Lint" into "Lint,Derek de" as ADI lists people
+func lastFirst(name string) string {
+	i := strings.LastIndex(name, " ")
+	if i < 0 {
+		return name
+	}
+	return name[i+1:] + "," + name[:i]
+}
+
+// licensingWindow is the earliest start and latest (inclusive) end of the
+// rights, windows without a start begin when the package is created
+func licensingWindow(rights []metadata.VideoRights, created time.Time) (start, end time.Time, err error) {
+	open := len(rights) == 0
+	for _, r := range rights {
+		w, err := r.Window()
+		if err != nil {
+			return start, end, err
+		}
+		from := w.From
+		if w.Unlimited || from.IsZero() {
+			from = created
+		}
+		if start.IsZero() || from.Before(start) {
+			start = from
+		}
+		if w.Unlimited || w.To.IsZero() {
+			open = true
+		} else if w.To.After(end) {
+			end = w.To
+		}
+	}
+	if start.IsZero() {
+		start = created
+	}
+	if open {
+		return start, windowEnd, nil
+	}
+	// rights windows end just before To
+	return start, end.Add(-time.Nanosecond), nil
+}
+
+// assetSize is the size of the uploaded file, or the "size" in the asset's
+// metadata for assets that were not uploaded with this package
+func assetSize(a synq.Asset) (int64, bool) {
+	if a.UploadInfo.Size > 0 {
+		return a.UploadInfo.Size, true
+	}
+	size, err := jsonparser.GetInt(a.Metadata, "size")
+	return size, err == nil
+}
+
+// assetChecksum is the MD5 of the uploaded file, or the "md5" in the asset's
+// metadata. The upload checksum is only used if it covers the whole file.
+func assetChecksum(a synq.Asset) (string, bool) {
+	info := a.UploadInfo
+	if info.Checksum != "" && info.ChecksumSize == info.Size {
+		return info.Checksum, true
+	}
+	md5, err := jsonparser.GetString(a.Metadata, "md5")
+	return md5, err == nil
+}
+
+// Export creates the ADI package for video and its assets
+func Export(video synq.VideoV2, opts Options) ([]byte, error) {
+	opts = opts.withDefaults()
+	meta, err := video.GetMetaData()
+	if err != nil {
+		return nil, err
+	}
+	rights, err := video.GetRights()
+	if err != nil {
+		return nil, err
+	}
+	title, _ := opts.Resolver.Title(meta, opts.Language)
+	if title.Value == "" {
+		return nil, fmt.Errorf("video %s has no title", video.Id)
+	}
+	name := truncate(title.Value, TITLE_MAX)
+
+	adi := ADI{Metadata: Metadata{AMS: opts.ams(video, name, CLASS_PACKAGE, 0)}}
+	adi.Metadata.add("Provider_Content_Tier", opts.Provider)
+	adi.Metadata.add("Metadata_Spec_Version", SPEC_VERSION)
+
+	t := Metadata{AMS: opts.ams(video, name, CLASS_TITLE, 0)}
+	t.add("Type", CLASS_TITLE)
+	t.add("Title_Brief", truncate(title.Value, TITLE_BRIEF))
+	t.add("Title", name)
+	if meta.Type == metadata.TYPE_EPISODE {
+		t.add("Episode_Name", name)
+		if meta.Series.Episode > 0 {
+			t.add("Episode_ID", fmt.Sprintf("%d", meta.Series.Episode))
+		}
+	}
+	short, _ := opts.Resolver.Description(meta, opts.Language, SUMMARY_SHORT)
+	t.add("Summary_Short", short.Value)
+	if medium, ok := opts.Resolver.Resolve(meta.Description, opts.Language, 0, metadata.DESCRIPTION_MEDIUM); ok {
+		t.add("Summary_Medium", medium.Value)
+	}
+	if long, ok := opts.Resolver.Resolve(meta.Description, opts.Language, 0, metadata.DESCRIPTION_LONG, metadata.CONTENT); ok {
+		t.add("Summary_Long", long.Value)
+	}
+	t.add("Rating", meta.Rating)
+	if meta.Duration != nil && meta.Duration.Valid() {
+		rt := runTime(meta.Duration.Duration())
+		t.add("Run_Time", rt)
+		t.add("Display_Run_Time", rt[:5])
+	}
+	if meta.Year > 0 {
+		t.add("Year", fmt.Sprintf("%d", meta.Year))
+	}
+	t.add("Country_of_Origin", meta.Countries...)
+	for _, c := range meta.Credits {
+		switch strings.ToLower(c.Function) {
+		case "actor":
+			t.add("Actors", lastFirst(c.Name))
+			t.add("Actors_Display", c.Name)
+		case "director":
+			t.add("Director", lastFirst(c.Name))
+		case "producer":
+			t.add("Producers", lastFirst(c.Name))
+		}
+	}
+	t.add("Studio", meta.Studio)
+	t.add("Category", opts.Category)
+	t.add("Genre", meta.Genres...)
+	switch meta.Type {
+	case metadata.TYPE_MOVIE:
+		t.add("Show_Type", "Movie")
+	case metadata.TYPE_EPISODE, metadata.TYPE_SERIES:
+		t.add("Show_Type", "Series")
+	default:
+		t.add("Show_Type", "Other")
+	}
+	t.add("Billing_ID", opts.BillingId)
+	start, end, err := licensingWindow(rights, opts.Created)
+	if err != nil {
+		return nil, err
+	}
+	t.add("Licensing_Window_Start", start.Format(DATE_FORMAT))
+	t.add("Licensing_Window_End", end.Format(DATE_FORMAT))
+	t.add("Preview_Period", fmt.Sprintf("%d", opts.PreviewPeriod))
+	t.add("Provider_QA_Contact", opts.ProviderQAContact)
+	t.add("Suggested_Price", opts.SuggestedPrice)
+	adi.Asset.Metadata = t
+
+	counts := map[string]int{}
+	for _, a := range video.Assets {
+		class, ok := assetClasses[a.Type]
+		location := a.Location
+		if location == "" {
+			location = a.Url
+		}
+		if !ok || location == "" {
+			continue
+		}
+		n := counts[class]
+		counts[class]++
+		m := Metadata{AMS: opts.ams(video, name, class, n)}
+		m.add("Type", class)
+		if size, ok := assetSize(a); ok {
+			m.add("Content_FileSize", fmt.Sprintf("%d", size))
+		}
+		if md5, ok := assetChecksum(a); ok {
+			m.add("Content_CheckSum", md5)
+		}
+		if class == CLASS_POSTER {
+			width, _ := jsonparser.GetInt(a.Metadata, "width")
+			height, _ := jsonparser.GetInt(a.Metadata, "height")
+			if width > 0 && height > 0 {
+				m.add("Image_Aspect_Ratio", fmt.Sprintf("%dx%d", width, height))
+			}
+		} else if height, _ := jsonparser.GetInt(a.Metadata, "height"); height >= 720 {
+			m.add("HDContent", "Y")
+		}
+		content := &Content{Value: path.Base(strings.SplitN(location, "?", 2)[0])}
+		adi.Asset.Assets = append(adi.Asset.Assets, Asset{Metadata: m, Content: content})
+	}
+	if counts[CLASS_MOVIE] == 0 {
+		return nil, fmt.Errorf("video %s has no source asset", video.Id)
+	}
+	data, err := xml.MarshalIndent(adi, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(xml.Header + DOCTYPE + "\n" + string(data) + "\n"), nil
+}
@@ -0,0 +1,246 @@
+package adi
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/metadata"
+	"github.com/SYNQfm/SYNQ-Golang/synq"
+	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/buger/jsonparser"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	DEFAULT_SAMPLE_DIR = "../sample"
+)
+
+var created = time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func loadAkkaVideo(t *testing.T) synq.VideoV2 {
+	f, err := os.Open(DEFAULT_SAMPLE_DIR + "/akka.xml")
+	require.Nil(t, err)
+	defer f.Close()
+	asset, err := metadata.ParseAkkaXML(f)
+	require.Nil(t, err)
+	asset.Credits = append(asset.Credits, metadata.Credit{Name: "Ton Roosendaal", Function: "producer"})
+	asset.Description.Set("eng", metadata.DESCRIPTION_LONG, "Thom just wanted to be an astronaut, and Celia just wanted robots.")
+	meta, _ := json.Marshal(asset)
+	return synq.VideoV2{
+		Id:       "45d4063d00454c9fb21e5186a09c3115",
+		Metadata: meta,
+		Assets: []synq.Asset{
+			{Type: "source", Location: "s3://bucket/tos/tears_of_steel.mp4", Metadata: json.RawMessage(`{"size":1024,"md5":"abc123","height":1080}`)},
+			{Type: "trailer", Url: "https://cdn.example.com/tos_trailer.mp4?sig=1", Metadata: json.RawMessage(`{"height":480}`)},
+			{Type: synq.IMAGE_ASSET_TYPE, Location: "tos_poster_nor.jpg", Metadata: json.RawMessage(`{"width":600,"height":800}`)},
+			{Type: "hls", Location: "s3://bucket/tos/playlist.m3u8"},
+		},
+	}
+}
+
+func TestExport(t *testing.T) {
+	assert := require.New(t)
+	video := loadAkkaVideo(t)
+	data, err := Export(video, Options{Language: "eng", ProviderId: "example.com", Created: created, AssetIdPrefix: "EXMP"})
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(string(data), xml.Header+DOCTYPE))
+
+	var adi ADI
+	assert.Nil(xml.Unmarshal(data, &adi))
+	ams := adi.Metadata.AMS
+	assert.Equal(CLASS_PACKAGE, ams.AssetClass)
+	assert.Equal("example.com", ams.ProviderId)
+	assert.Equal("SYNQ", ams.Provider)
+	assert.Equal("2018-06-01", ams.CreationDate)
+	assert.Equal(1, ams.VersionMajor)
+	assert.Len(ams.AssetId, 20)
+	assert.True(strings.HasPrefix(ams.AssetId, "EXMP"))
+	assert.Equal(SPEC_VERSION, adi.Metadata.Get("Metadata_Spec_Version"))
+
+	title := adi.Asset.Metadata
+	assert.Equal(CLASS_TITLE, title.AMS.AssetClass)
+	assert.NotEqual(ams.AssetId, title.AMS.AssetId)
+	// eng has no title, so the original is used
+	assert.Equal("Tears of Steel", title.Get("Title"))
+	assert.Equal("Tears of Steel", title.Get("Title_Brief"))
+	// the longest description that fits is the short summary
+	assert.Equal("Thom just wanted to be an astronaut, and Celia just wanted robots.", title.Get("Summary_Short"))
+	assert.Equal("Thom just wanted to be an astronaut, and Celia just wanted robots.", title.Get("Summary_Long"))
+	assert.Equal("", title.Get("Summary_Medium"))
+	assert.Equal("00:12:14", title.Get("Run_Time"))
+	assert.Equal("00:12", title.Get("Display_Run_Time"))
+	assert.Equal("2012", title.Get("Year"))
+	assert.Equal([]string{"Lint,Derek de"}, title.All("Actors"))
+	assert.Equal([]string{"Derek de Lint"}, title.All("Actors_Display"))
+	assert.Equal("Hubert,Ian", title.Get("Director"))
+	assert.Equal("Roosendaal,Ton", title.Get("Producers"))
+	assert.Equal([]string{"Sci-Fi", "Short"}, title.All("Genre"))
+	assert.Equal("Movie", title.Get("Show_Type"))
+	assert.Equal("2018-01-01", title.Get("Licensing_Window_Start"))
+	// the unlimited tv rights never end
+	assert.Equal("2099-12-31", title.Get("Licensing_Window_End"))
+
+	assets := adi.Asset.Assets
+	assert.Len(assets, 3)
+	assert.Equal(CLASS_MOVIE, assets[0].Metadata.AMS.AssetClass)
+	assert.Equal("tears_of_steel.mp4", assets[0].Content.Value)
+	assert.Equal("1024", assets[0].Metadata.Get("Content_FileSize"))
+	assert.Equal("abc123", assets[0].Metadata.Get("Content_CheckSum"))
+	assert.Equal("Y", assets[0].Metadata.Get("HDContent"))
+	assert.Equal(CLASS_PREVIEW, assets[1].Metadata.AMS.AssetClass)
+	assert.Equal("tos_trailer.mp4", assets[1].Content.Value)
+	assert.Equal("", assets[1].Metadata.Get("HDContent"))
+	assert.Equal(CLASS_POSTER, assets[2].Metadata.AMS.AssetClass)
+	assert.Equal("600x800", assets[2].Metadata.Get("Image_Aspect_Ratio"))
+
+	// asset ids are stable, and can be set
+	again, _ := Export(video, Options{Language: "eng", ProviderId: "example.com", Created: created, AssetIdPrefix: "EXMP"})
+	assert.Equal(data, again)
+	data, err = Export(video, Options{Created: created, AssetId: func(v synq.VideoV2, class string, n int) string {
+		return class + "-" + v.Id[:4]
+	}})
+	assert.Nil(err)
+	assert.Contains(string(data), `Asset_ID="title-45d4"`)
+}
+
+func TestExportUploadInfo(t *testing.T) {
+	assert := require.New(t)
+	video := loadAkkaVideo(t)
+	movie := func() Metadata {
+		data, err := Export(video, Options{Created: created})
+		assert.Nil(err)
+		var adi ADI
+		assert.Nil(xml.Unmarshal(data, &adi))
+		return adi.Asset.Assets[0].Metadata
+	}
+	// the upload checksum of the whole file is used before the metadata
+	video.Assets[0].UploadInfo = synq.AssetUpload{Checksum: "def456", ChecksumSize: 2048, Size: 2048}
+	assert.Equal("2048", movie().Get("Content_FileSize"))
+	assert.Equal("def456", movie().Get("Content_CheckSum"))
+
+	// a checksum of only part of the file is not
+	video.Assets[0].UploadInfo.ChecksumSize = 1024
+	assert.Equal("2048", movie().Get("Content_FileSize"))
+	assert.Equal("abc123", movie().Get("Content_CheckSum"))
+
+	video.Assets[0].Metadata = json.RawMessage(`{}`)
+	assert.Equal("", movie().Get("Content_CheckSum"))
+}
+
+func TestExportErrors(t *testing.T) {
+	assert := require.New(t)
+	video := loadAkkaVideo(t)
+	video.Assets = video.Assets[1:]
+	_, err := Export(video, Options{})
+	assert.Equal("video 45d4063d00454c9fb21e5186a09c3115 has no source asset", err.Error())
+	video.Metadata = json.RawMessage(`{}`)
+	_, err = Export(video, Options{})
+	assert.Equal("video 45d4063d00454c9fb21e5186a09c3115 has no title", err.Error())
+}
+
+func TestLicensingWindow(t *testing.T) {
+	assert := require.New(t)
+	start, end, err := licensingWindow([]metadata.VideoRights{
+		{ValidFrom: "2018-03-01", ValidTo: "2018-06-30"},
+		{ValidFrom: "2018-05-01", ValidTo: "2018-09-30", Devices: []string{"web"}},
+	}, created)
+	assert.Nil(err)
+	assert.Equal("2018-03-01", start.Format(DATE_FORMAT))
+	assert.Equal("2018-09-30", end.Format(DATE_FORMAT))
+	start, end, err = licensingWindow(nil, created)
+	assert.Nil(err)
+	assert.Equal(created, start)
+	assert.Equal(windowEnd, end)
+	_, _, err = licensingWindow([]metadata.VideoRights{{ValidFrom: "soon"}}, created)
+	assert.NotNil(err)
+}
+
+func TestImport(t *testing.T) {
+	assert := require.New(t)
+	data, err := Export(loadAkkaVideo(t), Options{Language: "eng", ProviderId: "example.com", Created: created})
+	assert.Nil(err)
+	p, err := Import(bytes.NewReader(data), "eng")
+	assert.Nil(err)
+	assert.Equal("example.com", p.ProviderId)
+	assert.Equal(p.AssetId, p.Asset.ContentId)
+	meta := p.Asset.MetaData
+	assert.Equal("Tears of Steel", meta.Title.Get("eng", metadata.CONTENT))
+	assert.Equal("Thom just wanted to be an astronaut, and Celia just wanted robots.", meta.Description.Get("eng", metadata.DESCRIPTION_LONG))
+	assert.Equal(metadata.TYPE_MOVIE, meta.Type)
+	assert.Equal(2012, meta.Year)
+	assert.Equal(734*time.Second, meta.Duration.Duration())
+	assert.Equal([]string{"Sci-Fi", "Short"}, meta.Genres)
+	assert.Equal([]metadata.Credit{
+		{Name: "Derek de Lint", Function: "actor"},
+		{Name: "Ian Hubert", Function: "director"},
+		{Name: "Ton Roosendaal", Function: "producer"},
+	}, meta.Credits)
+	assert.Equal([]metadata.VideoRights{{ValidFrom: "2018-01-01"}}, p.Asset.Rights)
+	assert.Equal([]metadata.ImageData{{Type: CLASS_POSTER, File: "tos_poster_nor.jpg"}}, p.Asset.Images)
+	assert.Len(p.Files, 2)
+	assert.Equal(File{Class: CLASS_MOVIE, AssetId: p.Files[0].AssetId, File: "tears_of_steel.mp4"}, p.Files[0])
+	assert.Equal(CLASS_PREVIEW, p.Files[1].Class)
+
+	// partner files list actors as "Last,First" only and have a closed window
+	p, err = Import(strings.NewReader(`<ADI><Metadata><AMS Asset_ID="PKG1" Asset_Class="package"/></Metadata>
+<Asset><Metadata><AMS Asset_Class="title"/>
+<App_Data App="MOD" Name="Title_Brief" Value="Short"/>
+<App_Data App="MOD" Name="Actors" Value="Lint,Derek de"/>
+<App_Data App="MOD" Name="Show_Type" Value="Series"/>
+<App_Data App="MOD" Name="Episode_ID" Value="3"/>
+<App_Data App="MOD" Name="Licensing_Window_End" Value="2019-01-31"/>
+</Metadata></Asset></ADI>`))
+	assert.Nil(err)
+	meta = p.Asset.MetaData
+	assert.Equal("Short", meta.Title.Get(metadata.ORIGINAL_LANGUAGE, metadata.CONTENT))
+	assert.Equal([]metadata.Credit{{Name: "Derek de Lint", Function: "actor"}}, meta.Credits)
+	assert.Equal(metadata.TYPE_EPISODE, meta.Type)
+	assert.Equal(3, meta.Series.Episode)
+	assert.Equal([]metadata.VideoRights{{ValidTo: "2019-01-31"}}, p.Asset.Rights)
+}
+
+func TestImportErrors(t *testing.T) {
+	assert := require.New(t)
+	_, err := Import(strings.NewReader(`<ADI><Metadata>`))
+	assert.NotNil(err)
+	_, err = Import(strings.NewReader(`<ADI><Asset><Metadata><AMS Asset_Class="movie"/></Metadata></Asset></ADI>`))
+	assert.Equal("ADI package has no title asset", err.Error())
+	_, err = Import(strings.NewReader(`<ADI><Asset><Metadata><AMS Asset_ID="T1" Asset_Class="title"/></Metadata></Asset></ADI>`))
+	assert.Equal("ADI title T1 has no Title", err.Error())
+	_, err = Import(strings.NewReader(`<ADI><Asset><Metadata><AMS Asset_Class="title"/>
+<App_Data App="MOD" Name="Title" Value="T"/><App_Data App="MOD" Name="Year" Value="MMXII"/></Metadata></Asset></ADI>`))
+	assert.Equal("Year 'MMXII' is not a number", err.Error())
+}
+
+func TestCreateVideo(t *testing.T) {
+	assert := require.New(t)
+	data, _ := Export(loadAkkaVideo(t), Options{Created: created})
+	p, err := Import(bytes.NewReader(data))
+	assert.Nil(err)
+
+	api := synq.NewV2(test_server.TEST_AUTH)
+	server := test_server.SetupServer(synq.SYNQ_VERSION, DEFAULT_SAMPLE_DIR)
+	defer server.Close()
+	api.SetUrl(server.GetUrl())
+	video, err := p.CreateVideo(&api)
+	assert.Nil(err)
+	assert.NotEmpty(video.Id)
+	reqs, values := server.GetReqs()
+	// create, update, poster, source and trailer
+	assert.Len(reqs, 5)
+	body := []byte(values[1].Get("body"))
+	title, _ := jsonparser.GetString(body, "metadata", "title", metadata.ORIGINAL_LANGUAGE, metadata.CONTENT)
+	assert.Equal("Tears of Steel", title)
+	types := []string{}
+	for _, v := range values[2:] {
+		a := synq.Asset{}
+		json.Unmarshal([]byte(v.Get("body")), &a)
+		types = append(types, a.Type+":"+a.Location)
+	}
+	assert.Equal([]string{"image:tos_poster_nor.jpg", "source:tears_of_steel.mp4", "trailer:tos_trailer.mp4"}, types)
+}
@@ -0,0 +1,186 @@
+package adi
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/SYNQfm/SYNQ-Golang/metadata"
+	"github.com/SYNQfm/SYNQ-Golang/synq"
+)
+
+// the synq asset types created for each ADI asset class
+var assetTypes = map[string]string{
+	CLASS_MOVIE:   "source",
+	CLASS_PREVIEW: "trailer",
+}
+
+// File is a content file of an imported package
+type File struct {
+	Class   string
+	AssetId string
+	File    string
+}
+
+// Package is an imported ADI package
+type Package struct {
+	ProviderId string
+	AssetId    string
+	// Asset is the title metadata, rights and posters of the package
+	Asset metadata.AkkaXMLAsset
+	// Files are the movie and preview content files
+	Files []File
+}
+
+// firstLast turns "Lint,Derek de" back into "Derek de Lint"
+func firstLast(name string) string {
+	parts := strings.SplitN(name, ",", 2)
+	if len(parts) < 2 {
+		return name
+	}
+	return strings.TrimSpace(parts[1]) + " " + strings.TrimSpace(parts[0])
+}
+
+func class(a Asset) string {
+	if c := a.Metadata.AMS.AssetClass; c != "" {
+		return strings.ToLower(c)
+	}
+	return strings.ToLower(a.Metadata.Get("Type"))
+}
+
+// Import reads an ADI package, the title and summaries are stored as lang
+// (the original language if not set)
+func Import(r io.Reader, lang ...string) (p Package, err error) {
+	l := metadata.ORIGINAL_LANGUAGE
+	if len(lang) > 0 && lang[0] != "" {
+		l = lang[0]
+	}
+	var adi ADI
+	if err = xml.NewDecoder(r).Decode(&adi); err != nil {
+		return p, err
+	}
+	if class(adi.Asset) != CLASS_TITLE {
+		return p, fmt.Errorf("ADI package has no title asset")
+	}
+	p.ProviderId = adi.Metadata.AMS.ProviderId
+	p.AssetId = adi.Metadata.AMS.AssetId
+	t := adi.Asset.Metadata
+	asset := metadata.AkkaXMLAsset{ContentId: p.AssetId}
+	meta := &asset.MetaData
+	meta.Title = metadata.LanguageList{}
+	meta.Description = metadata.LanguageList{}
+
+	title := t.Get("Title")
+	if title == "" {
+		title = t.Get("Title_Brief")
+	}
+	if title == "" {
+		return p, fmt.Errorf("ADI title %s has no Title", t.AMS.AssetId)
+	}
+	meta.Title.Set(l, metadata.CONTENT, title)
+	for name, size := range map[string]string{
+		"Summary_Short":  metadata.DESCRIPTION_SHORT,
+		"Summary_Medium": metadata.DESCRIPTION_MEDIUM,
+		"Summary_Long":   metadata.DESCRIPTION_LONG,
+	} {
+		if value := t.Get(name); value != "" {
+			meta.Description.Set(l, size, value)
+		}
+	}
+	meta.Rating = t.Get("Rating")
+	meta.Studio = t.Get("Studio")
+	meta.Genres = t.All("Genre")
+	meta.Countries = t.All("Country_of_Origin")
+	if year := t.Get("Year"); year != "" {
+		if meta.Year, err = strconv.Atoi(year); err != nil {
+			return p, fmt.Errorf("Year '%s' is not a number", year)
+		}
+	}
+	if rt := t.Get("Run_Time"); rt != "" {
+		d, err := metadata.ParseTimecode(rt, 0)
+		if err != nil {
+			return p, err
+		}
+		meta.Duration = &d
+	}
+	switch strings.ToLower(t.Get("Show_Type")) {
+	case "movie":
+		meta.Type = metadata.TYPE_MOVIE
+	case "series":
+		meta.Type = metadata.TYPE_EPISODE
+	}
+	if episode := t.Get("Episode_ID"); episode != "" {
+		meta.Series.Episode, _ = strconv.Atoi(episode)
+	}
+
+	// prefer the display names, ADI lists actors as "Last,First"
+	actors := t.All("Actors_Display")
+	if len(actors) == 0 {
+		for _, a := range t.All("Actors") {
+			actors = append(actors, firstLast(a))
+		}
+	}
+	for _, a := range actors {
+		meta.Credits = append(meta.Credits, metadata.Credit{Name: a, Function: "actor"})
+	}
+	for _, d := range t.All("Director") {
+		meta.Credits = append(meta.Credits, metadata.Credit{Name: firstLast(d), Function: "director"})
+	}
+	for _, d := range t.All("Producers") {
+		meta.Credits = append(meta.Credits, metadata.Credit{Name: firstLast(d), Function: "producer"})
+	}
+
+	start, end := t.Get("Licensing_Window_Start"), t.Get("Licensing_Window_End")
+	if end == windowEnd.Format(DATE_FORMAT) {
+		end = ""
+	}
+	if start != "" || end != "" {
+		asset.Rights = []metadata.VideoRights{{ValidFrom: start, ValidTo: end}}
+		if _, err = asset.Rights[0].Window(); err != nil {
+			return p, err
+		}
+	}
+
+	for _, a := range adi.Asset.Assets {
+		if a.Content == nil || a.Content.Value == "" {
+			continue
+		}
+		c := class(a)
+		if c == CLASS_POSTER || c == "box cover" {
+			asset.Images = append(asset.Images, metadata.ImageData{Type: CLASS_POSTER, File: a.Content.Value})
+			continue
+		}
+		p.Files = append(p.Files, File{Class: c, AssetId: a.Metadata.AMS.AssetId, File: a.Content.Value})
+	}
+	p.Asset = asset
+	return p, nil
+}
+
+// CreateVideo creates a video with the package's metadata, rights and
+// posters, and a 'created' asset for every movie and preview file
+func (p Package) CreateVideo(api *synq.ApiV2) (synq.VideoV2, error) {
+	return p.CreateVideoCtx(context.Background(), api)
+}
+
+func (p Package) CreateVideoCtx(ctx context.Context, api *synq.ApiV2) (synq.VideoV2, error) {
+	video, err := api.CreateCtx(ctx)
+	if err != nil {
+		return video, err
+	}
+	if err = video.ApplyAkkaXMLCtx(ctx, p.Asset); err != nil {
+		return video, err
+	}
+	for _, f := range p.Files {
+		fileType, ok := assetTypes[f.Class]
+		if !ok {
+			continue
+		}
+		if _, err = video.CreateAssetCtx(ctx, "created", fileType, f.File); err != nil {
+			return video, err
+		}
+	}
+	return video, nil
+}
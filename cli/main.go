@@ -39,6 +39,15 @@ func handleError(err error) {
 	}
 }
 
+// printProgress keeps a live progress line on stderr
+func printProgress(p upload.Progress) {
+	// pad to clear what is left of a longer line
+	fmt.Fprintf(os.Stderr, "\r%-80s", p.String())
+	if p.Done {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
 func handleV2(api synq.ApiV2) {
 	vid := cli.GetString("video_id")
 	aid := cli.GetString("asset_id")
@@ -116,6 +125,7 @@ func handleV2(api synq.ApiV2) {
 
 		cli.Printf("uploading file %s\n", file)
 		if !cli.Simulate {
+			asset.Progress = printProgress
 			err = asset.UploadFile(file)
 			handleError(err)
 			log.Printf("uploaded file %s\n", file)
@@ -156,7 +166,7 @@ func handleV2(api synq.ApiV2) {
 		video.CompletenessScore = 10.1
 		err := video.Update()
 		if err != nil {
-			log.Printf("Got error %s", err.Error)
+			log.Printf("Got error %s", err.Error())
 		} else {
 			log.Printf("Got video score %.1f\n", video.CompletenessScore)
 		}
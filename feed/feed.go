@@ -0,0 +1,264 @@
+// Package feed creates Media RSS feeds and Google video sitemaps from the
+// videos in a SYNQ catalog
+package feed
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/metadata"
+	"github.com/SYNQfm/SYNQ-Golang/synq"
+	"github.com/buger/jsonparser"
+)
+
+const (
+	// SITEMAP_MAX_URLS is the most urls Google accepts in one sitemap (and
+	// sitemaps in one index)
+	SITEMAP_MAX_URLS = 50000
+	// SITEMAP_DESCRIPTION is the longest description Google accepts
+	SITEMAP_DESCRIPTION = 2048
+	// DATE_FORMAT is the W3C date time format sitemaps use
+	DATE_FORMAT = time.RFC3339
+)
+
+var (
+	// DEFAULT_CONTENT_TYPES are the asset types that can be played, in the
+	// order they are preferred
+	DEFAULT_CONTENT_TYPES = []string{"mp4", "hls"}
+	// DEFAULT_THUMBNAIL_TYPES are the asset types used as thumbnails
+	DEFAULT_THUMBNAIL_TYPES = []string{"thumbnail", synq.IMAGE_ASSET_TYPE}
+	// mime types by file extension
+	mimeTypes = map[string]string{
+		".mp4":  "video/mp4",
+		".m4v":  "video/mp4",
+		".mov":  "video/quicktime",
+		".webm": "video/webm",
+		".m3u8": "application/x-mpegURL",
+		".mpd":  "application/dash+xml",
+		".jpg":  "image/jpeg",
+		".jpeg": "image/jpeg",
+		".png":  "image/png",
+	}
+)
+
+// Source streams the videos a feed is made of, *synq.VideoIterator is one
+type Source interface {
+	Next() bool
+	Video() synq.VideoV2
+	Err() error
+}
+
+type sliceSource struct {
+	videos []synq.VideoV2
+	n      int
+}
+
+// Videos returns a Source for a list of videos
+func Videos(videos ...synq.VideoV2) Source {
+	return &sliceSource{videos: videos}
+}
+
+func (s *sliceSource) Next() bool {
+	if s.n >= len(s.videos) {
+		return false
+	}
+	s.n++
+	return true
+}
+
+func (s *sliceSource) Video() synq.VideoV2 {
+	return s.videos[s.n-1]
+}
+
+func (s *sliceSource) Err() error {
+	return nil
+}
+
+// Options configures a feed, zero values use the defaults
+type Options struct {
+	// Title, Link and Description describe the MRSS channel
+	Title       string
+	Link        string
+	Description string
+	// PageUrl returns the url of the page that plays video, it is required
+	PageUrl func(video synq.VideoV2) string
+	// PlayerUrl returns the url of an embeddable player for video, if set
+	PlayerUrl func(video synq.VideoV2) string
+	// Language is the language of titles and descriptions
+	Language string
+	Resolver metadata.Resolver
+	// Rights decides which videos are available for Device at Now, and when
+	// they expire
+	Rights metadata.RightsEngine
+	Device string
+	Now    time.Time
+	// ContentTypes and ThumbnailTypes are the asset types used for the media
+	// and thumbnails, DEFAULT_CONTENT_TYPES and DEFAULT_THUMBNAIL_TYPES if nil
+	ContentTypes   []string
+	ThumbnailTypes []string
+	// SitemapSize is the most urls in a sitemap, SITEMAP_MAX_URLS if 0
+	SitemapSize int
+	// OnError is called with the videos left out of a feed because their
+	// metadata or rights can not be read, they are logged if it is nil
+	OnError func(video synq.VideoV2, err error)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Language == "" {
+		o.Language = "en"
+	}
+	if o.Resolver.Fallbacks == nil && o.Resolver.Chains == nil {
+		o.Resolver = metadata.DefaultResolver
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	if o.ContentTypes == nil {
+		o.ContentTypes = DEFAULT_CONTENT_TYPES
+	}
+	if o.ThumbnailTypes == nil {
+		o.ThumbnailTypes = DEFAULT_THUMBNAIL_TYPES
+	}
+	if o.SitemapSize <= 0 || o.SitemapSize > SITEMAP_MAX_URLS {
+		o.SitemapSize = SITEMAP_MAX_URLS
+	}
+	return o
+}
+
+// Media is a playable file or an image of a video
+type Media struct {
+	Url      string
+	Type     string
+	FileSize int64
+	Width    int64
+	Height   int64
+	Duration time.Duration
+}
+
+// Entry is a video as it appears in a feed
+type Entry struct {
+	Id          string
+	Title       string
+	Description string
+	PageUrl     string
+	PlayerUrl   string
+	Content     Media
+	Thumbnail   Media
+	Genres      []string
+	Rating      string
+	Published   time.Time
+	// Expires is when the rights for the video end, zero if they do not
+	Expires time.Time
+}
+
+// mimeType returns the mime type for the file in url
+func mimeType(url string) string {
+	file := strings.SplitN(url, "?", 2)[0]
+	return mimeTypes[strings.ToLower(path.Ext(file))]
+}
+
+// findMedia returns the first public asset of the first type in types
+func findMedia(video synq.VideoV2, types []string) (Media, bool) {
+	for _, t := range types {
+		for _, a := range video.Assets {
+			url := a.GetUrl()
+			if a.Type != t || !strings.HasPrefix(url, "http") {
+				continue
+			}
+			m := Media{Url: url, Type: mimeType(url)}
+			m.FileSize, _ = jsonparser.GetInt(a.Metadata, "size")
+			m.Width, _ = jsonparser.GetInt(a.Metadata, "width")
+			m.Height, _ = jsonparser.GetInt(a.Metadata, "height")
+			if d, err := jsonparser.GetFloat(a.Metadata, "duration"); err == nil {
+				m.Duration = time.Duration(d * float64(time.Second))
+			}
+			return m, true
+		}
+	}
+	return Media{}, false
+}
+
+// NewEntry returns the feed entry for video. It returns false if the video
+// can not be in a feed: it has no title, no public content or thumbnail, or
+// its rights do not allow it to be played now.
+func NewEntry(video synq.VideoV2, opts Options) (e Entry, ok bool, err error) {
+	opts = opts.withDefaults()
+	if opts.PageUrl == nil {
+		return e, false, fmt.Errorf("feed options have no PageUrl")
+	}
+	meta, err := video.GetMetaData()
+	if err != nil {
+		return e, false, fmt.Errorf("video %s : %s", video.Id, err.Error())
+	}
+	rights, err := video.GetRights()
+	if err != nil {
+		return e, false, fmt.Errorf("video %s : %s", video.Id, err.Error())
+	}
+	if len(rights) > 0 {
+		playable, err := opts.Rights.Playable(rights, opts.Device, opts.Now)
+		if err != nil {
+			return e, false, fmt.Errorf("video %s : %s", video.Id, err.Error())
+		}
+		if !playable {
+			return e, false, nil
+		}
+		e.Expires, _, _ = opts.Rights.Expires(rights, opts.Device, opts.Now)
+	}
+	title, ok := opts.Resolver.Title(meta, opts.Language)
+	if !ok {
+		return e, false, nil
+	}
+	if e.Content, ok = findMedia(video, opts.ContentTypes); !ok {
+		return e, false, nil
+	}
+	if e.Thumbnail, ok = findMedia(video, opts.ThumbnailTypes); !ok {
+		return e, false, nil
+	}
+	e.Id = video.Id
+	e.Title = title.Value
+	e.Description = title.Value
+	if desc, ok := opts.Resolver.Description(meta, opts.Language, SITEMAP_DESCRIPTION); ok {
+		e.Description = desc.Value
+	}
+	e.PageUrl = opts.PageUrl(video)
+	if opts.PlayerUrl != nil {
+		e.PlayerUrl = opts.PlayerUrl(video)
+	}
+	if meta.Duration != nil && meta.Duration.Valid() {
+		e.Content.Duration = meta.Duration.Duration()
+	}
+	e.Genres = meta.Genres
+	e.Rating = meta.Rating
+	e.Published = video.CreatedAt
+	return e, true, nil
+}
+
+// entries calls fn with the entry of every video in src that can be in a
+// feed, videos that can not be read are passed to opts.OnError and skipped
+func entries(src Source, opts Options, fn func(e Entry) error) error {
+	if opts.PageUrl == nil {
+		return fmt.Errorf("feed options have no PageUrl")
+	}
+	for src.Next() {
+		video := src.Video()
+		e, ok, err := NewEntry(video, opts)
+		if err != nil {
+			if opts.OnError != nil {
+				opts.OnError(video, err)
+			} else {
+				log.Printf("skipping %s\n", err.Error())
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+	return src.Err()
+}
@@ -0,0 +1,253 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/synq"
+	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/stretchr/testify/require"
+)
+
+// a *synq.VideoIterator can be used as a Source
+var _ Source = &synq.VideoIterator{}
+
+var now = time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func testOptions() Options {
+	return Options{
+		Title:   "Catalog",
+		Link:    "https://example.com",
+		Now:     now,
+		PageUrl: func(v synq.VideoV2) string { return "https://example.com/watch/" + v.Id },
+	}
+}
+
+func testVideo(id, meta string) synq.VideoV2 {
+	return synq.VideoV2{
+		Id:        id,
+		CreatedAt: time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		Metadata:  json.RawMessage(meta),
+		Assets: []synq.Asset{
+			{Type: "source", Location: "s3://bucket/" + id + ".mov"},
+			{Type: "hls", Url: "https://cdn.example.com/" + id + "/playlist.m3u8"},
+			{Type: "mp4", Location: "https://cdn.example.com/" + id + ".mp4", Metadata: json.RawMessage(`{"size":2048,"width":1280,"height":720,"duration":61.4}`)},
+			{Type: "thumbnail", Url: "https://cdn.example.com/" + id + ".jpg", Metadata: json.RawMessage(`{"width":640,"height":360}`)},
+		},
+	}
+}
+
+const testMeta = `{"title":{"original":{"content":"Tears of Steel"},"nor":{"content":"Tårer av stål"}},
+"description":{"nor":{"content-short":"Thom ville bare bli astronaut."}},
+"genres":["Sci-Fi","Short"],"parental_rating":"10",
+"rights":[{"valid_from":"2018-01-01","valid_to":"2018-06-30"}]}`
+
+func TestNewEntry(t *testing.T) {
+	assert := require.New(t)
+	opts := testOptions()
+	opts.Language = "nb"
+	e, ok, err := NewEntry(testVideo("v1", testMeta), opts)
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("v1", e.Id)
+	assert.Equal("Tårer av stål", e.Title)
+	assert.Equal("Thom ville bare bli astronaut.", e.Description)
+	assert.Equal("https://example.com/watch/v1", e.PageUrl)
+	assert.Equal(Media{Url: "https://cdn.example.com/v1.mp4", Type: "video/mp4", FileSize: 2048, Width: 1280, Height: 720, Duration: 61400 * time.Millisecond}, e.Content)
+	assert.Equal(Media{Url: "https://cdn.example.com/v1.jpg", Type: "image/jpeg", Width: 640, Height: 360}, e.Thumbnail)
+	assert.Equal(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC), e.Expires)
+
+	// the title is used when there is no description, and hls if it is preferred
+	opts.Language = "en"
+	opts.ContentTypes = []string{"hls", "mp4"}
+	e, ok, err = NewEntry(testVideo("v1", `{"title":{"original":{"content":"Tears of Steel"}},"expected_duration":"00:12:14"}`), opts)
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("Tears of Steel", e.Description)
+	assert.Equal("application/x-mpegURL", e.Content.Type)
+	assert.Equal(734*time.Second, e.Content.Duration)
+	assert.True(e.Expires.IsZero())
+}
+
+func TestNewEntrySkipped(t *testing.T) {
+	assert := require.New(t)
+	opts := testOptions()
+	// expired
+	opts.Now = time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	_, ok, err := NewEntry(testVideo("v1", testMeta), opts)
+	assert.Nil(err)
+	assert.False(ok)
+	// no title
+	_, ok, err = NewEntry(testVideo("v1", `{}`), testOptions())
+	assert.Nil(err)
+	assert.False(ok)
+	// no public content
+	video := testVideo("v1", testMeta)
+	video.Assets = video.Assets[:1]
+	_, ok, err = NewEntry(video, testOptions())
+	assert.Nil(err)
+	assert.False(ok)
+
+	_, _, err = NewEntry(testVideo("v1", `{"rights":[{"valid_from":"soon"}]}`), testOptions())
+	assert.Equal("video v1 : rights time 'soon' is not a date or RFC 3339 time", err.Error())
+	_, _, err = NewEntry(testVideo("v1", testMeta), Options{})
+	assert.Equal("feed options have no PageUrl", err.Error())
+}
+
+func TestWriteMRSS(t *testing.T) {
+	assert := require.New(t)
+	opts := testOptions()
+	opts.PlayerUrl = func(v synq.VideoV2) string { return "https://example.com/embed/" + v.Id }
+	var buf bytes.Buffer
+	err := WriteMRSS(&buf, Videos(testVideo("v1", testMeta), testVideo("v2", `{}`)), opts)
+	assert.Nil(err)
+	out := buf.String()
+	assert.True(strings.HasPrefix(out, xml.Header+`<rss version="2.0" xmlns:media="`+MRSS_NAMESPACE+`"`))
+	assert.Contains(out, "<title>Catalog</title>")
+	assert.Contains(out, "<language>en</language>")
+	assert.Equal(1, strings.Count(out, "<item>"))
+	assert.Contains(out, `<guid isPermaLink="false">v1</guid>`)
+	assert.Contains(out, "<pubDate>Tue, 02 Jan 2018 03:04:05 +0000</pubDate>")
+	assert.Contains(out, `<media:content url="https://cdn.example.com/v1.mp4" type="video/mp4" medium="video" fileSize="2048" duration="61" width="1280" height="720"></media:content>`)
+	assert.Contains(out, `<media:thumbnail url="https://cdn.example.com/v1.jpg" width="640" height="360"></media:thumbnail>`)
+	assert.Contains(out, `<media:player url="https://example.com/embed/v1"></media:player>`)
+	assert.Contains(out, "<media:keywords>Sci-Fi, Short</media:keywords>")
+	assert.Contains(out, "<dcterms:valid>end=2018-07-01T00:00:00Z; scheme=W3C-DTF</dcterms:valid>")
+	assert.True(strings.HasSuffix(out, "</channel>\n</rss>\n"))
+}
+
+func TestWriteMRSSSkipsBadVideos(t *testing.T) {
+	assert := require.New(t)
+	opts := testOptions()
+	failed := map[string]string{}
+	opts.OnError = func(v synq.VideoV2, err error) {
+		failed[v.Id] = err.Error()
+	}
+	var buf bytes.Buffer
+	videos := Videos(
+		testVideo("v1", `{"title":`),
+		testVideo("v2", `{"rights":[{"valid_from":"soon"}]}`),
+		testVideo("v3", testMeta),
+	)
+	assert.Nil(WriteMRSS(&buf, videos, opts))
+	assert.Equal(1, strings.Count(buf.String(), "<item>"))
+	assert.Contains(buf.String(), `<guid isPermaLink="false">v3</guid>`)
+	assert.Len(failed, 2)
+	assert.Equal("video v2 : rights time 'soon' is not a date or RFC 3339 time", failed["v2"])
+
+	// a missing PageUrl still fails the feed
+	opts.PageUrl = nil
+	assert.NotNil(WriteMRSS(&buf, Videos(testVideo("v3", testMeta)), opts))
+}
+
+type errSource struct {
+	Source
+}
+
+func (errSource) Err() error {
+	return errors.New("page failed")
+}
+
+func TestWriteSitemap(t *testing.T) {
+	assert := require.New(t)
+	var buf bytes.Buffer
+	err := WriteSitemap(&buf, Videos(testVideo("v1", testMeta)), testOptions())
+	assert.Nil(err)
+	out := buf.String()
+	assert.Contains(out, `<urlset xmlns="`+SITEMAP_NAMESPACE+`" xmlns:video="`+VIDEO_NAMESPACE+`">`)
+	assert.Contains(out, "<loc>https://example.com/watch/v1</loc>")
+	assert.Contains(out, "<video:thumbnail_loc>https://cdn.example.com/v1.jpg</video:thumbnail_loc>")
+	assert.Contains(out, "<video:title>Tears of Steel</video:title>")
+	assert.Contains(out, "<video:content_loc>https://cdn.example.com/v1.mp4</video:content_loc>")
+	assert.Contains(out, "<video:duration>61</video:duration>")
+	assert.Contains(out, "<video:expiration_date>2018-07-01T00:00:00Z</video:expiration_date>")
+	assert.Contains(out, "<video:publication_date>2018-01-02T03:04:05Z</video:publication_date>")
+	assert.Contains(out, "<video:tag>Sci-Fi</video:tag>")
+	assert.NotContains(out, "player_loc")
+
+	err = WriteSitemap(&buf, errSource{Videos()}, testOptions())
+	assert.Equal("page failed", err.Error())
+}
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeBuffer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWriteSitemaps(t *testing.T) {
+	assert := require.New(t)
+	videos := []synq.VideoV2{}
+	for i := 0; i < 5; i++ {
+		videos = append(videos, testVideo(fmt.Sprintf("v%d", i), testMeta))
+	}
+	// expired videos are not counted
+	videos = append(videos[:2], append([]synq.VideoV2{testVideo("old", `{"rights":[{"valid_to":"2017-01-01"}]}`)}, videos[2:]...)...)
+	opts := testOptions()
+	opts.SitemapSize = 2
+	files := []*closeBuffer{}
+	n, err := WriteSitemaps(Videos(videos...), opts, func(n int) (io.WriteCloser, error) {
+		assert.Equal(len(files), n)
+		files = append(files, &closeBuffer{})
+		return files[n], nil
+	})
+	assert.Nil(err)
+	assert.Equal(3, n)
+	for i, f := range files {
+		assert.True(f.closed)
+		assert.True(strings.HasSuffix(f.String(), "</urlset>\n"))
+		if i < 2 {
+			assert.Equal(2, strings.Count(f.String(), "<url>"))
+		}
+	}
+	assert.Contains(files[2].String(), "v4</loc>")
+
+	n, err = WriteSitemaps(Videos(), opts, nil)
+	assert.Nil(err)
+	assert.Equal(0, n)
+	_, err = WriteSitemaps(Videos(videos...), opts, func(n int) (io.WriteCloser, error) {
+		return nil, errors.New("disk full")
+	})
+	assert.Equal("disk full", err.Error())
+}
+
+func TestWriteSitemapIndex(t *testing.T) {
+	assert := require.New(t)
+	var buf bytes.Buffer
+	err := WriteSitemapIndex(&buf, []string{"https://example.com/sitemap-0.xml", "https://example.com/sitemap-1.xml"}, now)
+	assert.Nil(err)
+	out := buf.String()
+	assert.Contains(out, `<sitemapindex xmlns="`+SITEMAP_NAMESPACE+`">`)
+	assert.Equal(2, strings.Count(out, "<sitemap>"))
+	assert.Contains(out, "<loc>https://example.com/sitemap-1.xml</loc>\n    <lastmod>2018-06-01T00:00:00Z</lastmod>")
+	err = WriteSitemapIndex(&buf, make([]string, SITEMAP_MAX_URLS+1), now)
+	assert.NotNil(err)
+}
+
+func TestFeedFromApi(t *testing.T) {
+	assert := require.New(t)
+	api := synq.NewV2(test_server.TEST_AUTH)
+	server := test_server.SetupServer(synq.SYNQ_VERSION, "../sample")
+	defer server.Close()
+	api.SetUrl(server.GetUrl())
+	it := api.Videos("")
+	defer it.Close()
+	var buf bytes.Buffer
+	// the sample videos have no titles
+	assert.Nil(WriteMRSS(&buf, it, testOptions()))
+	assert.NotContains(buf.String(), "<item>")
+	reqs, _ := server.GetReqs()
+	// the first page is short, so it is the last one
+	assert.Len(reqs, 1)
+}
@@ -0,0 +1,138 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	MRSS_NAMESPACE    = "http://search.yahoo.com/mrss/"
+	DCTERMS_NAMESPACE = "http://purl.org/dc/terms/"
+)
+
+type mrssContent struct {
+	Url      string `xml:"url,attr"`
+	Type     string `xml:"type,attr,omitempty"`
+	Medium   string `xml:"medium,attr"`
+	FileSize int64  `xml:"fileSize,attr,omitempty"`
+	Duration int64  `xml:"duration,attr,omitempty"`
+	Width    int64  `xml:"width,attr,omitempty"`
+	Height   int64  `xml:"height,attr,omitempty"`
+}
+
+type mrssThumbnail struct {
+	Url    string `xml:"url,attr"`
+	Width  int64  `xml:"width,attr,omitempty"`
+	Height int64  `xml:"height,attr,omitempty"`
+}
+
+type mrssPlayer struct {
+	Url string `xml:"url,attr"`
+}
+
+type mrssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type mrssItem struct {
+	XMLName     xml.Name      `xml:"item"`
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	Guid        mrssGuid      `xml:"guid"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Content     mrssContent   `xml:"media:content"`
+	Thumbnail   mrssThumbnail `xml:"media:thumbnail"`
+	Player      *mrssPlayer   `xml:"media:player,omitempty"`
+	Keywords    string        `xml:"media:keywords,omitempty"`
+	Rating      string        `xml:"media:rating,omitempty"`
+	Valid       string        `xml:"dcterms:valid,omitempty"`
+}
+
+func newMRSSItem(e Entry) mrssItem {
+	item := mrssItem{
+		Title:       e.Title,
+		Link:        e.PageUrl,
+		Description: e.Description,
+		Guid:        mrssGuid{Value: e.Id},
+		Content: mrssContent{
+			Url:      e.Content.Url,
+			Type:     e.Content.Type,
+			Medium:   "video",
+			FileSize: e.Content.FileSize,
+			Duration: int64(e.Content.Duration.Round(time.Second) / time.Second),
+			Width:    e.Content.Width,
+			Height:   e.Content.Height,
+		},
+		Thumbnail: mrssThumbnail{Url: e.Thumbnail.Url, Width: e.Thumbnail.Width, Height: e.Thumbnail.Height},
+		Rating:    e.Rating,
+	}
+	if !e.Published.IsZero() {
+		item.PubDate = e.Published.UTC().Format(time.RFC1123Z)
+	}
+	if e.PlayerUrl != "" {
+		item.Player = &mrssPlayer{Url: e.PlayerUrl}
+	}
+	for i, g := range e.Genres {
+		if i > 0 {
+			item.Keywords += ", "
+		}
+		item.Keywords += g
+	}
+	if !e.Expires.IsZero() {
+		item.Valid = fmt.Sprintf("end=%s; scheme=W3C-DTF", e.Expires.UTC().Format(DATE_FORMAT))
+	}
+	return item
+}
+
+func start(name string, attrs ...string) xml.StartElement {
+	s := xml.StartElement{Name: xml.Name{Local: name}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.Attr = append(s.Attr, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	return s
+}
+
+// WriteMRSS writes a Media RSS feed with an item for every video in src that
+// can be in a feed (see NewEntry), the videos are streamed so src can be a
+// whole catalog
+func WriteMRSS(w io.Writer, src Source, opts Options) error {
+	opts = opts.withDefaults()
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	rss := start("rss", "version", "2.0", "xmlns:media", MRSS_NAMESPACE, "xmlns:dcterms", DCTERMS_NAMESPACE)
+	channel := start("channel")
+	if err := enc.EncodeToken(rss); err != nil {
+		return err
+	}
+	enc.EncodeToken(channel)
+	for _, field := range []struct{ name, value string }{
+		{"title", opts.Title},
+		{"link", opts.Link},
+		{"description", opts.Description},
+		{"language", opts.Language},
+	} {
+		if err := enc.EncodeElement(field.value, start(field.name)); err != nil {
+			return err
+		}
+	}
+	err := entries(src, opts, func(e Entry) error {
+		return enc.Encode(newMRSSItem(e))
+	})
+	if err != nil {
+		return err
+	}
+	enc.EncodeToken(channel.End())
+	enc.EncodeToken(rss.End())
+	if err = enc.Flush(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,179 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	SITEMAP_NAMESPACE = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	VIDEO_NAMESPACE   = "http://www.google.com/schemas/sitemap-video/1.1"
+)
+
+type sitemapVideo struct {
+	ThumbnailLoc    string   `xml:"video:thumbnail_loc"`
+	Title           string   `xml:"video:title"`
+	Description     string   `xml:"video:description"`
+	ContentLoc      string   `xml:"video:content_loc"`
+	PlayerLoc       string   `xml:"video:player_loc,omitempty"`
+	Duration        int64    `xml:"video:duration,omitempty"`
+	ExpirationDate  string   `xml:"video:expiration_date,omitempty"`
+	PublicationDate string   `xml:"video:publication_date,omitempty"`
+	Tags            []string `xml:"video:tag,omitempty"`
+}
+
+type sitemapUrl struct {
+	XMLName xml.Name     `xml:"url"`
+	Loc     string       `xml:"loc"`
+	Video   sitemapVideo `xml:"video:video"`
+}
+
+type sitemapLoc struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod,omitempty"`
+}
+
+func newSitemapUrl(e Entry) sitemapUrl {
+	u := sitemapUrl{
+		Loc: e.PageUrl,
+		Video: sitemapVideo{
+			ThumbnailLoc: e.Thumbnail.Url,
+			Title:        e.Title,
+			Description:  e.Description,
+			ContentLoc:   e.Content.Url,
+			PlayerLoc:    e.PlayerUrl,
+			Duration:     int64(e.Content.Duration.Round(time.Second) / time.Second),
+			Tags:         e.Genres,
+		},
+	}
+	if !e.Expires.IsZero() {
+		u.Video.ExpirationDate = e.Expires.UTC().Format(DATE_FORMAT)
+	}
+	if !e.Published.IsZero() {
+		u.Video.PublicationDate = e.Published.UTC().Format(DATE_FORMAT)
+	}
+	return u
+}
+
+// sitemapWriter writes one sitemap file
+type sitemapWriter struct {
+	w     io.Writer
+	enc   *xml.Encoder
+	root  xml.StartElement
+	count int
+}
+
+func newSitemapWriter(w io.Writer, root xml.StartElement) (*sitemapWriter, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+	s := &sitemapWriter{w: w, enc: xml.NewEncoder(w), root: root}
+	s.enc.Indent("", "  ")
+	return s, s.enc.EncodeToken(root)
+}
+
+func (s *sitemapWriter) write(v interface{}) error {
+	s.count++
+	return s.enc.Encode(v)
+}
+
+func (s *sitemapWriter) close() error {
+	s.enc.EncodeToken(s.root.End())
+	if err := s.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(s.w, "\n")
+	return err
+}
+
+func urlset() xml.StartElement {
+	return start("urlset", "xmlns", SITEMAP_NAMESPACE, "xmlns:video", VIDEO_NAMESPACE)
+}
+
+// WriteSitemap writes a video sitemap with every video in src that can be in
+// a feed (see NewEntry). It does not split the sitemap, use WriteSitemaps for
+// catalogs that can have more than SITEMAP_MAX_URLS videos.
+func WriteSitemap(w io.Writer, src Source, opts Options) error {
+	s, err := newSitemapWriter(w, urlset())
+	if err != nil {
+		return err
+	}
+	err = entries(src, opts.withDefaults(), func(e Entry) error {
+		return s.write(newSitemapUrl(e))
+	})
+	if err != nil {
+		return err
+	}
+	return s.close()
+}
+
+// WriteSitemaps writes the video sitemap for src split into files of at most
+// opts.SitemapSize urls, create returns where to write the n'th (0 based)
+// file. It returns how many files were written, list them with
+// WriteSitemapIndex.
+func WriteSitemaps(src Source, opts Options, create func(n int) (io.WriteCloser, error)) (n int, err error) {
+	opts = opts.withDefaults()
+	var (
+		file io.WriteCloser
+		s    *sitemapWriter
+	)
+	closeFile := func() error {
+		err := s.close()
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		s = nil
+		return err
+	}
+	err = entries(src, opts, func(e Entry) error {
+		if s != nil && s.count >= opts.SitemapSize {
+			if err := closeFile(); err != nil {
+				return err
+			}
+		}
+		if s == nil {
+			var err error
+			if file, err = create(n); err != nil {
+				return err
+			}
+			n++
+			if s, err = newSitemapWriter(file, urlset()); err != nil {
+				file.Close()
+				s = nil
+				return err
+			}
+		}
+		return s.write(newSitemapUrl(e))
+	})
+	if s != nil {
+		if cerr := closeFile(); err == nil {
+			err = cerr
+		}
+	}
+	return n, err
+}
+
+// WriteSitemapIndex writes a sitemap index listing the sitemaps at locs,
+// lastMod is left out if it is zero
+func WriteSitemapIndex(w io.Writer, locs []string, lastMod time.Time) error {
+	if len(locs) > SITEMAP_MAX_URLS {
+		return fmt.Errorf("a sitemap index can list at most %d sitemaps, not %d", SITEMAP_MAX_URLS, len(locs))
+	}
+	s, err := newSitemapWriter(w, start("sitemapindex", "xmlns", SITEMAP_NAMESPACE))
+	if err != nil {
+		return err
+	}
+	for _, loc := range locs {
+		l := sitemapLoc{Loc: loc}
+		if !lastMod.IsZero() {
+			l.LastMod = lastMod.UTC().Format(DATE_FORMAT)
+		}
+		if err = s.write(l); err != nil {
+			return err
+		}
+	}
+	return s.close()
+}
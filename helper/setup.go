@@ -11,11 +11,13 @@ import (
 
 	"github.com/SYNQfm/SYNQ-Golang/synq"
 	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/SYNQfm/SYNQ-Golang/upload"
 )
 
 const (
 	SYNQ_LEGACY_VERSION = "v1"
 	SYNQ_VERSION        = "v2"
+	MB                  = 1024 * 1024
 )
 
 var DEFAULT_CRED_FILE = os.Getenv("HOME") + "/.synq/credentials.json"
@@ -34,6 +36,12 @@ type ApiSetting struct {
 	UploadTimeout int    `json:"upload_timeout,omitempty"`
 	User          string `json:"user,omitempty"`
 	Password      string `json:"password,omitempty"`
+	// the part settings of file uploads, 0 uses the default (see
+	// upload.PartOptions)
+	UploadPartSizeMB  int  `json:"upload_part_size_mb,omitempty"`
+	UploadConcurrency int  `json:"upload_concurrency,omitempty"`
+	UploadLeaveParts  bool `json:"upload_leave_parts_on_error,omitempty"`
+	UploadMaxBufferMB int  `json:"upload_max_buffer_mb,omitempty"`
 }
 
 type ApiSet struct {
@@ -51,6 +59,19 @@ func (a ApiSetting) Configure(api synq.ApiF) {
 	if a.Url != "" {
 		api.SetUrl(a.Url)
 	}
+	if opts := a.PartOptions(); opts != (upload.PartOptions{}) {
+		api.SetPartOptions(opts)
+	}
+}
+
+// PartOptions returns the upload part settings
+func (a ApiSetting) PartOptions() upload.PartOptions {
+	return upload.PartOptions{
+		PartSize:          int64(a.UploadPartSizeMB) * MB,
+		Concurrency:       a.UploadConcurrency,
+		LeavePartsOnError: a.UploadLeaveParts,
+		MaxBuffer:         int64(a.UploadMaxBufferMB) * MB,
+	}
 }
 
 func (a ApiSetting) SetupV2() synq.ApiV2 {
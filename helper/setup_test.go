@@ -3,6 +3,7 @@ package helper
 import (
 	"testing"
 
+	"github.com/SYNQfm/SYNQ-Golang/upload"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,3 +35,21 @@ func TestLoadFromFile(t *testing.T) {
 	assert.NotNil(set.ApiV2.BaseApi)
 	assert.Equal("456", set.ApiV2.GetKey())
 }
+
+func TestApiSettingPartOptions(t *testing.T) {
+	assert := require.New(t)
+	setting := ApiSetting{ApiKey: "456"}
+	api := setting.SetupV2()
+	assert.Equal(upload.PartOptions{}, api.GetPartOptions())
+	setting.UploadPartSizeMB = 64
+	setting.UploadConcurrency = 16
+	setting.UploadLeaveParts = true
+	setting.UploadMaxBufferMB = 512
+	api = setting.SetupV2()
+	assert.Equal(upload.PartOptions{
+		PartSize:          64 * MB,
+		Concurrency:       16,
+		LeavePartsOnError: true,
+		MaxBuffer:         512 * MB,
+	}, api.GetPartOptions())
+}
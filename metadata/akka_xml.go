@@ -0,0 +1,268 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is any element of an Akka XML file
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+func xmlName(name string) string {
+	return strings.Replace(strings.ToLower(name), "-", "_", -1)
+}
+
+func (n xmlNode) name() string {
+	return xmlName(n.XMLName.Local)
+}
+
+func (n xmlNode) text() string {
+	return strings.TrimSpace(n.Text)
+}
+
+// get returns the attribute or child element called one of names
+func (n xmlNode) get(names ...string) string {
+	for _, name := range names {
+		for _, a := range n.Attrs {
+			if xmlName(a.Name.Local) == name {
+				return strings.TrimSpace(a.Value)
+			}
+		}
+		for _, c := range n.Nodes {
+			if c.name() == name {
+				return c.text()
+			}
+		}
+	}
+	return ""
+}
+
+// value returns the element's text, or a map of its attributes and children
+// when it has any, used for OtherInformation
+func (n xmlNode) value() interface{} {
+	if len(n.Attrs) == 0 && len(n.Nodes) == 0 {
+		return n.text()
+	}
+	v := map[string]interface{}{}
+	for _, a := range n.Attrs {
+		v[xmlName(a.Name.Local)] = a.Value
+	}
+	for _, c := range n.Nodes {
+		addValue(v, c.name(), c.value())
+	}
+	if text := n.text(); text != "" {
+		v["value"] = text
+	}
+	return v
+}
+
+// addValue sets key in v, repeated keys become a list
+func addValue(v map[string]interface{}, key string, value interface{}) {
+	existing, ok := v[key]
+	if !ok {
+		v[key] = value
+		return
+	}
+	if list, ok := existing.([]interface{}); ok {
+		v[key] = append(list, value)
+	} else {
+		v[key] = []interface{}{existing, value}
+	}
+}
+
+// akkaContainers are elements that only group the elements inside them
+var akkaContainers = map[string]bool{
+	"titles": true, "descriptions": true, "genres": true, "credits": true, "countries": true,
+	"images": true, "rights": true, "tags": true, "ratings": true, "metadata": true,
+}
+
+func (n xmlNode) isContainer() bool {
+	if !akkaContainers[n.name()] || len(n.Nodes) == 0 {
+		return false
+	}
+	if n.name() == "rights" {
+		// <rights> is also a single window when it has no <right> or <window> in it
+		child := n.Nodes[0].name()
+		return child == "right" || child == "window"
+	}
+	return true
+}
+
+// description tiers can be given as 'short' or 'content-short'
+var akkaTiers = map[string]string{
+	"":       CONTENT,
+	"tiny":   DESCRIPTION_TINY,
+	"short":  DESCRIPTION_SHORT,
+	"medium": DESCRIPTION_MEDIUM,
+	"long":   DESCRIPTION_LONG,
+}
+
+// ParseAkkaXML reads a partner XML file into an AkkaXMLAsset. Elements are
+// matched by their json names (title, production_year, ...), values can be
+// attributes or child elements and plural elements like <titles> can be used
+// to group them. Elements it does not know are kept in OtherInformation.
+func ParseAkkaXML(r io.Reader) (asset AkkaXMLAsset, err error) {
+	root := xmlNode{}
+	if err = xml.NewDecoder(r).Decode(&root); err != nil {
+		return asset, err
+	}
+	asset.ContentId = root.get("content_id", "id")
+	for _, n := range root.Nodes {
+		if err = asset.parseNode(n); err != nil {
+			return asset, err
+		}
+	}
+	return asset, nil
+}
+
+func parseInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a number", value)
+	}
+	return i, nil
+}
+
+func language(n xmlNode) string {
+	if lang := n.get("lang", "language"); lang != "" {
+		return lang
+	}
+	return ORIGINAL_LANGUAGE
+}
+
+func (a *AkkaXMLAsset) parseNode(n xmlNode) (err error) {
+	name := n.name()
+	if n.isContainer() {
+		for _, c := range n.Nodes {
+			if err = a.parseNode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	switch name {
+	case "content_id", "id":
+		a.ContentId = n.text()
+	case "metadata_version":
+		a.Version = n.text()
+	case "type":
+		a.Type = n.text()
+	case "title":
+		if a.Title == nil {
+			a.Title = LanguageList{}
+		}
+		a.Title.Set(language(n), CONTENT, n.text())
+	case "description":
+		tier := strings.ToLower(n.get("length", "tier", "size"))
+		if t, ok := akkaTiers[tier]; ok {
+			tier = t
+		}
+		if a.Description == nil {
+			a.Description = LanguageList{}
+		}
+		a.Description.Set(language(n), tier, n.text())
+	case "production_year", "year":
+		a.Year, err = parseInt(n.text())
+	case "release_year":
+		a.ReleaseYear, err = parseInt(n.text())
+	case "genre", "genres":
+		a.Genres = append(a.Genres, n.text())
+	case "credit":
+		a.Credits = append(a.Credits, Credit{
+			Name:      firstOf(n.get("name"), n.text()),
+			Function:  n.get("role", "function"),
+			Character: n.get("character"),
+		})
+	case "series":
+		s := &a.Series
+		if s.Season, err = parseInt(n.get("season")); err != nil {
+			break
+		}
+		if s.Episode, err = parseInt(n.get("episode_number", "episode")); err != nil {
+			break
+		}
+		if s.EpisodeCount, err = parseInt(n.get("episodes_in_season")); err != nil {
+			break
+		}
+		s.ExternalId = n.get("external_id")
+		s.InternalId = n.get("internal_id")
+	case "regional_content":
+		a.Regional, _ = strconv.ParseBool(n.text())
+	case "parental_rating":
+		a.Rating = n.text()
+	case "aspect_ratio":
+		a.Ratio = n.text()
+	case "expected_duration", "duration":
+		var d Timecode
+		if d, err = ParseTimecode(n.text(), 0); err == nil {
+			a.Duration = &d
+		}
+	case "country", "country_of_origin", "countries":
+		a.Countries = append(a.Countries, strings.ToUpper(n.text()))
+	case "first_release_date":
+		a.ReleaseDate = n.text()
+	case "original_language":
+		a.OriginalLanguage = n.text()
+	case "studio":
+		a.Studio = n.text()
+	case "imdb_url":
+		a.ImdbUrl = n.text()
+	case "rating", "ratings":
+		a.Ratings = append(a.Ratings, Rating{Country: n.get("country"), Content: firstOf(n.get("content"), n.text())})
+	case "metadata_score":
+		a.MetadataScore = n.text()
+	case "awards_and_recognitions", "awards":
+		a.Awards = n.text()
+	case "image":
+		a.Images = append(a.Images, ImageData{
+			Type:        n.get("type"),
+			Orientation: n.get("orientation"),
+			Language:    n.get("lang", "language"),
+			File:        firstOf(n.get("org_file", "file", "url"), n.text()),
+		})
+	case "right", "rights", "window":
+		window := VideoRights{ValidFrom: n.get("valid_from", "start"), ValidTo: n.get("valid_to", "end")}
+		window.Unlimited, _ = strconv.ParseBool(n.get("unlimited"))
+		for _, c := range n.Nodes {
+			if c.name() == "device" {
+				window.Devices = append(window.Devices, c.text())
+			} else if c.name() == "devices" {
+				for _, d := range c.Nodes {
+					window.Devices = append(window.Devices, d.text())
+				}
+			}
+		}
+		a.Rights = append(a.Rights, window)
+	case "tag", "tags":
+		a.Tags = append(a.Tags, n.text())
+	default:
+		if a.OtherInformation == nil {
+			a.OtherInformation = map[string]interface{}{}
+		}
+		addValue(a.OtherInformation, name, n.value())
+	}
+	if err != nil {
+		return fmt.Errorf("<%s> : %s", n.XMLName.Local, err.Error())
+	}
+	return nil
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
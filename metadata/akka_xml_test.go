@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAkkaXML(t *testing.T) {
+	assert := require.New(t)
+	f, err := os.Open("../sample/akka.xml")
+	assert.Nil(err)
+	defer f.Close()
+	asset, err := ParseAkkaXML(f)
+	assert.Nil(err)
+	assert.Equal("TOS-2012", asset.ContentId)
+	assert.Equal("1.0", asset.Version)
+	assert.Equal(TYPE_MOVIE, asset.Type)
+	assert.Equal("Tears of Steel", asset.Title.Get(ORIGINAL_LANGUAGE, CONTENT))
+	assert.Equal("Tårer av stål", asset.Title.Get("nor", CONTENT))
+	assert.Equal("Thom ville bare bli astronaut.", asset.Description.Get("nor", DESCRIPTION_TINY))
+	assert.Contains(asset.Description.Get("nor", DESCRIPTION_SHORT), "Celia")
+	assert.Equal("Thom just wanted to be an astronaut.", asset.Description.Get("eng", CONTENT))
+	assert.Equal(2012, asset.Year)
+	assert.Equal([]string{"Sci-Fi", "Short"}, asset.Genres)
+	assert.Equal([]Credit{
+		{Name: "Derek de Lint", Function: "actor", Character: "Thom"},
+		{Name: "Ian Hubert", Function: "director"},
+	}, asset.Credits)
+	assert.Equal("10", asset.Rating)
+	assert.Equal([]string{"NL"}, asset.Countries)
+	assert.Equal(734*time.Second, asset.Duration.Duration())
+	assert.Equal([]Rating{{Country: "NO", Content: "10"}}, asset.Ratings)
+	assert.Equal([]ImageData{
+		{Type: "poster", Orientation: "portrait", Language: "nor", File: "tos_poster_nor.jpg"},
+		{Type: "still", Orientation: "landscape", File: "tos_still.jpg"},
+	}, asset.Images)
+	assert.Equal([]VideoRights{
+		{ValidFrom: "2018-01-01T00:00:00Z", ValidTo: "2018-12-31T23:59:59Z", Devices: []string{"web", "ios"}},
+		{Unlimited: true, Devices: []string{"tv"}},
+	}, asset.Rights)
+	assert.Equal([]string{"robots", "blender"}, asset.Tags)
+	assert.Equal(map[string]interface{}{
+		"distributor": map[string]interface{}{"id": "42", "value": "Blender Foundation"},
+		"box_office":  []interface{}{"unknown", "none"},
+	}, asset.OtherInformation)
+	assert.Nil(asset.Validate())
+}
+
+func TestParseAkkaXMLErrors(t *testing.T) {
+	assert := require.New(t)
+	_, err := ParseAkkaXML(strings.NewReader(`<asset><title>unclosed</asset>`))
+	assert.NotNil(err)
+	_, err = ParseAkkaXML(strings.NewReader(`<asset><production_year>twenty</production_year></asset>`))
+	assert.Equal("<production_year> : 'twenty' is not a number", err.Error())
+	_, err = ParseAkkaXML(strings.NewReader(`<asset><series season="one"/></asset>`))
+	assert.Equal("<series> : 'one' is not a number", err.Error())
+	_, err = ParseAkkaXML(strings.NewReader(`<asset><expected_duration>soon</expected_duration></asset>`))
+	assert.NotNil(err)
+
+	// a single rights window
+	asset, err := ParseAkkaXML(strings.NewReader(`<asset id="1"><rights><valid_from>2018</valid_from><device>web</device></rights></asset>`))
+	assert.Nil(err)
+	assert.Equal("1", asset.ContentId)
+	assert.Equal([]VideoRights{{ValidFrom: "2018", Devices: []string{"web"}}}, asset.Rights)
+	asset, err = ParseAkkaXML(strings.NewReader(`<asset><series><season>2</season><episode>5</episode></series></asset>`))
+	assert.Nil(err)
+	assert.Equal(Series{Season: 2, Episode: 5}, asset.Series)
+}
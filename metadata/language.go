@@ -0,0 +1,190 @@
+package metadata
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// isoCodes maps ISO 639-2 (both the T and B codes) and alias codes to the
+// ISO 639-1 code NormalizeLanguage returns
+var isoCodes = map[string]string{
+	// Norwegian: 'nor' and 'no' are the macrolanguage, Bokmål ('nb', 'nob') is
+	// what is almost always meant, so they are all the same language here
+	"nor": "no", "nob": "no", "nb": "no",
+	"nno": "nn",
+	"dan": "da", "swe": "sv", "fin": "fi", "isl": "is", "ice": "is",
+	"eng": "en", "deu": "de", "ger": "de", "fra": "fr", "fre": "fr",
+	"spa": "es", "ita": "it", "por": "pt", "nld": "nl", "dut": "nl",
+	"pol": "pl", "ces": "cs", "cze": "cs", "slk": "sk", "slo": "sk",
+	"hun": "hu", "ron": "ro", "rum": "ro", "bul": "bg", "hrv": "hr",
+	"srp": "sr", "slv": "sl", "ell": "el", "gre": "el", "tur": "tr",
+	"rus": "ru", "ukr": "uk", "est": "et", "lav": "lv", "lit": "lt",
+	"ara": "ar", "heb": "he", "fas": "fa", "per": "fa", "hin": "hi",
+	"zho": "zh", "chi": "zh", "jpn": "ja", "kor": "ko", "tha": "th",
+	"vie": "vi", "ind": "id", "msa": "ms", "may": "ms", "cat": "ca",
+	"eus": "eu", "baq": "eu", "glg": "gl", "gle": "ga", "cym": "cy",
+	"wel": "cy", "sme": "se",
+}
+
+// NormalizeLanguage returns the ISO 639-1 code for a BCP-47, ISO 639-1 or
+// ISO 639-2 language code ("nb-NO", "nor" and "no" all return "no"). Codes it
+// does not know are returned lower cased without their region, ORIGINAL_LANGUAGE
+// is returned as is.
+func NormalizeLanguage(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == ORIGINAL_LANGUAGE {
+		return code
+	}
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	if iso, ok := isoCodes[code]; ok {
+		return iso
+	}
+	return code
+}
+
+// SameLanguage returns true if a and b are codes for the same language
+func SameLanguage(a, b string) bool {
+	return NormalizeLanguage(a) == NormalizeLanguage(b)
+}
+
+// TITLE_TIERS and DESCRIPTION_TIERS are the order values are looked for in a Language
+var TITLE_TIERS = []string{CONTENT, DESCRIPTION_LONG, DESCRIPTION_MEDIUM, DESCRIPTION_SHORT, DESCRIPTION_TINY}
+var DESCRIPTION_TIERS = []string{DESCRIPTION_LONG, DESCRIPTION_MEDIUM, DESCRIPTION_SHORT, DESCRIPTION_TINY, CONTENT}
+
+// Resolver picks the best value from a LanguageList for a requested language
+type Resolver struct {
+	// Chains are the languages to try (in order) when a language is missing,
+	// keyed by language code, e.g. "da": {"no", "sv"}
+	Chains map[string][]string
+	// Fallbacks are tried after the language and its chain
+	Fallbacks []string
+}
+
+// DefaultResolver only falls back to the original language
+var DefaultResolver = Resolver{Fallbacks: []string{ORIGINAL_LANGUAGE}}
+
+// Resolved is a value found by a Resolver, Language is the key in the
+// LanguageList it was found under
+type Resolved struct {
+	Value    string
+	Language string
+	Tier     string
+}
+
+// Languages returns the normalized languages tried for lang, in order
+func (r Resolver) Languages(lang string) []string {
+	langs := []string{}
+	seen := map[string]bool{}
+	add := func(codes ...string) {
+		for _, c := range codes {
+			c = NormalizeLanguage(c)
+			if c != "" && !seen[c] {
+				seen[c] = true
+				langs = append(langs, c)
+			}
+		}
+	}
+	add(lang)
+	keys := []string{}
+	for key := range r.Chains {
+		if SameLanguage(key, lang) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add(r.Chains[key]...)
+	}
+	add(r.Fallbacks...)
+	return langs
+}
+
+// find returns the entries in list for the normalized language lang
+func (l LanguageList) find(lang string) (keys []string) {
+	for key := range l {
+		if NormalizeLanguage(key) == lang {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(l LanguageList) []string {
+	keys := []string{}
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Has returns true if there is a non empty value in lang (ignoring fallbacks)
+func (l LanguageList) Has(lang string) bool {
+	for _, key := range l.find(NormalizeLanguage(lang)) {
+		for _, value := range l[key] {
+			if value != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Resolve returns the first value found, trying every tier in a language
+// before moving to the next language. Values longer than maxLen characters
+// are skipped unless maxLen is 0.
+func (r Resolver) Resolve(list LanguageList, lang string, maxLen int, tiers ...string) (Resolved, bool) {
+	for _, l := range r.Languages(lang) {
+		for _, key := range list.find(l) {
+			for _, tier := range tiers {
+				value := list[key][tier]
+				if value == "" || (maxLen > 0 && utf8.RuneCountInString(value) > maxLen) {
+					continue
+				}
+				return Resolved{Value: value, Language: key, Tier: tier}, true
+			}
+		}
+	}
+	return Resolved{}, false
+}
+
+// Title returns the best title for lang
+func (r Resolver) Title(meta MetaData, lang string) (Resolved, bool) {
+	return r.Resolve(meta.Title, lang, 0, TITLE_TIERS...)
+}
+
+// Description returns the longest description for lang that is at most
+// maxLen characters (any length if maxLen is 0)
+func (r Resolver) Description(meta MetaData, lang string, maxLen int) (Resolved, bool) {
+	return r.Resolve(meta.Description, lang, maxLen, DESCRIPTION_TIERS...)
+}
+
+// LanguageReport lists the requested languages a title or description is
+// missing in
+type LanguageReport struct {
+	Title       []string `json:"title,omitempty"`
+	Description []string `json:"description,omitempty"`
+}
+
+func (l LanguageReport) Complete() bool {
+	return len(l.Title) == 0 && len(l.Description) == 0
+}
+
+// MissingLanguages reports which of langs have no title or description,
+// fallbacks are not used
+func MissingLanguages(meta MetaData, langs ...string) LanguageReport {
+	report := LanguageReport{}
+	for _, lang := range langs {
+		if !meta.Title.Has(lang) {
+			report.Title = append(report.Title, lang)
+		}
+		if !meta.Description.Has(lang) {
+			report.Description = append(report.Description, lang)
+		}
+	}
+	return report
+}
@@ -0,0 +1,93 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testMeta() MetaData {
+	return MetaData{
+		Title: LanguageList{
+			"original": {CONTENT: "Tears of Steel"},
+			"nor":      {CONTENT: "Tårer av stål"},
+			"en-GB":    {CONTENT: "Tears of Steel (UK)"},
+		},
+		Description: LanguageList{
+			"nor": {
+				DESCRIPTION_TINY:  "Kort.",
+				DESCRIPTION_SHORT: "Litt lengre beskrivelse.",
+				DESCRIPTION_LONG:  "En mye lengre beskrivelse som ikke passer.",
+			},
+			"eng": {DESCRIPTION_MEDIUM: "A medium description."},
+		},
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	assert := require.New(t)
+	for _, code := range []string{"nor", "no", "nb", "nob", "nb-NO", "NB_no"} {
+		assert.Equal("no", NormalizeLanguage(code), code)
+	}
+	assert.Equal("nn", NormalizeLanguage("nno"))
+	assert.Equal("de", NormalizeLanguage("ger"))
+	assert.Equal("de", NormalizeLanguage("deu"))
+	assert.Equal("en", NormalizeLanguage("en-US"))
+	assert.Equal("xx", NormalizeLanguage("XX-YY"))
+	assert.Equal(ORIGINAL_LANGUAGE, NormalizeLanguage("original"))
+	assert.True(SameLanguage("nor", "nb-NO"))
+	assert.False(SameLanguage("nor", "nno"))
+}
+
+func TestResolveTitle(t *testing.T) {
+	assert := require.New(t)
+	meta := testMeta()
+	title, ok := DefaultResolver.Title(meta, "nb-NO")
+	assert.True(ok)
+	assert.Equal(Resolved{Value: "Tårer av stål", Language: "nor", Tier: CONTENT}, title)
+	title, _ = DefaultResolver.Title(meta, "sv")
+	assert.Equal("Tears of Steel", title.Value)
+	assert.Equal("original", title.Language)
+
+	r := Resolver{Chains: map[string][]string{"da": {"sv", "no"}}, Fallbacks: []string{"en", ORIGINAL_LANGUAGE}}
+	assert.Equal([]string{"da", "sv", "no", "en", ORIGINAL_LANGUAGE}, r.Languages("dan"))
+	title, _ = r.Title(meta, "da-DK")
+	assert.Equal("nor", title.Language)
+	title, _ = r.Title(meta, "fi")
+	assert.Equal("en-GB", title.Language)
+
+	_, ok = Resolver{}.Title(meta, "fi")
+	assert.False(ok)
+}
+
+func TestResolveDescription(t *testing.T) {
+	assert := require.New(t)
+	meta := testMeta()
+	desc, ok := DefaultResolver.Description(meta, "no", 0)
+	assert.True(ok)
+	assert.Equal(DESCRIPTION_LONG, desc.Tier)
+	desc, _ = DefaultResolver.Description(meta, "no", 30)
+	assert.Equal(Resolved{Value: "Litt lengre beskrivelse.", Language: "nor", Tier: DESCRIPTION_SHORT}, desc)
+	// counts characters, not bytes
+	meta.Description["nor"][DESCRIPTION_TINY] = "Kåååå"
+	desc, _ = DefaultResolver.Description(meta, "no", 5)
+	assert.Equal("Kåååå", desc.Value)
+
+	r := Resolver{Fallbacks: []string{"en"}}
+	desc, _ = r.Description(meta, "fi", 100)
+	assert.Equal(DESCRIPTION_MEDIUM, desc.Tier)
+	_, ok = r.Description(meta, "fi", 5)
+	assert.False(ok)
+}
+
+func TestMissingLanguages(t *testing.T) {
+	assert := require.New(t)
+	report := MissingLanguages(testMeta(), "nb", "en", "sv")
+	assert.Equal([]string{"sv"}, report.Title)
+	assert.Equal([]string{"sv"}, report.Description)
+	assert.False(report.Complete())
+	report = MissingLanguages(testMeta(), "no")
+	assert.True(report.Complete())
+	report = MissingLanguages(MetaData{}, "no")
+	assert.Equal([]string{"no"}, report.Title)
+}
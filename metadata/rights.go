@@ -0,0 +1,245 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// forever is used as the end of windows without a valid_to
+	forever = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	// DEFAULT_DEVICE_GROUPS are device names that match a group of devices
+	DEFAULT_DEVICE_GROUPS = map[string][]string{
+		"mobile": {"ios", "android"},
+		"tv":     {"appletv", "androidtv", "smarttv", "chromecast"},
+	}
+)
+
+// RightsWindow is a parsed VideoRights, zero From and To are open ended
+type RightsWindow struct {
+	From      time.Time
+	To        time.Time
+	Unlimited bool
+	Devices   []string
+}
+
+// parseRightsTime accepts RFC 3339 times, times without a zone (UTC) and
+// dates, a date used as the end of a window includes that whole day
+func parseRightsTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, fmt.Errorf("rights time '%s' is not a date or RFC 3339 time", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// Window parses the rights
+func (r VideoRights) Window() (w RightsWindow, err error) {
+	w.Unlimited = r.Unlimited
+	w.Devices = r.Devices
+	if w.From, err = parseRightsTime(r.ValidFrom, false); err != nil {
+		return w, err
+	}
+	if w.To, err = parseRightsTime(r.ValidTo, true); err != nil {
+		return w, err
+	}
+	if !w.To.IsZero() && !w.From.IsZero() && !w.To.After(w.From) {
+		return w, fmt.Errorf("rights window ends (%s) before it starts (%s)", r.ValidTo, r.ValidFrom)
+	}
+	return w, nil
+}
+
+// Contains returns true if t is in the window, From is included and To is not
+func (w RightsWindow) Contains(t time.Time) bool {
+	if w.Unlimited {
+		return true
+	}
+	return !t.Before(w.From) && (w.To.IsZero() || t.Before(w.To))
+}
+
+// RightsEngine answers availability questions about VideoRights
+type RightsEngine struct {
+	// DeviceGroups are device names in rights that stand for several devices,
+	// DEFAULT_DEVICE_GROUPS if nil
+	DeviceGroups map[string][]string
+}
+
+var DefaultRightsEngine = RightsEngine{}
+
+// matches returns true if a window for devices covers device. No devices, or
+// the device 'all', covers every device and so does asking for device "".
+func (e RightsEngine) matches(devices []string, device string) bool {
+	if len(devices) == 0 || device == "" {
+		return true
+	}
+	groups := e.DeviceGroups
+	if groups == nil {
+		groups = DEFAULT_DEVICE_GROUPS
+	}
+	for _, d := range devices {
+		if strings.EqualFold(d, device) || strings.EqualFold(d, "all") || d == "*" {
+			return true
+		}
+		for _, member := range groups[strings.ToLower(d)] {
+			if strings.EqualFold(member, device) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type interval struct {
+	from, to time.Time
+}
+
+// availability returns when the rights allow device to play, as sorted and
+// non overlapping intervals
+func (e RightsEngine) availability(rights []VideoRights, device string) ([]interval, error) {
+	intervals := []interval{}
+	for _, r := range rights {
+		w, err := r.Window()
+		if err != nil {
+			return nil, err
+		}
+		if !e.matches(w.Devices, device) {
+			continue
+		}
+		i := interval{from: w.From, to: w.To}
+		if w.Unlimited {
+			i = interval{}
+		}
+		if i.to.IsZero() {
+			i.to = forever
+		}
+		intervals = append(intervals, i)
+	}
+	sort.Slice(intervals, func(a, b int) bool {
+		return intervals[a].from.Before(intervals[b].from)
+	})
+	merged := []interval{}
+	for _, i := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && !i.from.After(merged[last].to) {
+			if i.to.After(merged[last].to) {
+				merged[last].to = i.to
+			}
+			continue
+		}
+		merged = append(merged, i)
+	}
+	return merged, nil
+}
+
+// Playable returns true if the rights allow device to play at
+func (e RightsEngine) Playable(rights []VideoRights, device string, at time.Time) (bool, error) {
+	_, ok, err := e.current(rights, device, at)
+	return ok, err
+}
+
+func (e RightsEngine) current(rights []VideoRights, device string, at time.Time) (interval, bool, error) {
+	intervals, err := e.availability(rights, device)
+	if err != nil {
+		return interval{}, false, err
+	}
+	for _, i := range intervals {
+		if !at.Before(i.from) && at.Before(i.to) {
+			return i, true, nil
+		}
+	}
+	return interval{}, false, nil
+}
+
+// NextAvailable returns the first time at or after at that device can play,
+// false if it never can
+func (e RightsEngine) NextAvailable(rights []VideoRights, device string, at time.Time) (time.Time, bool, error) {
+	intervals, err := e.availability(rights, device)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for _, i := range intervals {
+		if at.Before(i.to) {
+			if at.Before(i.from) {
+				return i.from, true, nil
+			}
+			return at, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// Expires returns when device stops being able to play, taking overlapping
+// windows into account. It returns false if device can not play at or if the
+// availability never ends.
+func (e RightsEngine) Expires(rights []VideoRights, device string, at time.Time) (time.Time, bool, error) {
+	i, ok, err := e.current(rights, device, at)
+	if err != nil || !ok || !i.to.Before(forever) {
+		return time.Time{}, false, err
+	}
+	return i.to, true, nil
+}
+
+// RightsSet is the rights of several videos by id
+type RightsSet map[string][]VideoRights
+
+// RightsExpiry is when a video's availability ends
+type RightsExpiry struct {
+	Id      string
+	Expires time.Time
+}
+
+func (s RightsSet) ids() []string {
+	ids := []string{}
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// PlayableVideos returns the ids of the videos device can play at
+func (e RightsEngine) PlayableVideos(set RightsSet, device string, at time.Time) ([]string, error) {
+	ids := []string{}
+	for _, id := range set.ids() {
+		ok, err := e.Playable(set[id], device, at)
+		if err != nil {
+			return nil, fmt.Errorf("video %s : %s", id, err.Error())
+		}
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ExpiringVideos returns the videos device can play at, but not for longer
+// than within, sorted by when they expire
+func (e RightsEngine) ExpiringVideos(set RightsSet, device string, at time.Time, within time.Duration) ([]RightsExpiry, error) {
+	expiring := []RightsExpiry{}
+	for _, id := range set.ids() {
+		expires, ok, err := e.Expires(set[id], device, at)
+		if err != nil {
+			return nil, fmt.Errorf("video %s : %s", id, err.Error())
+		}
+		if ok && !expires.After(at.Add(within)) {
+			expiring = append(expiring, RightsExpiry{Id: id, Expires: expires})
+		}
+	}
+	sort.SliceStable(expiring, func(a, b int) bool {
+		return expiring[a].Expires.Before(expiring[b].Expires)
+	})
+	return expiring, nil
+}
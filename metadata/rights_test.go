@@ -0,0 +1,110 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func day(d int) time.Time {
+	return time.Date(2018, 1, d, 12, 0, 0, 0, time.UTC)
+}
+
+func TestRightsWindow(t *testing.T) {
+	assert := require.New(t)
+	w, err := VideoRights{ValidFrom: "2018-01-01", ValidTo: "2018-01-10"}.Window()
+	assert.Nil(err)
+	assert.True(w.Contains(day(1)))
+	// a date as the end includes that day
+	assert.True(w.Contains(day(10)))
+	assert.False(w.Contains(day(11)))
+	w, err = VideoRights{ValidFrom: "2018-01-05T10:00:00+01:00"}.Window()
+	assert.Nil(err)
+	assert.True(w.Contains(time.Date(2018, 1, 5, 9, 0, 0, 0, time.UTC)))
+	assert.False(w.Contains(time.Date(2018, 1, 5, 8, 59, 0, 0, time.UTC)))
+	assert.True(w.Contains(day(31).AddDate(10, 0, 0)))
+
+	_, err = VideoRights{ValidFrom: "soon"}.Window()
+	assert.NotNil(err)
+	_, err = VideoRights{ValidFrom: "2018-01-10", ValidTo: "2018-01-01"}.Window()
+	assert.NotNil(err)
+}
+
+func TestRightsEngine(t *testing.T) {
+	assert := require.New(t)
+	e := DefaultRightsEngine
+	rights := []VideoRights{
+		{ValidFrom: "2018-01-01", ValidTo: "2018-01-05", Devices: []string{"web"}},
+		// overlaps the first window
+		{ValidFrom: "2018-01-04", ValidTo: "2018-01-08", Devices: []string{"web", "mobile"}},
+		{ValidFrom: "2018-01-20", ValidTo: "2018-01-25"},
+	}
+	playable, err := e.Playable(rights, "web", day(7))
+	assert.Nil(err)
+	assert.True(playable)
+	playable, _ = e.Playable(rights, "ios", day(2))
+	assert.False(playable)
+	playable, _ = e.Playable(rights, "ios", day(5))
+	assert.True(playable)
+	playable, _ = e.Playable(rights, "tv", day(5))
+	assert.False(playable)
+	playable, _ = e.Playable(rights, "tv", day(21))
+	assert.True(playable)
+
+	next, ok, err := e.NextAvailable(rights, "tv", day(1))
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(time.Date(2018, 1, 20, 0, 0, 0, 0, time.UTC), next)
+	next, ok, _ = e.NextAvailable(rights, "web", day(3))
+	assert.True(ok)
+	assert.Equal(day(3), next)
+	_, ok, _ = e.NextAvailable(rights, "web", day(26))
+	assert.False(ok)
+
+	// the overlapping windows are merged
+	expires, ok, err := e.Expires(rights, "web", day(2))
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(time.Date(2018, 1, 9, 0, 0, 0, 0, time.UTC), expires)
+	_, ok, _ = e.Expires(rights, "web", day(15))
+	assert.False(ok)
+
+	unlimited := append(rights, VideoRights{Unlimited: true, Devices: []string{"web"}})
+	_, ok, _ = e.Expires(unlimited, "web", day(2))
+	assert.False(ok)
+	playable, _ = e.Playable(unlimited, "web", day(15))
+	assert.True(playable)
+
+	e = RightsEngine{DeviceGroups: map[string][]string{"tv": {"roku"}}}
+	playable, _ = e.Playable([]VideoRights{{Devices: []string{"TV"}}}, "Roku", day(1))
+	assert.True(playable)
+	playable, _ = e.Playable([]VideoRights{{Devices: []string{"all"}}}, "anything", day(1))
+	assert.True(playable)
+	_, err = e.Playable([]VideoRights{{ValidTo: "never"}}, "web", day(1))
+	assert.NotNil(err)
+}
+
+func TestRightsSet(t *testing.T) {
+	assert := require.New(t)
+	set := RightsSet{
+		"a": {{ValidTo: "2018-01-05"}},
+		"b": {{ValidTo: "2018-01-03"}},
+		"c": {{ValidTo: "2018-01-30"}},
+		"d": {{ValidFrom: "2018-01-10"}},
+		"e": {{Unlimited: true}},
+	}
+	e := DefaultRightsEngine
+	ids, err := e.PlayableVideos(set, "web", day(2))
+	assert.Nil(err)
+	assert.Equal([]string{"a", "b", "c", "e"}, ids)
+	expiring, err := e.ExpiringVideos(set, "web", day(1), 7*24*time.Hour)
+	assert.Nil(err)
+	assert.Equal([]RightsExpiry{
+		{Id: "b", Expires: time.Date(2018, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{Id: "a", Expires: time.Date(2018, 1, 6, 0, 0, 0, 0, time.UTC)},
+	}, expiring)
+	set["f"] = []VideoRights{{ValidFrom: "bad"}}
+	_, err = e.ExpiringVideos(set, "web", day(1), time.Hour)
+	assert.Equal("video f : rights time 'bad' is not a date or RFC 3339 time", err.Error())
+}
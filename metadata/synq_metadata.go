@@ -1,5 +1,18 @@
 package metadata
 
+const (
+	// ORIGINAL_LANGUAGE is the language key used for the untranslated title
+	ORIGINAL_LANGUAGE  = "original"
+	CONTENT            = "content"
+	DESCRIPTION_TINY   = "content-tiny"
+	DESCRIPTION_SHORT  = "content-short"
+	DESCRIPTION_MEDIUM = "content-medium"
+	DESCRIPTION_LONG   = "content-long"
+)
+
+// DESCRIPTION_SIZES lists the description keys from longest to shortest
+var DESCRIPTION_SIZES = []string{DESCRIPTION_LONG, DESCRIPTION_MEDIUM, DESCRIPTION_SHORT, DESCRIPTION_TINY}
+
 type MetaData struct {
 	Version          string       `json:"metadata_version"`
 	Title            LanguageList `json:"title"`
@@ -13,7 +26,7 @@ type MetaData struct {
 	Regional         bool         `json:"regional_content"`
 	Rating           string       `json:"parental_rating"`
 	Ratio            string       `json:"aspect_ratio,omitempty"`
-	Duration         string       `json:"expected_duration,omitempty"`
+	Duration         *Timecode    `json:"expected_duration,omitempty"`
 	Countries        []string     `json:"country_of_origin"`
 	ReleaseDate      string       `json:"first_release_date,omitempty"`
 	OriginalLanguage string       `json:"original_language,omitempty"`
@@ -41,6 +54,19 @@ type Credit struct {
 type Language map[string]string
 type LanguageList map[string]Language
 
+// Get returns the value of key in lang, or "" if there is none
+func (l LanguageList) Get(lang, key string) string {
+	return l[lang][key]
+}
+
+// Set sets key in lang to value, creating the language if needed
+func (l LanguageList) Set(lang, key, value string) {
+	if l[lang] == nil {
+		l[lang] = Language{}
+	}
+	l[lang][key] = value
+}
+
 type ImageData struct {
 	Type        string `json:"type"`
 	Orientation string `json:"orientation"`
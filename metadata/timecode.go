@@ -0,0 +1,209 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TIMECODE_SMPTE is HH:MM:SS:FF, the last field is frames
+	TIMECODE_SMPTE = "smpte"
+	// TIMECODE_CLOCK is HH:MM:SS with optional fractions of a second, HH:MM:SS.mmm
+	TIMECODE_CLOCK = "clock"
+	// TIMECODE_ISO8601 is an ISO 8601 duration like PT12M14S
+	TIMECODE_ISO8601 = "iso8601"
+
+	// DEFAULT_FRAME_RATE is used for SMPTE timecodes when no frame rate is known
+	DEFAULT_FRAME_RATE = 25.0
+	// DEFAULT_DURATION_TOLERANCE is how far expected_duration can be from the
+	// probed duration of the media before CheckDuration flags it
+	DEFAULT_DURATION_TOLERANCE = 2 * time.Second
+)
+
+var (
+	smpteRe   = regexp.MustCompile(`^(\d{2,}):([0-5]\d):([0-5]\d)[:;](\d{2,3})$`)
+	clockRe   = regexp.MustCompile(`^(\d{1,}):([0-5]\d):([0-5]\d(?:\.\d+)?)$`)
+	iso8601Re = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+)
+
+// Timecode is a duration written as a SMPTE timecode, a clock time or an ISO
+// 8601 duration. The text it was parsed from is kept, so unchanged values are
+// written back exactly as they were read.
+type Timecode struct {
+	text   string
+	format string
+	// whole seconds and frames of a SMPTE timecode, kept separately so the
+	// frame rate can be changed after parsing
+	seconds int64
+	frames  int64
+	// fraction is the last field read as a fraction of a second, used when
+	// it has more frames than the frame rate (see Ambiguous)
+	fraction float64
+	d        time.Duration
+	// FrameRate converts the frames of a SMPTE timecode, DEFAULT_FRAME_RATE if 0
+	FrameRate float64
+}
+
+// ParseTimecode parses any of the supported formats, frameRate is only used
+// for SMPTE timecodes (DEFAULT_FRAME_RATE if 0)
+func ParseTimecode(text string, frameRate float64) (Timecode, error) {
+	t := Timecode{text: text, FrameRate: frameRate}
+	if m := smpteRe.FindStringSubmatch(text); m != nil {
+		t.format = TIMECODE_SMPTE
+		t.seconds = atoi(m[1])*3600 + atoi(m[2])*60 + atoi(m[3])
+		t.frames = atoi(m[4])
+		t.fraction, _ = strconv.ParseFloat("0."+m[4], 64)
+		return t, nil
+	}
+	if m := clockRe.FindStringSubmatch(text); m != nil {
+		secs, _ := strconv.ParseFloat(m[3], 64)
+		t.format = TIMECODE_CLOCK
+		t.d = time.Duration(atoi(m[1]))*time.Hour + time.Duration(atoi(m[2]))*time.Minute + seconds(secs)
+		return t, nil
+	}
+	if m := iso8601Re.FindStringSubmatch(text); m != nil && text != "P" && text != "PT" {
+		secs, _ := strconv.ParseFloat(strings.Replace(m[4], ",", ".", 1), 64)
+		t.format = TIMECODE_ISO8601
+		t.d = time.Duration(atoi(m[1]))*24*time.Hour + time.Duration(atoi(m[2]))*time.Hour +
+			time.Duration(atoi(m[3]))*time.Minute + seconds(secs)
+		return t, nil
+	}
+	return Timecode{}, fmt.Errorf("'%s' is not a HH:MM:SS:FF, HH:MM:SS.mmm or ISO 8601 duration", text)
+}
+
+// NewTimecode returns the duration d written in format, SMPTE timecodes drop
+// partial frames
+func NewTimecode(d time.Duration, format string, frameRate float64) Timecode {
+	t := Timecode{format: format, d: d, FrameRate: frameRate}
+	if format == TIMECODE_SMPTE {
+		// allow for rounding errors with rates like 29.97
+		total := int64(math.Floor(d.Seconds()*t.rate() + 1e-6))
+		t.seconds, t.frames = total/t.fps(), total%t.fps()
+		t.d = 0
+	}
+	return t
+}
+
+func atoi(s string) int64 {
+	i, _ := strconv.ParseInt(s, 10, 64)
+	return i
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+func (t Timecode) rate() float64 {
+	if t.FrameRate > 0 {
+		return t.FrameRate
+	}
+	return DEFAULT_FRAME_RATE
+}
+
+// fps is the number of frames counted per second, 30 for 29.97
+func (t Timecode) fps() int64 {
+	return int64(math.Ceil(t.rate()))
+}
+
+// Format is the format the timecode was parsed from or created with, "" if
+// it is not valid
+func (t Timecode) Format() string {
+	return t.format
+}
+
+func (t Timecode) IsZero() bool {
+	return t.text == "" && t.format == ""
+}
+
+// Valid returns false for the zero Timecode and for text read from json that
+// is not a timecode
+func (t Timecode) Valid() bool {
+	return t.format != ""
+}
+
+// Ambiguous returns true for a SMPTE timecode with more frames than the frame
+// rate, like 00:12:14:50 at 25 fps. The last field is probably hundredths of
+// a second, so Duration reads it as a fraction of a second.
+func (t Timecode) Ambiguous() bool {
+	return t.format == TIMECODE_SMPTE && t.frames >= t.fps()
+}
+
+func (t Timecode) Duration() time.Duration {
+	if t.Ambiguous() {
+		return time.Duration(t.seconds)*time.Second + seconds(t.fraction)
+	}
+	if t.format == TIMECODE_SMPTE {
+		frames := t.seconds*t.fps() + t.frames
+		return seconds(float64(frames) / t.rate())
+	}
+	return t.d
+}
+
+// In returns the timecode written in format
+func (t Timecode) In(format string) string {
+	d := t.Duration()
+	switch format {
+	case TIMECODE_SMPTE:
+		n := NewTimecode(d, TIMECODE_SMPTE, t.FrameRate)
+		s := n.seconds
+		return fmt.Sprintf("%02d:%02d:%02d:%02d", s/3600, s/60%60, s%60, n.frames)
+	case TIMECODE_CLOCK:
+		ms := d.Milliseconds()
+		return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+	case TIMECODE_ISO8601:
+		s := "PT"
+		if h := int64(d / time.Hour); h > 0 {
+			s += strconv.FormatInt(h, 10) + "H"
+		}
+		if m := int64(d / time.Minute % 60); m > 0 {
+			s += strconv.FormatInt(m, 10) + "M"
+		}
+		if secs := (d % time.Minute).Seconds(); secs > 0 || s == "PT" {
+			s += strconv.FormatFloat(secs, 'f', -1, 64) + "S"
+		}
+		return s
+	}
+	return ""
+}
+
+// String returns the text the timecode was parsed from, or the timecode in
+// its format
+func (t Timecode) String() string {
+	if t.text != "" {
+		return t.text
+	}
+	return t.In(t.format)
+}
+
+func (t Timecode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON never fails on text it can not parse, so existing metadata can
+// always be read and written back, Valid returns false for it instead
+func (t *Timecode) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	parsed, err := ParseTimecode(text, t.FrameRate)
+	if err != nil {
+		parsed = Timecode{text: text, FrameRate: t.FrameRate}
+	}
+	*t = parsed
+	return nil
+}
+
+// Matches returns true if the timecode is within tolerance of d
+func (t Timecode) Matches(d time.Duration, tolerance time.Duration) bool {
+	diff := t.Duration() - d
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= tolerance
+}
@@ -0,0 +1,148 @@
+package metadata
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustTimecode(text string) Timecode {
+	t, err := ParseTimecode(text, 0)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func timecodeRef(text string) *Timecode {
+	t := mustTimecode(text)
+	return &t
+}
+
+func TestParseTimecode(t *testing.T) {
+	assert := require.New(t)
+	tests := []struct {
+		text     string
+		rate     float64
+		format   string
+		duration time.Duration
+	}{
+		{"00:12:14:00", 0, TIMECODE_SMPTE, 734 * time.Second},
+		{"00:12:14:12", 0, TIMECODE_SMPTE, 734*time.Second + 480*time.Millisecond},
+		{"00:12:14:12", 24, TIMECODE_SMPTE, 734*time.Second + 500*time.Millisecond},
+		{"01:00:00;00", 29.97, TIMECODE_SMPTE, 3603603603604},
+		{"00:12:14.083", 0, TIMECODE_CLOCK, 734083 * time.Millisecond},
+		{"1:02:03", 0, TIMECODE_CLOCK, time.Hour + 2*time.Minute + 3*time.Second},
+		{"PT12M14S", 0, TIMECODE_ISO8601, 734 * time.Second},
+		{"PT1H0.5S", 0, TIMECODE_ISO8601, time.Hour + 500*time.Millisecond},
+		{"P1DT2H", 0, TIMECODE_ISO8601, 26 * time.Hour},
+		{"PT1,25S", 0, TIMECODE_ISO8601, 1250 * time.Millisecond},
+	}
+	for _, test := range tests {
+		tc, err := ParseTimecode(test.text, test.rate)
+		assert.Nil(err, test.text)
+		assert.Equal(test.format, tc.Format(), test.text)
+		assert.Equal(test.duration, tc.Duration(), test.text)
+		assert.Equal(test.text, tc.String())
+	}
+	for _, bad := range []string{"", "12:14", "00:60:00:00", "PT", "P", "12 minutes", "PT5X"} {
+		_, err := ParseTimecode(bad, 0)
+		assert.NotNil(err, bad)
+	}
+	tc, err := ParseTimecode("00:00:00:25", 30)
+	assert.Nil(err)
+	assert.False(tc.Ambiguous())
+}
+
+func TestAmbiguousTimecode(t *testing.T) {
+	assert := require.New(t)
+	// more frames than 25 fps, so the last field is read as hundredths
+	tc, err := ParseTimecode("00:12:14:50", 0)
+	assert.Nil(err)
+	assert.True(tc.Valid())
+	assert.True(tc.Ambiguous())
+	assert.Equal(734*time.Second+500*time.Millisecond, tc.Duration())
+	assert.Equal("00:12:14:50", tc.String())
+	// at 60 fps they are frames again
+	tc.FrameRate = 60
+	assert.False(tc.Ambiguous())
+	assert.Equal(734*time.Second+833*time.Millisecond, tc.Duration().Truncate(time.Millisecond))
+	tc = mustTimecode("00:00:01:250")
+	assert.True(tc.Ambiguous())
+	assert.Equal(1250*time.Millisecond, tc.Duration())
+}
+
+func TestFormatTimecode(t *testing.T) {
+	assert := require.New(t)
+	tc := mustTimecode("PT1H2M3.5S")
+	assert.Equal("01:02:03:12", tc.In(TIMECODE_SMPTE))
+	assert.Equal("01:02:03.500", tc.In(TIMECODE_CLOCK))
+	assert.Equal("PT1H2M3.5S", tc.In(TIMECODE_ISO8601))
+	tc = NewTimecode(734*time.Second+500*time.Millisecond, TIMECODE_SMPTE, 24)
+	assert.Equal("00:12:14:12", tc.String())
+	assert.Equal("PT12M14.5S", tc.In(TIMECODE_ISO8601))
+	assert.Equal("PT0S", NewTimecode(0, TIMECODE_ISO8601, 0).String())
+	// changing the frame rate re-reads the frames
+	tc = mustTimecode("00:00:01:15")
+	assert.Equal(1600*time.Millisecond, tc.Duration())
+	tc.FrameRate = 30
+	assert.Equal(1500*time.Millisecond, tc.Duration())
+}
+
+func TestTimecodeJSON(t *testing.T) {
+	assert := require.New(t)
+	text := `{"expected_duration":"00:12:14:00"}`
+	meta := MetaData{}
+	assert.Nil(json.Unmarshal([]byte(text), &meta))
+	data, err := json.Marshal(struct {
+		Duration *Timecode `json:"expected_duration,omitempty"`
+	}{meta.Duration})
+	assert.Nil(err)
+	assert.Equal(text, string(data))
+
+	meta = MetaData{}
+	assert.Nil(json.Unmarshal([]byte(`{"expected_duration":"PT12M14S"}`), &meta))
+	assert.True(meta.Duration.Valid())
+	assert.Equal(734*time.Second, meta.Duration.Duration())
+	assert.NotNil(json.Unmarshal([]byte(`{"expected_duration":734}`), &meta))
+
+	// text that is not a timecode is kept as it is, so existing metadata
+	// always loads
+	for _, text := range []string{`{"expected_duration":"12 min"}`, `{"expected_duration":"00:12:14:50"}`} {
+		meta = MetaData{}
+		assert.Nil(json.Unmarshal([]byte(text), &meta), text)
+		data, err = json.Marshal(struct {
+			Duration *Timecode `json:"expected_duration,omitempty"`
+		}{meta.Duration})
+		assert.Nil(err)
+		assert.Equal(text, string(data))
+	}
+	assert.True(meta.Duration.Ambiguous())
+	meta = MetaData{}
+	assert.Nil(json.Unmarshal([]byte(`{"expected_duration":"12 min"}`), &meta))
+	assert.False(meta.Duration.IsZero())
+	assert.False(meta.Duration.Valid())
+	assert.Nil(meta.CheckDuration(time.Second, 0, 0))
+
+	// metadata without a duration leaves the key out
+	data, err = json.Marshal(MetaData{})
+	assert.Nil(err)
+	assert.NotContains(string(data), "expected_duration")
+	meta = MetaData{}
+	assert.Nil(json.Unmarshal([]byte(`{"expected_duration":""}`), &meta))
+	assert.True(meta.Duration.IsZero())
+	assert.Nil(meta.CheckDuration(time.Second, 0, 0))
+}
+
+func TestCheckDuration(t *testing.T) {
+	assert := require.New(t)
+	meta := MetaData{Duration: timecodeRef("00:12:14:00")}
+	assert.Nil(meta.CheckDuration(734083*time.Millisecond, 24, 0))
+	err := meta.CheckDuration(700*time.Second, 24, 0)
+	assert.NotNil(err)
+	assert.Equal("expected_duration : 00:12:14:00 (12m14s) does not match the media duration 11m40s", err.Error())
+	assert.Nil(meta.CheckDuration(700*time.Second, 24, time.Minute))
+	assert.Nil(MetaData{}.CheckDuration(time.Second, 0, 0))
+}
@@ -0,0 +1,393 @@
+package metadata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	TYPE_MOVIE   = "movie"
+	TYPE_EPISODE = "episode"
+	TYPE_SERIES  = "series"
+	TYPE_TRAILER = "trailer"
+	TYPE_CLIP    = "clip"
+
+	// MIN_YEAR is the earliest production or release year that is accepted
+	MIN_YEAR = 1888
+)
+
+var TYPES = []string{TYPE_MOVIE, TYPE_EPISODE, TYPE_SERIES, TYPE_TRAILER, TYPE_CLIP}
+
+// DESCRIPTION_LIMITS are the default maximum lengths (in characters) of each
+// description tier
+var DESCRIPTION_LIMITS = map[string]int{
+	DESCRIPTION_TINY:   60,
+	DESCRIPTION_SHORT:  160,
+	DESCRIPTION_MEDIUM: 320,
+	DESCRIPTION_LONG:   1000,
+}
+
+// countries are the ISO 3166-1 alpha-2 codes, plus the reserved 'UK' which is
+// commonly used for GB
+var countries = map[string]bool{}
+
+func init() {
+	codes := `AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ
+	BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE
+	EG EH ER ES ET FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR
+	HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS
+	LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL
+	NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW SA SB SC SD SE SG SH SI
+	SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA UG UM US UY
+	UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW UK`
+	for _, c := range strings.Fields(codes) {
+		countries[c] = true
+	}
+}
+
+// ValidCountry returns true if code is an ISO 3166-1 alpha-2 country code
+func ValidCountry(code string) bool {
+	return countries[code]
+}
+
+// ValidationError is a problem with one field of the metadata, Field is the
+// json name of the field
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (v ValidationError) Error() string {
+	return v.Field + " : " + v.Message
+}
+
+// ValidationErrors is every problem Validate found
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+	msgs := []string{}
+	for _, e := range v {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// Field returns the problems for field
+func (v ValidationErrors) Field(field string) (errs ValidationErrors) {
+	for _, e := range v {
+		if e.Field == field {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// Validator checks that MetaData is well formed, zero values use the defaults
+type Validator struct {
+	// MinYear and MaxYear limit production_year and release_year, MaxYear
+	// defaults to 5 years from now
+	MinYear int
+	MaxYear int
+	// DescriptionLimits are the maximum lengths of each description tier
+	DescriptionLimits map[string]int
+}
+
+var DefaultValidator = Validator{}
+
+// Validate checks the metadata with DefaultValidator, it returns nil or ValidationErrors
+func (m MetaData) Validate() error {
+	return DefaultValidator.Validate(m)
+}
+
+// Validate returns nil or ValidationErrors with every problem found
+func (v Validator) Validate(m MetaData) error {
+	errs := v.validate(m)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (v Validator) validate(m MetaData) (errs ValidationErrors) {
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if m.Type == "" {
+		add("type", "is required")
+	} else if !contains(TYPES, m.Type) {
+		add("type", "'%s' is not one of %s", m.Type, strings.Join(TYPES, ", "))
+	}
+	if !hasAny(m.Title) {
+		add("title", "is required")
+	}
+	if m.Type == TYPE_EPISODE {
+		if m.Series.Season <= 0 {
+			add("series", "season is required for an episode")
+		}
+		if m.Series.Episode <= 0 {
+			add("series", "episode_number is required for an episode")
+		}
+	}
+	if m.Series.EpisodeCount > 0 && m.Series.Episode > m.Series.EpisodeCount {
+		add("series", "episode_number %d is more than episodes_in_season %d", m.Series.Episode, m.Series.EpisodeCount)
+	}
+	minYear, maxYear := v.years()
+	if m.Year != 0 && (m.Year < minYear || m.Year > maxYear) {
+		add("production_year", "%d is not between %d and %d", m.Year, minYear, maxYear)
+	}
+	if m.ReleaseYear != 0 && (m.ReleaseYear < minYear || m.ReleaseYear > maxYear) {
+		add("release_year", "%d is not between %d and %d", m.ReleaseYear, minYear, maxYear)
+	}
+	if m.Year != 0 && m.ReleaseYear != 0 && m.ReleaseYear < m.Year {
+		add("release_year", "%d is before production_year %d", m.ReleaseYear, m.Year)
+	}
+	if m.Duration != nil && !m.Duration.IsZero() && !m.Duration.Valid() {
+		add("expected_duration", "'%s' is not HH:MM:SS:FF, HH:MM:SS.mmm or an ISO 8601 duration", m.Duration)
+	} else if m.Duration != nil && m.Duration.Ambiguous() {
+		add("expected_duration", "'%s' has more frames than the frame rate, the last field is read as hundredths of a second", m.Duration)
+	}
+	for _, c := range m.Countries {
+		if !ValidCountry(c) {
+			add("country_of_origin", "'%s' is not an ISO 3166-1 alpha-2 code", c)
+		}
+	}
+	limits := v.DescriptionLimits
+	if limits == nil {
+		limits = DESCRIPTION_LIMITS
+	}
+	for _, lang := range sortedKeys(m.Description) {
+		desc := m.Description[lang]
+		prev, prevTier := 0, ""
+		// walk from the shortest tier up, each tier must fit its limit and
+		// not be shorter than the tier below it
+		for i := len(DESCRIPTION_SIZES) - 1; i >= 0; i-- {
+			tier := DESCRIPTION_SIZES[i]
+			if desc[tier] == "" {
+				continue
+			}
+			length := utf8.RuneCountInString(desc[tier])
+			if limit, ok := limits[tier]; ok && length > limit {
+				add("description", "%s %s is %d characters, the limit is %d", lang, tier, length, limit)
+			}
+			if length < prev {
+				add("description", "%s %s is shorter than %s", lang, tier, prevTier)
+			}
+			prev, prevTier = length, tier
+		}
+	}
+	for i, c := range m.Credits {
+		if c.Name == "" || c.Function == "" {
+			add("credits", "credit %d needs a name and a role", i)
+		}
+	}
+	return errs
+}
+
+func (v Validator) years() (int, int) {
+	minYear, maxYear := v.MinYear, v.MaxYear
+	if minYear == 0 {
+		minYear = MIN_YEAR
+	}
+	if maxYear == 0 {
+		maxYear = time.Now().Year() + 5
+	}
+	return minYear, maxYear
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAny(list LanguageList) bool {
+	for lang := range list {
+		if list.Has(lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// DEFAULT_SCORE_WEIGHTS is how much each field (by json name) counts towards
+// the completeness score
+var DEFAULT_SCORE_WEIGHTS = map[string]float64{
+	"title":             20,
+	"description":       20,
+	"type":              5,
+	"genres":            10,
+	"credits":           10,
+	"production_year":   5,
+	"parental_rating":   5,
+	"country_of_origin": 5,
+	"expected_duration": 5,
+	"original_language": 5,
+	"series":            10,
+}
+
+// FieldScore is how one field contributed to a Score, Score is out of Weight
+type FieldScore struct {
+	Field  string  `json:"field"`
+	Weight float64 `json:"weight"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// Score is the completeness of the metadata (0 - 100) and how each field
+// contributed to it
+type Score struct {
+	Total  float64      `json:"total"`
+	Fields []FieldScore `json:"fields"`
+}
+
+// Scorer computes completeness scores. Fields without a weight are not scored,
+// 'series' is only scored for episodes.
+type Scorer struct {
+	Weights   map[string]float64
+	Validator Validator
+}
+
+var DefaultScorer = Scorer{Weights: DEFAULT_SCORE_WEIGHTS}
+
+// Score scores the metadata with DefaultScorer
+func (m MetaData) Score() Score {
+	return DefaultScorer.Score(m)
+}
+
+// Score gives each field its full weight when set and valid, none when it is
+// missing or invalid and part of it for descriptions missing some tiers
+func (s Scorer) Score(m MetaData) Score {
+	weights := s.Weights
+	if weights == nil {
+		weights = DEFAULT_SCORE_WEIGHTS
+	}
+	errs := s.Validator.validate(m)
+	score := Score{Fields: []FieldScore{}}
+	total, max := 0.0, 0.0
+	for _, field := range sortedFields(weights) {
+		if field == "series" && m.Type != TYPE_EPISODE {
+			continue
+		}
+		fs := FieldScore{Field: field, Weight: weights[field]}
+		fraction, reason := fieldCompleteness(m, field)
+		if fieldErrs := errs.Field(field); len(fieldErrs) > 0 && fraction > 0 {
+			fraction, reason = 0, fieldErrs[0].Message
+		}
+		fs.Score = fs.Weight * fraction
+		fs.Reason = reason
+		total += fs.Score
+		max += fs.Weight
+		score.Fields = append(score.Fields, fs)
+	}
+	if max > 0 {
+		score.Total = math.Round(total/max*1000) / 10
+	}
+	return score
+}
+
+// fieldCompleteness returns how complete field is (0 - 1) and why it is not complete
+func fieldCompleteness(m MetaData, field string) (float64, string) {
+	set := false
+	switch field {
+	case "title":
+		set = hasAny(m.Title)
+	case "description":
+		best := 0
+		for _, lang := range sortedKeys(m.Description) {
+			found := 0
+			for _, tier := range DESCRIPTION_SIZES {
+				if m.Description[lang][tier] != "" {
+					found++
+				}
+			}
+			if found > best {
+				best = found
+			}
+		}
+		if best == 0 && hasAny(m.Description) {
+			// only a generic 'content' description
+			best = 1
+		}
+		if best > 0 && best < len(DESCRIPTION_SIZES) {
+			return float64(best) / float64(len(DESCRIPTION_SIZES)), fmt.Sprintf("%d of %d description lengths", best, len(DESCRIPTION_SIZES))
+		}
+		set = best > 0
+	case "type":
+		set = m.Type != ""
+	case "genres":
+		set = len(m.Genres) > 0
+	case "credits":
+		set = len(m.Credits) > 0
+	case "production_year":
+		set = m.Year != 0
+	case "release_year":
+		set = m.ReleaseYear != 0
+	case "parental_rating":
+		set = m.Rating != ""
+	case "country_of_origin":
+		set = len(m.Countries) > 0
+	case "expected_duration":
+		set = m.Duration != nil && !m.Duration.IsZero()
+	case "original_language":
+		set = m.OriginalLanguage != ""
+	case "series":
+		set = m.Series.Season > 0 && m.Series.Episode > 0
+	case "aspect_ratio":
+		set = m.Ratio != ""
+	case "first_release_date":
+		set = m.ReleaseDate != ""
+	case "studio":
+		set = m.Studio != ""
+	case "imdb_url":
+		set = m.ImdbUrl != ""
+	case "ratings":
+		set = len(m.Ratings) > 0
+	case "awards_and_recognitions":
+		set = m.Awards != ""
+	default:
+		return 0, "unknown field"
+	}
+	if !set {
+		return 0, "missing"
+	}
+	return 1, ""
+}
+
+func sortedFields(weights map[string]float64) []string {
+	fields := []string{}
+	for f := range weights {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// CheckDuration returns a ValidationError if expected_duration is more than
+// tolerance (DEFAULT_DURATION_TOLERANCE if 0) from the probed duration of the
+// media. frameRate is the probed frame rate, used for SMPTE timecodes.
+func (m MetaData) CheckDuration(probed time.Duration, frameRate float64, tolerance time.Duration) error {
+	if m.Duration == nil || !m.Duration.Valid() {
+		return nil
+	}
+	if tolerance == 0 {
+		tolerance = DEFAULT_DURATION_TOLERANCE
+	}
+	expected := *m.Duration
+	if frameRate > 0 {
+		expected.FrameRate = frameRate
+	}
+	if expected.Matches(probed, tolerance) {
+		return nil
+	}
+	return ValidationError{
+		Field:   "expected_duration",
+		Message: fmt.Sprintf("%s (%s) does not match the media duration %s", m.Duration, expected.Duration(), probed),
+	}
+}
@@ -0,0 +1,141 @@
+package metadata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func validMeta() MetaData {
+	return MetaData{
+		Type:  TYPE_MOVIE,
+		Title: LanguageList{ORIGINAL_LANGUAGE: {CONTENT: "Tears of Steel"}},
+		Description: LanguageList{"nor": {
+			DESCRIPTION_TINY:   "Kort.",
+			DESCRIPTION_SHORT:  "Litt lengre.",
+			DESCRIPTION_MEDIUM: "Enda litt lengre.",
+			DESCRIPTION_LONG:   "Den aller lengste beskrivelsen.",
+		}},
+		Year:             2012,
+		Genres:           []string{"Sci-Fi"},
+		Credits:          []Credit{{Name: "Derek de Lint", Function: "actor"}},
+		Rating:           "10",
+		Countries:        []string{"NL", "UK"},
+		Duration:         timecodeRef("00:12:14:00"),
+		OriginalLanguage: "en",
+	}
+}
+
+// durationJSON reads expected_duration the way it is read from the api
+func durationJSON(text string) *Timecode {
+	meta := MetaData{}
+	if err := json.Unmarshal([]byte(`{"expected_duration":"`+text+`"}`), &meta); err != nil {
+		panic(err)
+	}
+	return meta.Duration
+}
+
+func TestValidate(t *testing.T) {
+	assert := require.New(t)
+	assert.Nil(validMeta().Validate())
+
+	meta := validMeta()
+	meta.Type = TYPE_EPISODE
+	meta.Year = 1800
+	meta.ReleaseYear = 2011
+	meta.Duration = durationJSON("12:14")
+	meta.Countries = []string{"no", "XX"}
+	meta.Credits = append(meta.Credits, Credit{Name: "Nobody"})
+	meta.Description["nor"][DESCRIPTION_SHORT] = "Kort"
+	meta.Description["nor"][DESCRIPTION_LONG] = strings.Repeat("a", 1001)
+	err := meta.Validate()
+	errs, ok := err.(ValidationErrors)
+	assert.True(ok)
+	fields := map[string]int{}
+	for _, e := range errs {
+		fields[e.Field]++
+	}
+	assert.Equal(map[string]int{
+		"series":            2,
+		"production_year":   1,
+		"expected_duration": 1,
+		"country_of_origin": 2,
+		"credits":           1,
+		"description":       2,
+	}, fields)
+	assert.Contains(err.Error(), "'XX' is not an ISO 3166-1 alpha-2 code")
+	assert.Equal("nor content-short is shorter than content-tiny", errs.Field("description")[0].Message)
+
+	meta = MetaData{Type: "podcast", Year: 2012, ReleaseYear: 2011}
+	errs = meta.Validate().(ValidationErrors)
+	assert.Len(errs.Field("type"), 1)
+	assert.Len(errs.Field("title"), 1)
+	assert.Equal("2011 is before production_year 2012", errs.Field("release_year")[0].Message)
+
+	v := Validator{MaxYear: 2010, DescriptionLimits: map[string]int{DESCRIPTION_TINY: 3}}
+	errs = v.Validate(validMeta()).(ValidationErrors)
+	assert.Len(errs, 2)
+	assert.Len(errs.Field("production_year"), 1)
+	assert.Len(errs.Field("description"), 1)
+}
+
+func TestValidateDuration(t *testing.T) {
+	assert := require.New(t)
+	for text, message := range map[string]string{
+		"PT12M14S":    "",
+		"":            "",
+		"12 min":      "'12 min' is not HH:MM:SS:FF, HH:MM:SS.mmm or an ISO 8601 duration",
+		"00:12:14:50": "'00:12:14:50' has more frames than the frame rate, the last field is read as hundredths of a second",
+	} {
+		data := `{"type":"movie","title":{"eng":{"content":"Tears of Steel"}},"expected_duration":"` + text + `"}`
+		meta := MetaData{}
+		assert.Nil(json.Unmarshal([]byte(data), &meta), text)
+		err := meta.Validate()
+		if message == "" {
+			assert.Nil(err, text)
+			continue
+		}
+		errs := err.(ValidationErrors).Field("expected_duration")
+		assert.Len(errs, 1, text)
+		assert.Equal(message, errs[0].Message)
+	}
+}
+
+func TestScore(t *testing.T) {
+	assert := require.New(t)
+	score := validMeta().Score()
+	assert.Equal(100.0, score.Total)
+	// series is only scored for episodes
+	for _, f := range score.Fields {
+		assert.NotEqual("series", f.Field)
+		assert.Equal(f.Weight, f.Score, f.Field)
+	}
+
+	meta := validMeta()
+	meta.OriginalLanguage = ""
+	meta.Genres = nil
+	meta.Duration = durationJSON("bad")
+	delete(meta.Description["nor"], DESCRIPTION_LONG)
+	score = meta.Score()
+	// 65 of 90 points, missing 5 + 10 + 5 and a quarter of 20
+	assert.Equal(72.2, score.Total)
+	reasons := map[string]string{}
+	for _, f := range score.Fields {
+		reasons[f.Field] = f.Reason
+	}
+	assert.Equal("missing", reasons["original_language"])
+	assert.Equal("missing", reasons["genres"])
+	assert.Equal("'bad' is not HH:MM:SS:FF, HH:MM:SS.mmm or an ISO 8601 duration", reasons["expected_duration"])
+	assert.Equal("3 of 4 description lengths", reasons["description"])
+	assert.Equal("", reasons["title"])
+
+	meta = validMeta()
+	meta.Type = TYPE_EPISODE
+	scorer := Scorer{Weights: map[string]float64{"title": 1, "series": 1}}
+	score = scorer.Score(meta)
+	assert.Equal(50.0, score.Total)
+	assert.Len(score.Fields, 2)
+	assert.Equal(0.0, MetaData{}.Score().Total)
+}
@@ -15,6 +15,9 @@ type SearchRequest struct {
 	Url         string
 	Token       string
 	RequestBody *SearchRequestBody
+	// Client is used to send the request, typically the api's GetClient(),
+	// defaults to http.DefaultClient
+	Client *http.Client
 }
 
 // SearchRequestBody is the expected request body for search route
@@ -36,7 +39,7 @@ type SearchResponse struct {
 	Page        int          `json:"page"`
 	NbHits      int          `json:"nbHits"`
 	NbPage      int          `json:"nbPages"`
-	HitsPerPage int          `json:"hitsPerPage`
+	HitsPerPage int          `json:"hitsPerPage"`
 	IdList      []string     `json:"idList"`
 	Hits        []synq.Asset `json:"hits"`
 }
@@ -51,7 +54,10 @@ func (r SearchRequest) Search() (searchResp SearchResponse, err error) {
 	}
 	req.Header.Add("Authorization", "Bearer "+r.Token)
 
-	httpClient := &http.Client{}
+	httpClient := r.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
 	rsp, err := httpClient.Do(req)
 	if err != nil {
 		log.Println("error with request")
@@ -47,7 +47,7 @@ func TestSearch(t *testing.T) {
 	assert := require.New(t)
 
 	reqBody := createRequestBody()
-	request := SearchRequest{"POST", testServer.URL, test_server.TEST_AUTH, &reqBody}
+	request := SearchRequest{"POST", testServer.URL, test_server.TEST_AUTH, &reqBody, nil}
 	resp, err := request.Search()
 	assert.Nil(err)
 	assert.Equal(1, resp.Page)
@@ -56,3 +56,21 @@ func TestSearch(t *testing.T) {
 	assert.Equal(100, resp.HitsPerPage)
 	assert.Equal(test_server.V2_VIDEO_ID, resp.IdList[0])
 }
+
+func TestSearchWithClient(t *testing.T) {
+	assert := require.New(t)
+	transport := &test_server.CountingTransport{}
+	api := synq.NewV2WithClient(test_server.TEST_AUTH, &http.Client{Transport: transport})
+	reqBody := createRequestBody()
+	request := SearchRequest{
+		Method:      "POST",
+		Url:         testServer.URL,
+		Token:       api.GetKey(),
+		RequestBody: &reqBody,
+		Client:      api.GetClient(),
+	}
+	resp, err := request.Search()
+	assert.Nil(err)
+	assert.Equal(1, resp.Page)
+	assert.Equal(1, transport.Count())
+}
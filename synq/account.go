@@ -0,0 +1,168 @@
+package synq
+
+import (
+	"context"
+)
+
+const (
+	ACCOUNT_STATUS_ACTIVE   = "active"
+	ACCOUNT_STATUS_INACTIVE = "inactive"
+)
+
+// AccountRequest is the body used to create or update an account, blank
+// fields are left unchanged on update
+type AccountRequest struct {
+	Name    string `json:"name,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+	Contact string `json:"contact_person,omitempty"`
+}
+
+type AccountList struct {
+	Accounts   []Account `json:"data"`
+	PageSize   int       `json:"page_size"`
+	PageNumber int       `json:"page_number"`
+}
+
+// AccountSettingRequest links a setting to an account, by id or by name
+type AccountSettingRequest struct {
+	SettingId string `json:"setting_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+type AccountSettingResp struct {
+	Setting AccountSetting `json:"data"`
+}
+
+type AccountSettingList struct {
+	Settings []AccountSetting `json:"data"`
+}
+
+type DistributorResp struct {
+	Distributor Distributor `json:"data"`
+}
+
+type DistributorList struct {
+	Distributors []Distributor `json:"data"`
+}
+
+func (a *ApiV2) accountUrl(id string, path ...string) string {
+	url := a.getBaseUrl() + "/accounts/" + id
+	for _, p := range path {
+		url = url + "/" + p
+	}
+	return url
+}
+
+func (a *ApiV2) CreateAccount(account AccountRequest) (Account, error) {
+	return a.CreateAccountCtx(context.Background(), account)
+}
+
+func (a *ApiV2) CreateAccountCtx(ctx context.Context, account AccountRequest) (Account, error) {
+	var resp AccountResp
+	err := a.handleJsonCtx(ctx, "POST", a.getBaseUrl()+"/accounts", account, &resp)
+	return resp.Account, err
+}
+
+// GetAccountList returns every account, loading all of the pages
+func (a *ApiV2) GetAccountList() ([]Account, error) {
+	return a.GetAccountListCtx(context.Background())
+}
+
+func (a *ApiV2) GetAccountListCtx(ctx context.Context) (accounts []Account, err error) {
+	baseUrl := a.getBaseUrl() + "/accounts"
+	for pageNumber := 1; pageNumber <= a.maxPages(); pageNumber++ {
+		if err := ctx.Err(); err != nil {
+			return accounts, err
+		}
+		var list AccountList
+		err = a.handleGetCtx(ctx, pageUrl(baseUrl, nil, pageNumber, a.PageSize), &list)
+		if err != nil {
+			return accounts, err
+		}
+		accounts = append(accounts, list.Accounts...)
+		if lastPage(len(list.Accounts), list.PageSize) {
+			return accounts, nil
+		}
+	}
+	return accounts, a.tooManyPages(baseUrl)
+}
+
+func (a *ApiV2) UpdateAccount(id string, account AccountRequest) (Account, error) {
+	return a.UpdateAccountCtx(context.Background(), id, account)
+}
+
+func (a *ApiV2) UpdateAccountCtx(ctx context.Context, id string, account AccountRequest) (Account, error) {
+	var resp AccountResp
+	err := a.handleJsonCtx(ctx, "PUT", a.accountUrl(id), account, &resp)
+	return resp.Account, err
+}
+
+// DeactivateAccount sets the account status to inactive, use UpdateAccount
+// to activate it again
+func (a *ApiV2) DeactivateAccount(id string) (Account, error) {
+	return a.DeactivateAccountCtx(context.Background(), id)
+}
+
+func (a *ApiV2) DeactivateAccountCtx(ctx context.Context, id string) (Account, error) {
+	return a.UpdateAccountCtx(ctx, id, AccountRequest{Status: ACCOUNT_STATUS_INACTIVE})
+}
+
+func (a *ApiV2) GetAccountSettings(id string) ([]AccountSetting, error) {
+	return a.GetAccountSettingsCtx(context.Background(), id)
+}
+
+func (a *ApiV2) GetAccountSettingsCtx(ctx context.Context, id string) ([]AccountSetting, error) {
+	var list AccountSettingList
+	err := a.handleGetCtx(ctx, a.accountUrl(id, "settings"), &list)
+	return list.Settings, err
+}
+
+func (a *ApiV2) AddAccountSetting(id string, setting AccountSettingRequest) (AccountSetting, error) {
+	return a.AddAccountSettingCtx(context.Background(), id, setting)
+}
+
+func (a *ApiV2) AddAccountSettingCtx(ctx context.Context, id string, setting AccountSettingRequest) (AccountSetting, error) {
+	var resp AccountSettingResp
+	err := a.handleJsonCtx(ctx, "POST", a.accountUrl(id, "settings"), setting, &resp)
+	return resp.Setting, err
+}
+
+func (a *ApiV2) RemoveAccountSetting(id string, settingId string) error {
+	return a.RemoveAccountSettingCtx(context.Background(), id, settingId)
+}
+
+func (a *ApiV2) RemoveAccountSettingCtx(ctx context.Context, id string, settingId string) error {
+	return a.handleJsonCtx(ctx, "DELETE", a.accountUrl(id, "settings", settingId), nil, nil)
+}
+
+func (a *ApiV2) GetDistributors(id string) ([]Distributor, error) {
+	return a.GetDistributorsCtx(context.Background(), id)
+}
+
+func (a *ApiV2) GetDistributorsCtx(ctx context.Context, id string) ([]Distributor, error) {
+	var list DistributorList
+	err := a.handleGetCtx(ctx, a.accountUrl(id, "distributors"), &list)
+	return list.Distributors, err
+}
+
+// AddDistributor lets distributorId distribute the videos of account id
+func (a *ApiV2) AddDistributor(id string, distributorId string) (Distributor, error) {
+	return a.AddDistributorCtx(context.Background(), id, distributorId)
+}
+
+func (a *ApiV2) AddDistributorCtx(ctx context.Context, id string, distributorId string) (Distributor, error) {
+	var resp DistributorResp
+	body := Distributor{DistributorId: distributorId}
+	err := a.handleJsonCtx(ctx, "POST", a.accountUrl(id, "distributors"), body, &resp)
+	return resp.Distributor, err
+}
+
+func (a *ApiV2) RemoveDistributor(id string, distributorId string) error {
+	return a.RemoveDistributorCtx(context.Background(), id, distributorId)
+}
+
+func (a *ApiV2) RemoveDistributorCtx(ctx context.Context, id string, distributorId string) error {
+	return a.handleJsonCtx(ctx, "DELETE", a.accountUrl(id, "distributors", distributorId), nil, nil)
+}
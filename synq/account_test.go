@@ -0,0 +1,113 @@
+package synq
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAccount(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	account, err := api.CreateAccount(AccountRequest{Name: "New Account", Type: "licensor", Domain: "new.fm"})
+	assert.Nil(err)
+	assert.Equal(test_server.NEW_ACCOUNT_ID, account.Id)
+	assert.Equal("New Account", account.Name)
+	assert.Equal("licensor", account.Type)
+	assert.Equal(ACCOUNT_STATUS_ACTIVE, account.Status)
+	reqs, values := testServer.GetReqs()
+	assert.Equal("POST", reqs[0].Method)
+	body := map[string]string{}
+	json.Unmarshal([]byte(values[0].Get("body")), &body)
+	assert.Equal(map[string]string{"name": "New Account", "type": "licensor", "domain": "new.fm"}, body)
+}
+
+func TestGetAccountList(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	accounts, err := api.GetAccountList()
+	assert.Nil(err)
+	assert.Len(accounts, 2)
+	assert.Equal(test_server.ACCOUNT_ID, accounts[0].Id)
+	assert.Equal(test_server.DISTRIBUTOR_ID, accounts[1].Id)
+	// a page shorter than the page size is the last one
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 1)
+
+	// a full page is followed by an empty one
+	testServer.Reset()
+	api.PageSize = 2
+	accounts, err = api.GetAccountList()
+	assert.Nil(err)
+	assert.Len(accounts, 2)
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 2)
+	assert.Equal("2", reqs[1].URL.Query().Get("page_number"))
+
+	// lists that do not end give up after MaxPages
+	testServer.Reset()
+	api.MaxPages = 1
+	_, err = api.GetAccountList()
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "returned more than 1 pages")
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 1)
+}
+
+func TestUpdateAccount(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	account, err := api.UpdateAccount(test_server.ACCOUNT_ID, AccountRequest{Contact: "Someone"})
+	assert.Nil(err)
+	assert.Equal("Someone", account.Contact)
+	assert.Equal("SYNQ Test", account.Name)
+	account, err = api.DeactivateAccount(test_server.ACCOUNT_ID)
+	assert.Nil(err)
+	assert.Equal(ACCOUNT_STATUS_INACTIVE, account.Status)
+	_, values := testServer.GetReqs()
+	assert.Equal(`{"status":"inactive"}`, values[1].Get("body"))
+	_, err = api.DeactivateAccount(test_server.NEW_ACCOUNT_ID)
+	assert.True(errors.Is(err, ErrNotFound))
+}
+
+func TestAccountSettings(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	settings, err := api.GetAccountSettings(test_server.ACCOUNT_ID)
+	assert.Nil(err)
+	assert.Len(settings, 1)
+	assert.Equal(test_server.SETTING_ID, settings[0].Id)
+	setting, err := api.AddAccountSetting(test_server.ACCOUNT_ID, AccountSettingRequest{Name: testSettingsName})
+	assert.Nil(err)
+	assert.Equal(test_server.SETTING_ID, setting.Id)
+	assert.Equal(testSettingsName, setting.Name)
+	_, err = api.AddAccountSetting(test_server.ACCOUNT_ID, AccountSettingRequest{Name: "missing"})
+	assert.True(errors.Is(err, ErrNotFound))
+	assert.Nil(api.RemoveAccountSetting(test_server.ACCOUNT_ID, test_server.SETTING_ID))
+	assert.True(errors.Is(api.RemoveAccountSetting(test_server.ACCOUNT_ID, "missing"), ErrNotFound))
+}
+
+func TestAccountDistributors(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	distributors, err := api.GetDistributors(test_server.ACCOUNT_ID)
+	assert.Nil(err)
+	assert.Len(distributors, 1)
+	assert.Equal(test_server.DISTRIBUTOR_ID, distributors[0].DistributorId)
+	distributor, err := api.AddDistributor(test_server.ACCOUNT_ID, test_server.NEW_ACCOUNT_ID)
+	assert.Nil(err)
+	assert.Equal(test_server.NEW_ACCOUNT_ID, distributor.DistributorId)
+	assert.Nil(api.RemoveDistributor(test_server.ACCOUNT_ID, test_server.DISTRIBUTOR_ID))
+	err = api.RemoveDistributor(test_server.ACCOUNT_ID, test_server.NEW_ACCOUNT_ID)
+	assert.True(errors.Is(err, ErrNotFound))
+	reqs, _ := testServer.GetReqs()
+	assert.Equal("DELETE", reqs[len(reqs)-1].Method)
+}
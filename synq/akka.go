@@ -0,0 +1,55 @@
+package synq
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/SYNQfm/SYNQ-Golang/metadata"
+)
+
+const (
+	// IMAGE_ASSET_TYPE is the type of the assets ApplyAkkaXML creates for images
+	IMAGE_ASSET_TYPE = "image"
+)
+
+// ApplyAkkaXML merges the asset parsed from a partner XML file (with
+// metadata.ParseAkkaXML) into the video's metadata, saves the video and
+// creates (or updates) an image asset for every image in it. Fields that are
+// not in the XML keep their current value.
+func (v *VideoV2) ApplyAkkaXML(asset metadata.AkkaXMLAsset) error {
+	return v.ApplyAkkaXMLCtx(context.Background(), asset)
+}
+
+func (v *VideoV2) ApplyAkkaXMLCtx(ctx context.Context, asset metadata.AkkaXMLAsset) error {
+	images := asset.Images
+	asset.Images = nil
+	data, err := json.Marshal(asset)
+	if err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	json.Unmarshal(data, &fields)
+	for key, value := range fields {
+		if isEmptyJSON(value) {
+			delete(fields, key)
+		}
+	}
+	patch, _ := json.Marshal(fields)
+	if v.Metadata, err = ApplyPatch(v.Metadata, patch); err != nil {
+		return err
+	}
+	if err = v.UpdateCtx(ctx); err != nil {
+		return err
+	}
+	for _, image := range images {
+		if image.File == "" {
+			continue
+		}
+		meta, _ := json.Marshal(image)
+		a := Asset{VideoId: v.Id, Type: IMAGE_ASSET_TYPE, State: "created", Location: image.File, Metadata: meta}
+		if err = v.CreateOrUpdateAssetCtx(ctx, &a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
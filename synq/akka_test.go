@@ -0,0 +1,52 @@
+package synq
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/SYNQfm/SYNQ-Golang/metadata"
+	"github.com/buger/jsonparser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyAkkaXML(t *testing.T) {
+	assert := require.New(t)
+	f, err := os.Open(DEFAULT_SAMPLE_DIR + "/akka.xml")
+	assert.Nil(err)
+	defer f.Close()
+	asset, err := metadata.ParseAkkaXML(f)
+	assert.Nil(err)
+
+	video := setupTestVideoV2()
+	defer testServer.Close()
+	video.Metadata = json.RawMessage(`{"studio":"kept","title":{"eng":{"content":"English title"}}}`)
+	assert.Nil(video.ApplyAkkaXML(asset))
+	reqs, values := testServer.GetReqs()
+	assert.Len(reqs, 3)
+	assert.Equal("PUT", reqs[0].Method)
+	body := []byte(values[0].Get("body"))
+	sent, _, _, _ := jsonparser.Get(body, "metadata")
+	studio, _ := jsonparser.GetString(sent, "studio")
+	assert.Equal("kept", studio)
+	title, _ := jsonparser.GetString(sent, "title", "eng", "content")
+	assert.Equal("English title", title)
+	title, _ = jsonparser.GetString(sent, "title", "nor", "content")
+	assert.Equal("Tårer av stål", title)
+	id, _ := jsonparser.GetString(sent, "content_id")
+	assert.Equal("TOS-2012", id)
+	_, _, _, err = jsonparser.Get(sent, "images")
+	assert.NotNil(err)
+	_, _, _, err = jsonparser.Get(sent, "other_information", "distributor")
+	assert.Nil(err)
+
+	for i, file := range []string{"tos_poster_nor.jpg", "tos_still.jpg"} {
+		assert.Equal("POST", reqs[i+1].Method)
+		created := Asset{}
+		json.Unmarshal([]byte(values[i+1].Get("body")), &created)
+		assert.Equal(IMAGE_ASSET_TYPE, created.Type)
+		assert.Equal(file, created.Location)
+		org, _ := jsonparser.GetString(created.Metadata, "org_file")
+		assert.Equal(file, org)
+	}
+}
@@ -2,6 +2,7 @@ package synq
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,17 +21,26 @@ const (
 	DEFAULT_V2_URL       = "https://b9n2fsyd6jbfihx82.stoplight-proxy.io"
 	DEFAULT_UPLOADER_URL = "https://s6krcbatzuuhmspse.stoplight-proxy.io"
 	DEFAULT_PAGE_SIZE    = 100
+	DEFAULT_MAX_PAGES    = 10000
 	SYNQ_VERSION         = "v2"
 	SYNQ_ROUTE           = "v1"
 )
 
 type ApiV2 struct {
 	*BaseApi
-	User        string
-	Password    string
-	UploadUrl   string
+	// User and Password are used to log in again when the token expires
+	User      string
+	Password  string
+	UploadUrl string
+	// TokenExpiry is the expiry of the token the api was created or logged in
+	// with, use GetTokenExpiry once the token may have been refreshed
 	TokenExpiry time.Time
 	PageSize    int
+	// MaxPages is the most pages a list loads before giving up, in case the
+	// server keeps returning full pages (DEFAULT_MAX_PAGES if 0)
+	MaxPages int
+	// auth is made by NewV2, or on first use for an ApiV2 struct literal
+	auth *tokenState
 }
 
 type AccountResp struct {
@@ -56,11 +66,12 @@ type SettingsResp struct {
 }
 
 type Settings struct {
-	Id        string `json:"id"`
-	Name      string `json:"name"`
-	Type      string `json:"type"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	Id        string          `json:"id"`
+	Name      string          `json:"name"`
+	Type      string          `json:"type"`
+	Config    json.RawMessage `json:"config,omitempty"`
+	CreatedAt string          `json:"created_at"`
+	UpdatedAt string          `json:"updated_at"`
 }
 
 type AccountSetting struct {
@@ -95,33 +106,37 @@ func (a ApiV2) Version() string {
 }
 
 func NewV2(token string, timeouts ...time.Duration) ApiV2 {
-	base := NewBase(token, timeouts...)
+	return NewV2WithClient(token, &http.Client{}, timeouts...)
+}
+
+// NewV2WithClient creates an api that uses client for every request it makes,
+// including uploads and logins
+func NewV2WithClient(token string, client *http.Client, timeouts ...time.Duration) ApiV2 {
+	base := NewBaseWithClient(token, client, timeouts...)
 	base.SetUrl(DEFAULT_V2_URL)
-	api := ApiV2{BaseApi: &base}
+	api := ApiV2{BaseApi: &base, auth: &tokenState{}}
 	api.PageSize = DEFAULT_PAGE_SIZE
+	api.TokenExpiry, _ = tokenExpiry(token)
+	api.auth.expiry = api.TokenExpiry
 	return api
 }
 
 func (a *ApiV2) handleAuth(req *http.Request) {
-	if strings.HasPrefix(a.GetKey(), "Bearer ") {
-		req.Header.Add("Authorization", a.GetKey())
-	} else {
-		req.Header.Add("Authorization", "Bearer "+a.GetKey())
-	}
+	req.Header.Add("Authorization", bearer(a.GetKey()))
 }
 
 func (a ApiV2) getBaseUrl() string {
 	return a.GetUrl() + "/" + SYNQ_ROUTE
 }
 
-func (a *ApiV2) CreateAccount(name string, type_ string) string {
-	return ""
+func (a *ApiV2) GetAccount(id string) (account Account, err error) {
+	return a.GetAccountCtx(context.Background(), id)
 }
 
-func (a *ApiV2) GetAccount(id string) (account Account, err error) {
+func (a *ApiV2) GetAccountCtx(ctx context.Context, id string) (account Account, err error) {
 	var resp AccountResp
 	url := a.getBaseUrl() + "/accounts/" + id
-	req, err := a.makeRequest("GET", url, nil)
+	req, err := a.makeRequestCtx(ctx, "GET", url, nil)
 	if err != nil {
 		return account, err
 	}
@@ -133,10 +148,22 @@ func (a *ApiV2) GetAccount(id string) (account Account, err error) {
 }
 
 func (a *ApiV2) makeRequest(method string, url string, body io.Reader) (req *http.Request, err error) {
+	return a.makeRequestCtx(context.Background(), method, url, body)
+}
+
+// makeRequestCtx creates the request bound to ctx, so cancelling ctx aborts
+// the call while it is in flight. If the token is about to expire, it is
+// refreshed first.
+func (a *ApiV2) makeRequestCtx(ctx context.Context, method string, url string, body io.Reader) (req *http.Request, err error) {
+	a.initAuth()
+	if err = a.refreshIfExpiring(ctx); err != nil {
+		return req, err
+	}
 	req, err = http.NewRequest(method, url, body)
 	if err != nil {
 		return req, err
 	}
+	req = req.WithContext(ctx)
 	if method == "POST" || method == "PUT" {
 		if strings.Contains(url, "/login") {
 			req.Header.Add("content-type", "application/x-www-form-urlencoded")
@@ -148,66 +175,123 @@ func (a *ApiV2) makeRequest(method string, url string, body io.Reader) (req *htt
 	return req, nil
 }
 
+// ParseError turns an error response into an *APIError, use errors.Is with
+// ErrNotFound, ErrUnauthorized, ErrConflict or ErrRateLimited to check it
 func (a ApiV2) ParseError(status int, bytes []byte) error {
+	apiErr := &APIError{StatusCode: status, Body: bytes}
 	if status == 404 {
-		return errors.New("404 Item not found")
+		apiErr.Message = "404 Item not found"
+		return apiErr
 	}
-	type Resp struct {
-		Message string `json:"message"`
-	}
-	resp := Resp{}
+	resp := ErrorRespV2{}
 	err := json.Unmarshal(bytes, &resp)
 	if err != nil {
-		return common.NewError("could not parse error %d : %s", status, string(bytes))
+		apiErr.Message = fmt.Sprintf("could not parse error %d : %s", status, string(bytes))
+		return apiErr
 	}
-	msg := resp.Message
-	if msg == "" {
-		msg = fmt.Sprintf("Failed with status %d", status)
+	apiErr.Message = resp.Message
+	if apiErr.Message == "" {
+		apiErr.Message = fmt.Sprintf("Failed with status %d", status)
 	}
-	return errors.New(msg)
+	return apiErr
 }
 
 func (a *ApiV2) handleGet(url string, v interface{}) error {
+	return a.handleGetCtx(context.Background(), url, v)
+}
+
+func (a *ApiV2) handleGetCtx(ctx context.Context, url string, v interface{}) error {
 	body := bytes.NewBufferString("")
-	req, err := a.makeRequest("GET", url, body)
+	req, err := a.makeRequestCtx(ctx, "GET", url, body)
 	if err != nil {
 		return err
 	}
 	return handleReq(a, req, v)
 }
 
-func Login(user, password string, serverUrl ...string) (ApiV2, error) {
-	var api ApiV2
-	resp, err := login(user, password, serverUrl...)
+// handleJsonCtx sends body (if it is not nil) as json and parses the response into v
+func (a *ApiV2) handleJsonCtx(ctx context.Context, method string, url string, body interface{}, v interface{}) error {
+	buf := bytes.NewBufferString("")
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	req, err := a.makeRequestCtx(ctx, method, url, buf)
 	if err != nil {
-		return api, err
+		return err
 	}
-	api = NewV2(resp.Token)
-	api.TokenExpiry = resp.TokenExpiry
-	api.User = user
-	api.Password = password
+	return handleReq(a, req, v)
+}
+
+func Login(user, password string, serverUrl ...string) (ApiV2, error) {
+	return LoginCtx(context.Background(), user, password, serverUrl...)
+}
+
+func LoginCtx(ctx context.Context, user, password string, serverUrl ...string) (ApiV2, error) {
+	return LoginWithClientCtx(ctx, &http.Client{}, user, password, serverUrl...)
+}
+
+// LoginWithClient logs in like Login, the returned api uses client for every
+// request it makes, including the login
+func LoginWithClient(client *http.Client, user, password string, serverUrl ...string) (ApiV2, error) {
+	return LoginWithClientCtx(context.Background(), client, user, password, serverUrl...)
+}
+
+func LoginWithClientCtx(ctx context.Context, client *http.Client, user, password string, serverUrl ...string) (ApiV2, error) {
+	api := NewV2WithClient("", client)
 	if len(serverUrl) > 0 {
 		api.SetUrl(serverUrl[0])
 	}
+	err := api.LoginCtx(ctx, user, password)
+	if err != nil {
+		return ApiV2{}, err
+	}
 	return api, nil
 }
 
-func login(user, password string, serverUrl ...string) (LoginResp, error) {
-	var r LoginResp
-	var u string
-	if len(serverUrl) > 0 {
-		u = serverUrl[0]
-	} else {
-		u = DEFAULT_V2_URL
+// Login will log in to the api's url with its client and use the returned token
+func (a *ApiV2) Login(user, password string) error {
+	return a.LoginCtx(context.Background(), user, password)
+}
+
+func (a *ApiV2) LoginCtx(ctx context.Context, user, password string) error {
+	a.initAuth()
+	resp, err := a.login(ctx, user, password)
+	if err != nil {
+		return err
 	}
-	u = u + "/" + SYNQ_ROUTE + "/login"
+	if resp.TokenExpiry.IsZero() {
+		resp.TokenExpiry, _ = tokenExpiry(resp.Token)
+	}
+	a.setToken(resp.Token, resp.TokenExpiry)
+	a.TokenExpiry = resp.TokenExpiry
+	a.User = user
+	a.Password = password
+	return nil
+}
+
+func (a *ApiV2) login(ctx context.Context, user, password string) (LoginResp, error) {
+	var r LoginResp
+	u := a.GetUrl() + "/" + SYNQ_ROUTE + "/login"
 	form := url.Values{}
 	form.Add("email", user)
 	form.Add("password", password)
-	resp, e := http.PostForm(u, form)
+	// login must not send a (possibly expired) token, so this does not use makeRequest
+	req, e := http.NewRequest("POST", u, strings.NewReader(form.Encode()))
+	if e != nil {
+		return r, e
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("content-type", "application/x-www-form-urlencoded")
+	resp, cancel, e := sendReq(a, "", req)
+	defer cancel()
 	if e != nil {
 		return r, e
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		return r, common.NewError("error getting login %d", resp.StatusCode)
 	}
@@ -217,6 +301,10 @@ func login(user, password string, serverUrl ...string) (LoginResp, error) {
 }
 
 func (a *ApiV2) Create(body ...[]byte) (VideoV2, error) {
+	return a.CreateCtx(context.Background(), body...)
+}
+
+func (a *ApiV2) CreateCtx(ctx context.Context, body ...[]byte) (VideoV2, error) {
 	resp := VideoResp{}
 	video := VideoV2{}
 	url := a.getBaseUrl() + "/videos"
@@ -224,7 +312,7 @@ func (a *ApiV2) Create(body ...[]byte) (VideoV2, error) {
 	if len(body) > 0 {
 		buf.Write(body[0])
 	}
-	req, err := a.makeRequest("POST", url, buf)
+	req, err := a.makeRequestCtx(ctx, "POST", url, buf)
 	if err != nil {
 		return video, err
 	}
@@ -238,39 +326,28 @@ func (a *ApiV2) Create(body ...[]byte) (VideoV2, error) {
 }
 
 // This will return the "raw" json, using pagination, and the calling function is expected
-// to turn it into whats needed
-func (a *ApiV2) getVideos(accountId string) (videos []json.RawMessage, err error) {
-	path := "/videos"
-	if accountId != "" {
-		path = "/accounts/" + accountId + path
-	}
-	// iterate through the requests until we get no more videos
-	base_url := a.getBaseUrl() + path
-	pageNumber := 1
-	pageSize := a.PageSize
-	for {
-		var obj VideoList
-		url := base_url + fmt.Sprintf("?page_number=%d&page_size=%d", pageNumber, pageSize)
-		req, err := a.makeRequest("GET", url, nil)
-		if err != nil {
-			return videos, err
-		}
-		err = handleReq(a, req, &obj)
-		if err != nil {
-			return videos, err
-		}
-		if len(obj.Videos) == 0 {
-			return videos, nil
-		}
-		videos = append(videos, obj.Videos...)
-		pageNumber++
+// to turn it into whats needed. Use Videos to iterate without loading everything.
+func (a *ApiV2) getVideos(ctx context.Context, accountId string, query ...VideoQuery) (videos []json.RawMessage, err error) {
+	var opts IteratorOptions
+	if len(query) > 0 {
+		opts.Query = query[0]
 	}
-	return videos, nil
+	it := a.VideosCtx(ctx, accountId, opts)
+	defer it.Close()
+	for it.Next() {
+		videos = append(videos, it.Raw())
+	}
+	return videos, it.Err()
 }
 
-func (a *ApiV2) GetVideos(accountId string) ([]VideoV2, error) {
+// GetVideos returns all videos (of accountId if it is set) that match the query
+func (a *ApiV2) GetVideos(accountId string, query ...VideoQuery) ([]VideoV2, error) {
+	return a.GetVideosCtx(context.Background(), accountId, query...)
+}
+
+func (a *ApiV2) GetVideosCtx(ctx context.Context, accountId string, query ...VideoQuery) ([]VideoV2, error) {
 	var videos []VideoV2
-	raw, err := a.getVideos(accountId)
+	raw, err := a.getVideos(ctx, accountId, query...)
 	if err != nil {
 		return videos, err
 	}
@@ -283,8 +360,12 @@ func (a *ApiV2) GetVideos(accountId string) ([]VideoV2, error) {
 	return videos, nil
 }
 
-func (a *ApiV2) GetRawVideos(accountId string) ([]json.RawMessage, error) {
-	return a.getVideos(accountId)
+func (a *ApiV2) GetRawVideos(accountId string, query ...VideoQuery) ([]json.RawMessage, error) {
+	return a.GetRawVideosCtx(context.Background(), accountId, query...)
+}
+
+func (a *ApiV2) GetRawVideosCtx(ctx context.Context, accountId string, query ...VideoQuery) ([]json.RawMessage, error) {
+	return a.getVideos(ctx, accountId, query...)
 }
 
 // this sets the api object properly on the Video object and the assets
@@ -301,44 +382,54 @@ func (a *ApiV2) SetApi(video *VideoV2) {
 
 // Helper function to get details for a video, will create video object
 func (a *ApiV2) GetVideo(id string) (video VideoV2, err error) {
+	return a.GetVideoCtx(context.Background(), id)
+}
+
+func (a *ApiV2) GetVideoCtx(ctx context.Context, id string) (video VideoV2, err error) {
 	var resp VideoResp
 	if !common.ValidUUID(id) {
 		return video, common.NewError("video id '%s' is invalid", id)
 	}
 	uuid := common.ConvertToUUIDFormat(id)
 	url := a.getBaseUrl() + "/videos/" + uuid
-	req, err := a.makeRequest("GET", url, nil)
+	req, err := a.makeRequestCtx(ctx, "GET", url, nil)
 	if err != nil {
 		return video, err
 	}
-	err = handleReq(a, req, &resp)
+	header, err := handleReqHeader(a, req, &resp)
 	if err != nil {
 		return video, err
 	}
 	video = resp.Video
+	video.ETag = header.Get("ETag")
 	a.SetApi(&video)
 	return video, nil
 }
 
 // Helper function to get an Asset
 func (a *ApiV2) GetAsset(id string) (asset Asset, err error) {
+	return a.GetAssetCtx(context.Background(), id)
+}
+
+func (a *ApiV2) GetAssetCtx(ctx context.Context, id string) (asset Asset, err error) {
 	var resp AssetResponse
 	if !common.ValidUUID(id) {
 		return asset, common.NewError("asset id '%s' is invalid", id)
 	}
 	uuid := common.ConvertToUUIDFormat(id)
 	url := a.getBaseUrl() + "/assets/" + uuid
-	req, err := a.makeRequest("GET", url, nil)
+	req, err := a.makeRequestCtx(ctx, "GET", url, nil)
 	if err != nil {
 		return asset, err
 	}
-	err = handleReq(a, req, &resp)
+	header, err := handleReqHeader(a, req, &resp)
 	if err != nil {
 		return asset, err
 	}
 	asset = *resp.Asset
+	asset.ETag = header.Get("ETag")
 	// now get the video
-	video, err := a.GetVideo(asset.VideoId)
+	video, err := a.GetVideoCtx(ctx, asset.VideoId)
 	if err != nil {
 		return asset, err
 	}
@@ -347,14 +438,41 @@ func (a *ApiV2) GetAsset(id string) (asset Asset, err error) {
 	return asset, nil
 }
 
-func (a *ApiV2) GetAssetList() ([]Asset, error) {
-	list := AssetList{}
-	url := a.getBaseUrl() + "/assets"
-	err := a.handleGet(url, &list)
-	return list.Assets, err
+// GetAssetList returns all assets that match the query, loading every page
+func (a *ApiV2) GetAssetList(query ...AssetQuery) ([]Asset, error) {
+	return a.GetAssetListCtx(context.Background(), query...)
+}
+
+func (a *ApiV2) GetAssetListCtx(ctx context.Context, query ...AssetQuery) (assets []Asset, err error) {
+	var q AssetQuery
+	if len(query) > 0 {
+		q = query[0]
+	}
+	values := q.Values()
+	baseUrl := a.getBaseUrl() + "/assets"
+	// iterate through the requests until we get the last page
+	for pageNumber := 1; pageNumber <= a.maxPages(); pageNumber++ {
+		if err := ctx.Err(); err != nil {
+			return assets, err
+		}
+		list := AssetList{}
+		err = a.handleGetCtx(ctx, pageUrl(baseUrl, values, pageNumber, a.PageSize), &list)
+		if err != nil {
+			return assets, err
+		}
+		assets = append(assets, list.Assets...)
+		if lastPage(len(list.Assets), list.PageSize) {
+			return assets, nil
+		}
+	}
+	return assets, a.tooManyPages(baseUrl)
 }
 
 func (a *ApiV2) GetUploadParams(vid string, params upload.UploadRequest) (up upload.UploadParameters, err error) {
+	return a.GetUploadParamsCtx(context.Background(), vid, params)
+}
+
+func (a *ApiV2) GetUploadParamsCtx(ctx context.Context, vid string, params upload.UploadRequest) (up upload.UploadParameters, err error) {
 	if a.UploadUrl == "" {
 		return up, errors.New("UploadUrl is blank")
 	}
@@ -362,7 +480,7 @@ func (a *ApiV2) GetUploadParams(vid string, params upload.UploadRequest) (up upl
 	data, _ := json.Marshal(params)
 	body := bytes.NewBuffer(data)
 
-	req, err := a.makeRequest("POST", url, body)
+	req, err := a.makeRequestCtx(ctx, "POST", url, body)
 	if err != nil {
 		return up, err
 	}
@@ -371,13 +489,23 @@ func (a *ApiV2) GetUploadParams(vid string, params upload.UploadRequest) (up upl
 }
 
 func (a *ApiV2) UpdateAssetMetadata(id string, metadata json.RawMessage) (asset Asset, err error) {
+	return a.UpdateAssetMetadataCtx(context.Background(), id, metadata)
+}
+
+func (a *ApiV2) UpdateAssetMetadataCtx(ctx context.Context, id string, metadata json.RawMessage) (asset Asset, err error) {
 	var resp AssetResponse
 	if !common.ValidUUID(id) {
 		return asset, common.NewError("asset id '%s' is invalid", id)
 	}
 	uuid := common.ConvertToUUIDFormat(id)
 	url := a.getBaseUrl() + "/assets/" + uuid
-	req, err := a.makeRequest("PUT", url, strings.NewReader("{\"metadata\": "+string(metadata)+"}"))
+	data, err := json.Marshal(struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}{metadata})
+	if err != nil {
+		return asset, err
+	}
+	req, err := a.makeRequestCtx(ctx, "PUT", url, bytes.NewBuffer(data))
 	if err != nil {
 		return asset, err
 	}
@@ -391,10 +519,14 @@ func (a *ApiV2) UpdateAssetMetadata(id string, metadata json.RawMessage) (asset
 }
 
 func (a *ApiV2) CreateAssetSettings(assetId string, settingIds []string) error {
+	return a.CreateAssetSettingsCtx(context.Background(), assetId, settingIds)
+}
+
+func (a *ApiV2) CreateAssetSettingsCtx(ctx context.Context, assetId string, settingIds []string) error {
 	url := fmt.Sprintf("%s/assets/%s/settings", a.getBaseUrl(), assetId)
 	data, _ := json.Marshal(map[string][]string{"settings_ids": settingIds})
 	body := bytes.NewBuffer(data)
-	req, err := a.makeRequest("POST", url, body)
+	req, err := a.makeRequestCtx(ctx, "POST", url, body)
 	if err != nil {
 		return err
 	}
@@ -402,9 +534,13 @@ func (a *ApiV2) CreateAssetSettings(assetId string, settingIds []string) error {
 }
 
 func (a *ApiV2) GetSettingsByName(settingsName string) (settings Settings, err error) {
+	return a.GetSettingsByNameCtx(context.Background(), settingsName)
+}
+
+func (a *ApiV2) GetSettingsByNameCtx(ctx context.Context, settingsName string) (settings Settings, err error) {
 	var resp SettingsResp
 	url := fmt.Sprintf("%s/settings?name=%s", a.getBaseUrl(), settingsName)
-	req, err := a.makeRequest("GET", url, nil)
+	req, err := a.makeRequestCtx(ctx, "GET", url, nil)
 	if err != nil {
 		return settings, err
 	}
@@ -1,7 +1,9 @@
 package synq
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -148,3 +150,52 @@ func TestGetSettingsByName(t *testing.T) {
 	assert.Equal(testSettingsName, settings.Name)
 	assert.NotNil(settings.Type)
 }
+
+func TestGetVideosCtx(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := api.GetVideosCtx(ctx, "")
+	assert.Equal(context.Canceled, err)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 0)
+	_, err = api.GetVideoCtx(ctx, testVideoIdV2)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "context canceled")
+	videos, err := api.GetVideosCtx(context.Background(), "")
+	assert.Nil(err)
+	assert.Len(videos, 2)
+}
+
+func TestSharedClient(t *testing.T) {
+	assert := require.New(t)
+	transport := &test_server.CountingTransport{}
+	api := NewV2WithClient("", &http.Client{Transport: transport})
+	server := test_server.SetupServer(SYNQ_VERSION, DEFAULT_SAMPLE_DIR)
+	defer server.Close()
+	api.SetUrl(server.GetUrl())
+	assert.NotNil(api.Login("fake", "fake"))
+	assert.Nil(api.Login("user", "pass"))
+	assert.Equal(test_server.TEST_AUTH, api.GetKey())
+	assert.Equal("user", api.User)
+	_, err := api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(3, transport.Count())
+}
+
+func TestLoginWithClient(t *testing.T) {
+	assert := require.New(t)
+	transport := &test_server.CountingTransport{}
+	server := test_server.SetupServer(SYNQ_VERSION, DEFAULT_SAMPLE_DIR)
+	defer server.Close()
+	client := &http.Client{Transport: transport}
+	_, err := LoginWithClient(client, "fake", "fake", server.GetUrl())
+	assert.NotNil(err)
+	api, err := LoginWithClient(client, "user", "pass", server.GetUrl())
+	assert.Nil(err)
+	assert.Equal(client, api.Client)
+	_, err = api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(3, transport.Count())
+}
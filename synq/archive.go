@@ -0,0 +1,221 @@
+package synq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	// SOFT_DELETE_KEY is the user_data field UserDataArchive marks deleted videos with
+	SOFT_DELETE_KEY = "synq_deleted_at"
+)
+
+// DeleteOptions controls how VideoV2.Delete removes a video
+type DeleteOptions struct {
+	// Cascade deletes all of the video's assets before the video
+	Cascade bool
+	// Archive keeps a copy of the video (and its assets) that can be brought
+	// back with ApiV2.RestoreVideo
+	Archive VideoArchive
+}
+
+// VideoArchive stores deleted videos so they can be restored
+type VideoArchive interface {
+	// Archive stores the video, it returns true if the video was soft deleted
+	// in place and must be left on the server
+	Archive(ctx context.Context, video *VideoV2) (bool, error)
+	// Restore brings back the archived video with id, the video it returns
+	// may have a new id
+	Restore(ctx context.Context, api *ApiV2, id string) (VideoV2, error)
+}
+
+// ArchiveRecord is what DirArchive writes for every deleted video
+type ArchiveRecord struct {
+	Video      VideoV2   `json:"video"`
+	ArchivedAt time.Time `json:"archived_at"`
+	// RestoredId is the id of the video a restore created, it is set before
+	// the assets are restored
+	RestoredId string `json:"restored_id,omitempty"`
+}
+
+// DirArchive saves each deleted video (with its assets) as <id>.json in Dir.
+// The api can not create a video or asset with a given id, so restoring
+// creates a new video and new copies of its deleted assets, anything that
+// stored the old ids has to be updated. Assets that are still on the server
+// (the video was deleted without Cascade) are moved to the new video.
+//
+// The new video's id is saved in the record before its assets are restored,
+// so a restore that failed part way can be retried without creating another
+// video. Asset copies made by the failed attempt are found by location and
+// updated.
+type DirArchive struct {
+	Dir string
+}
+
+func (d DirArchive) file(id string) string {
+	return filepath.Join(d.Dir, id+".json")
+}
+
+func (d DirArchive) Archive(ctx context.Context, video *VideoV2) (bool, error) {
+	if err := os.MkdirAll(d.Dir, 0755); err != nil {
+		return false, err
+	}
+	return false, d.save(ArchiveRecord{Video: *video, ArchivedAt: time.Now()})
+}
+
+func (d DirArchive) save(record ArchiveRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.file(record.Video.Id), data, 0644)
+}
+
+// Load returns the archived record for the video id
+func (d DirArchive) Load(id string) (record ArchiveRecord, err error) {
+	data, err := ioutil.ReadFile(d.file(id))
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(data, &record)
+	return record, err
+}
+
+func (d DirArchive) Restore(ctx context.Context, api *ApiV2, id string) (VideoV2, error) {
+	record, err := d.Load(id)
+	if err != nil {
+		return VideoV2{}, err
+	}
+	old := record.Video
+	var video VideoV2
+	if record.RestoredId != "" {
+		// an earlier restore created the video
+		video, err = api.GetVideoCtx(ctx, record.RestoredId)
+	} else {
+		body, _ := json.Marshal(struct {
+			Metadata json.RawMessage `json:"metadata,omitempty"`
+			Userdata json.RawMessage `json:"user_data,omitempty"`
+		}{old.Metadata, old.Userdata})
+		video, err = api.CreateCtx(ctx, body)
+		if err == nil {
+			record.RestoredId = video.Id
+			err = d.save(record)
+		}
+	}
+	if err != nil {
+		return video, err
+	}
+	for _, asset := range old.Assets {
+		current, err := api.findAsset(ctx, asset.Id)
+		if err != nil {
+			return video, err
+		}
+		if current != nil {
+			if err := video.moveAsset(ctx, current); err != nil {
+				return video, err
+			}
+			continue
+		}
+		asset.Id = ""
+		asset.VideoId = video.Id
+		if err := video.CreateOrUpdateAssetCtx(ctx, &asset); err != nil {
+			return video, err
+		}
+	}
+	for _, accountId := range old.AccountIds {
+		if err := video.AddAccountCtx(ctx, accountId); err != nil {
+			return video, err
+		}
+	}
+	return video, os.Remove(d.file(id))
+}
+
+// findAsset returns the asset id, or nil if it is not on the server
+func (a *ApiV2) findAsset(ctx context.Context, id string) (*Asset, error) {
+	req, err := a.makeRequestCtx(ctx, "GET", a.getBaseUrl()+"/assets/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp AssetResponse
+	header, err := handleReqHeader(a, req, &resp)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil || resp.Asset == nil {
+		return nil, err
+	}
+	resp.Asset.ETag = header.Get("ETag")
+	return resp.Asset, nil
+}
+
+// moveAsset makes asset one of the video's assets
+func (v *VideoV2) moveAsset(ctx context.Context, asset *Asset) error {
+	if asset.VideoId != v.Id {
+		asset.VideoId = v.Id
+		asset.Api = *v.Api
+		if err := asset.update(ctx, asset.ETag); err != nil {
+			return err
+		}
+	}
+	if _, found := v.FindAsset(asset.Id); !found {
+		v.Assets = append(v.Assets, *asset)
+	}
+	return nil
+}
+
+// UserDataArchive soft deletes videos in place, by setting SOFT_DELETE_KEY in
+// their user_data, so nothing is removed from the server
+type UserDataArchive struct{}
+
+func (u UserDataArchive) Archive(ctx context.Context, video *VideoV2) (bool, error) {
+	userdata := video.Userdata
+	if len(userdata) == 0 || string(userdata) == "null" {
+		userdata = []byte(`{}`)
+	}
+	now := strconv.Quote(time.Now().UTC().Format(time.RFC3339))
+	userdata, err := jsonparser.Set(userdata, []byte(now), SOFT_DELETE_KEY)
+	if err != nil {
+		return true, err
+	}
+	video.Userdata = userdata
+	return true, video.UpdateCtx(ctx)
+}
+
+func (u UserDataArchive) Restore(ctx context.Context, api *ApiV2, id string) (VideoV2, error) {
+	video, err := api.GetVideoCtx(ctx, id)
+	if err != nil {
+		return video, err
+	}
+	if !video.IsSoftDeleted() {
+		return video, nil
+	}
+	video.clearSoftDelete()
+	return video, video.UpdateCtx(ctx)
+}
+
+func (v *VideoV2) clearSoftDelete() {
+	v.Userdata = jsonparser.Delete(v.Userdata, SOFT_DELETE_KEY)
+}
+
+// IsSoftDeleted returns true if the video was deleted with UserDataArchive
+func (v VideoV2) IsSoftDeleted() bool {
+	deleted, _ := jsonparser.GetString(v.Userdata, SOFT_DELETE_KEY)
+	return deleted != ""
+}
+
+// RestoreVideo brings back a video that was deleted with an archive
+func (a *ApiV2) RestoreVideo(id string, archive VideoArchive) (VideoV2, error) {
+	return a.RestoreVideoCtx(context.Background(), id, archive)
+}
+
+func (a *ApiV2) RestoreVideoCtx(ctx context.Context, id string, archive VideoArchive) (VideoV2, error) {
+	return archive.Restore(ctx, a, id)
+}
@@ -0,0 +1,178 @@
+package synq
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirArchive(t *testing.T) {
+	assert := require.New(t)
+	dir, err := ioutil.TempDir("", "archive")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	archive := DirArchive{Dir: filepath.Join(dir, "videos")}
+	video := setupTestVideoV2()
+	video.Metadata = json.RawMessage(`{"title":"archived"}`)
+	video.AccountIds = []string{"account"}
+	assert.Nil(video.Delete(DeleteOptions{Cascade: true, Archive: archive}))
+	record, err := archive.Load(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(testVideoIdV2, record.Video.Id)
+	assert.Len(record.Video.Assets, 1)
+	assert.Equal(testAssetId, record.Video.Assets[0].Id)
+	assert.False(record.ArchivedAt.IsZero())
+	reqs, _ := testServer.GetReqs()
+	assert.Equal("DELETE", reqs[len(reqs)-1].Method)
+
+	// the server keeps track of what was deleted, so no Reset
+	start := len(reqs)
+	restored, err := video.Api.RestoreVideo(testVideoIdV2, archive)
+	assert.Nil(err)
+	assert.Equal(testVideoIdV2, restored.Id)
+	assert.Len(restored.Assets, 1)
+	reqs, values := testServer.GetReqs()
+	reqs, values = reqs[start:], values[start:]
+	// create the video, check the asset is gone, create it and add the account
+	assert.Len(reqs, 4)
+	assert.Equal("/v1/videos", reqs[0].URL.Path)
+	assert.Equal(`{"metadata":{"title":"archived"},"user_data":{}}`, values[0].Get("body"))
+	assert.Equal("GET", reqs[1].Method)
+	assert.Equal("/v1/assets/"+testAssetId, reqs[1].URL.Path)
+	assert.Equal("/v1/assets", reqs[2].URL.Path)
+	assert.Contains(values[2].Get("body"), `"id":""`)
+	assert.Contains(values[3].Get("body"), `"account_id":"account"`)
+	_, err = os.Stat(archive.file(testVideoIdV2))
+	assert.True(os.IsNotExist(err))
+
+	_, err = video.Api.RestoreVideo(testVideoIdV2, archive)
+	assert.True(os.IsNotExist(err))
+
+	// assets that were not deleted are not copied, they belong to the
+	// restored video
+	testServer.Reset()
+	video = setupTestVideoV2()
+	assert.Nil(video.Delete(DeleteOptions{Archive: archive}))
+	testServer.Reset()
+	restored, err = video.Api.RestoreVideo(testVideoIdV2, archive)
+	assert.Nil(err)
+	assert.Len(restored.Assets, 1)
+	assert.Equal(testAssetId, restored.Assets[0].Id)
+	reqs, _ = testServer.GetReqs()
+	for _, req := range reqs {
+		assert.NotEqual("/v1/assets", req.URL.Path)
+	}
+}
+
+// failPost answers POST requests to its path with a 400
+type failPost string
+
+func (f failPost) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "POST" && req.URL.Path == string(f) {
+		body := ioutil.NopCloser(strings.NewReader(`{"message":"invalid asset"}`))
+		return &http.Response{StatusCode: http.StatusBadRequest, Body: body, Header: http.Header{}, Request: req}, nil
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDirArchiveRetry(t *testing.T) {
+	assert := require.New(t)
+	dir, err := ioutil.TempDir("", "archive")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	archive := DirArchive{Dir: dir}
+	video := setupTestVideoV2()
+	assert.Nil(video.Delete(DeleteOptions{Cascade: true, Archive: archive}))
+	video.Api.Client = &http.Client{Transport: failPost("/v1/assets")}
+	_, err = video.Api.RestoreVideo(testVideoIdV2, archive)
+	assert.NotNil(err)
+	// the new video is kept, and used by the next attempt
+	record, err := archive.Load(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(testVideoIdV2, record.RestoredId)
+
+	video.Api.Client = nil
+	// the server keeps track of what was deleted, so no Reset
+	reqs, _ := testServer.GetReqs()
+	start := len(reqs)
+	restored, err := video.Api.RestoreVideo(testVideoIdV2, archive)
+	assert.Nil(err)
+	assert.Equal(testVideoIdV2, restored.Id)
+	reqs, _ = testServer.GetReqs()
+	reqs = reqs[start:]
+	// get the video, check the asset is gone and update the copy the video
+	// already has
+	assert.Len(reqs, 3)
+	assert.Equal("GET", reqs[0].Method)
+	assert.Equal("/v1/videos/"+testVideoIdV2, reqs[0].URL.Path)
+	assert.Equal("/v1/assets/"+testAssetId, reqs[1].URL.Path)
+	assert.Equal("PUT", reqs[2].Method)
+	assert.Equal("/v1/assets/"+testAssetId, reqs[2].URL.Path)
+	_, err = os.Stat(archive.file(testVideoIdV2))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestMoveAsset(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	video := VideoV2{Id: testVideoId2V2, Api: &api}
+	asset, err := api.findAsset(context.Background(), testAssetId)
+	assert.Nil(err)
+	assert.Equal(testVideoIdV2, asset.VideoId)
+	assert.Nil(video.moveAsset(context.Background(), asset))
+	assert.Len(video.Assets, 1)
+	reqs, values := testServer.GetReqs()
+	assert.Len(reqs, 2)
+	assert.Equal("PUT", reqs[1].Method)
+	assert.Contains(values[1].Get("body"), `"video_id":"`+testVideoId2V2+`"`)
+
+	// an asset that is already the video's is left alone
+	testServer.Reset()
+	video = VideoV2{Id: testVideoIdV2, Api: &api}
+	assert.Nil(video.moveAsset(context.Background(), asset))
+	assert.Len(video.Assets, 1)
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 0)
+
+	// a deleted asset is not found
+	assert.Nil(asset.Delete())
+	asset, err = api.findAsset(context.Background(), testAssetId)
+	assert.Nil(err)
+	assert.Nil(asset)
+}
+
+func TestUserDataArchive(t *testing.T) {
+	assert := require.New(t)
+	video := setupTestVideoV2()
+	video.Userdata = nil
+	assert.False(video.IsSoftDeleted())
+	assert.Nil(video.Delete(DeleteOptions{Cascade: true, Archive: UserDataArchive{}}))
+	reqs, values := testServer.GetReqs()
+	// the asset list and the update, nothing is deleted
+	assert.Len(reqs, 2)
+	assert.Equal("PUT", reqs[1].Method)
+	assert.Contains(values[1].Get("body"), SOFT_DELETE_KEY)
+
+	testServer.Reset()
+	video.Userdata = json.RawMessage(`{"keep":true,"` + SOFT_DELETE_KEY + `":"2019-01-01T00:00:00Z"}`)
+	assert.True(video.IsSoftDeleted())
+	video.clearSoftDelete()
+	assert.False(video.IsSoftDeleted())
+	assert.JSONEq(`{"keep":true}`, string(video.Userdata))
+
+	// the test server video is not soft deleted, so there is nothing to update
+	testServer.Reset()
+	restored, err := video.Api.RestoreVideo(testVideoIdV2, UserDataArchive{})
+	assert.Nil(err)
+	assert.Equal(testVideoIdV2, restored.Id)
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 1)
+}
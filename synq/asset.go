@@ -2,6 +2,7 @@ package synq
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -20,7 +21,9 @@ type AssetResponse struct {
 }
 
 type AssetList struct {
-	Assets []Asset `json:"data"`
+	Assets     []Asset `json:"data"`
+	PageSize   int     `json:"page_size"`
+	PageNumber int     `json:"page_number"`
 }
 
 type Asset struct {
@@ -39,8 +42,15 @@ type Asset struct {
 	Api              ApiV2                   `json:"-"`
 	Video            VideoV2                 `json:"-"`
 	UploadParameters upload.UploadParameters `json:"-"`
+	// ETag is the version of the asset returned by the server (if it sends
+	// one), UpdateIfUnchanged uses it
+	ETag string `json:"-"`
+	// Progress is called as UploadFile and UploadFileResumable progress
+	Progress upload.ProgressFunc `json:"-"`
 }
 
+// AssetUpload describes the uploaded file, Checksum is the (hex) MD5 of its
+// first ChecksumSize bytes
 type AssetUpload struct {
 	Checksum     string     `json:"checksum,omitempty"`
 	ChecksumSize int64      `json:"checksum_size,omitempty"`
@@ -48,6 +58,7 @@ type AssetUpload struct {
 	Started      *time.Time `json:"started,omitempty"`
 	Finished     *time.Time `json:"finished,omitempty"`
 	Filename     string     `json:"filename,omitempty"`
+	Sha256       string     `json:"sha256,omitempty"`
 }
 
 func (a *Asset) getApi() *ApiV2 {
@@ -62,37 +73,59 @@ func (a *Asset) getApi() *ApiV2 {
 }
 
 func (a *Asset) Update() error {
+	return a.UpdateCtx(context.Background())
+}
+
+func (a *Asset) UpdateCtx(ctx context.Context) error {
+	return a.update(ctx, "")
+}
+
+func (a *Asset) update(ctx context.Context, ifMatch string) error {
 	url := a.getApi().getBaseUrl() + "/assets/" + a.Id
 	data, err := json.Marshal(a)
 	if err != nil {
 		return err
 	}
 	body := bytes.NewBuffer(data)
-	return a.handleAssetReq("PUT", url, body)
+	return a.handleAssetReqIf(ctx, "PUT", url, body, ifMatch)
 }
 
 func (a *Asset) Delete() error {
+	return a.DeleteCtx(context.Background())
+}
+
+func (a *Asset) DeleteCtx(ctx context.Context) error {
 	url := a.getApi().getBaseUrl() + "/assets/" + a.Id
 	data, err := json.Marshal(a)
 	if err != nil {
 		return err
 	}
 	body := bytes.NewBuffer(data)
-	return a.handleAssetReq("DELETE", url, body)
+	return a.handleAssetReq(ctx, "DELETE", url, body)
+}
+
+func (a *Asset) handleAssetReq(ctx context.Context, method, url string, body io.Reader) error {
+	return a.handleAssetReqIf(ctx, method, url, body, "")
 }
 
-func (a *Asset) handleAssetReq(method, url string, body io.Reader) error {
+// handleAssetReqIf only makes the change if the server version matches ifMatch when it is set
+func (a *Asset) handleAssetReqIf(ctx context.Context, method, url string, body io.Reader, ifMatch string) error {
 	resp := AssetResponse{Asset: a}
-	req, err := a.getApi().makeRequest(method, url, body)
+	api := a.getApi()
+	req, err := api.makeRequestCtx(ctx, method, url, body)
 	if err != nil {
 		return err
 	}
 	req.Header.Add("content-type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
 
-	err = handleReq(a.Api, req, &resp)
+	header, err := handleReqHeader(api, req, &resp)
 	if err != nil {
 		return err
 	}
+	a.ETag = header.Get("ETag")
 
 	return nil
 }
@@ -105,9 +138,123 @@ func (a *Asset) GetUrl() string {
 }
 
 func (a *Asset) UploadFile(fileName string) error {
+	return a.UploadFileCtx(context.Background(), fileName)
+}
+
+// UploadFileCtx uploads the file to S3, cancelling ctx aborts the upload
+// (and any outstanding parts) as soon as possible. Once it is uploaded the
+// file's checksum, size, name and upload times are saved in UploadInfo.
+func (a *Asset) UploadFileCtx(ctx context.Context, fileName string) error {
+	aws, err := a.uploader(ctx, fileName)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	started := time.Now()
+	if _, err = aws.UploadCtx(ctx, f); err != nil {
+		return toS3Error(err)
+	}
+	return a.uploaded(ctx, aws, fileName, started)
+}
+
+func (a *Asset) UploadFileResumable(fileName string, checkpointFile ...string) error {
+	return a.UploadFileResumableCtx(context.Background(), fileName, checkpointFile...)
+}
+
+// UploadFileResumableCtx uploads the file to S3 so that an interrupted upload
+// continues where it stopped when it is called again. The progress is saved
+// in checkpointFile, fileName + upload.CHECKPOINT_EXT if it is not set.
+func (a *Asset) UploadFileResumableCtx(ctx context.Context, fileName string, checkpointFile ...string) error {
+	aws, err := a.uploader(ctx, fileName)
+	if err != nil {
+		return err
+	}
+	resumable, ok := aws.(upload.ResumableUploadF)
+	if !ok {
+		return errors.New("uploader can not resume uploads")
+	}
+	checkpoint := ""
+	if len(checkpointFile) > 0 {
+		checkpoint = checkpointFile[0]
+	}
+	started := time.Now()
+	if _, err = resumable.UploadFileResumableCtx(ctx, fileName, checkpoint); err != nil {
+		return toS3Error(err)
+	}
+	return a.uploaded(ctx, aws, fileName, started)
+}
+
+// uploaded saves how fileName was uploaded in UploadInfo, with the checksums
+// of the file if the uploader computed them
+func (a *Asset) uploaded(ctx context.Context, uploader upload.AwsUploadF, fileName string, started time.Time) error {
+	finished := time.Now().UTC()
+	started = started.UTC()
+	info := AssetUpload{
+		Filename: filepath.Base(fileName),
+		Started:  &started,
+		Finished: &finished,
+	}
+	if c, ok := uploader.(upload.ChecksumUploadF); ok {
+		sum := c.LastChecksum()
+		info.Checksum = sum.MD5
+		info.ChecksumSize = sum.Size
+		info.Sha256 = sum.SHA256
+		info.Size = sum.Size
+	} else if stat, err := os.Stat(fileName); err == nil {
+		info.Size = stat.Size()
+	}
+	a.UploadInfo = info
+	return a.UpdateCtx(ctx)
+}
+
+// VerifyUpload returns an error if fileName does not match the asset's
+// UploadInfo : its size, the MD5 of its first ChecksumSize bytes and its
+// SHA-256 (if it was saved). Get the asset again first to check what the
+// server has.
+func (a *Asset) VerifyUpload(fileName string) error {
+	info := a.UploadInfo
+	if info.Checksum == "" {
+		return common.NewError("asset '%s' has no upload checksum", a.Id)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size > 0 && info.Size != stat.Size() {
+		return common.NewError("asset '%s' uploaded %d bytes, '%s' has %d", a.Id, info.Size, fileName, stat.Size())
+	}
+	var r io.Reader = f
+	if info.ChecksumSize > 0 {
+		r = io.LimitReader(f, info.ChecksumSize)
+	}
+	sum, err := upload.ChecksumReader(r, 0)
+	if err != nil {
+		return err
+	}
+	if sum.MD5 != info.Checksum {
+		return common.NewError("asset '%s' checksum '%s' does not match '%s' of '%s'", a.Id, info.Checksum, sum.MD5, fileName)
+	}
+	if info.Sha256 != "" && sum.Size == stat.Size() && sum.SHA256 != info.Sha256 {
+		return common.NewError("asset '%s' sha256 '%s' does not match '%s' of '%s'", a.Id, info.Sha256, sum.SHA256, fileName)
+	}
+	return nil
+}
+
+// uploader returns the uploader for fileName, getting the upload parameters
+// again if they are not set
+func (a *Asset) uploader(ctx context.Context, fileName string) (upload.AwsUploadF, error) {
 	upUrl := a.Api.UploadUrl
 	if upUrl == "" {
-		return errors.New("invalid upload url, can not upload file")
+		return nil, errors.New("invalid upload url, can not upload file")
 	}
 	if a.UploadParameters.Key == "" {
 		// if the location exists, get the upload parameters again
@@ -118,31 +265,37 @@ func (a *Asset) UploadFile(fileName string) error {
 				ContentType: common.ExtToCtype(ext),
 				AssetId:     a.Id,
 			}
-			up, err := a.Video.GetUploadParams(req)
+			up, err := a.Video.GetUploadParamsCtx(ctx, req)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			a.UploadParameters = up
 		} else {
-			return errors.New("upload parameters is invalid")
+			return nil, errors.New("upload parameters is invalid")
 		}
 	}
-	f, err := os.Open(fileName)
-	defer f.Close()
-	if os.IsNotExist(err) {
-		return errors.New("file '" + fileName + "' does not exist")
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		return nil, errors.New("file '" + fileName + "' does not exist")
 	}
-
 	params := a.UploadParameters
 	if !strings.Contains(params.SignatureUrl, "http") {
 		sigUrl := upUrl + params.SignatureUrl
 		log.Printf("Updating sig url to include host '%s'\n", upUrl)
 		params.SignatureUrl = sigUrl
 	}
-	aws, err := upload.CreatorFn(params)
-	if err != nil {
-		return err
+	api := a.getApi()
+	// the signature server is retried like the api
+	retry := api.GetRetryPolicy()
+	attempts := retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	opts := upload.UploadOptions{
+		Client:       api.GetClient(),
+		Progress:     a.Progress,
+		SignAttempts: attempts,
+		SignBackoff:  retry.BaseDelay,
+		PartOptions:  api.GetPartOptions(),
 	}
-	_, err = aws.Upload(f)
-	return err
+	return upload.CreatorFn(params, opts)
 }
@@ -1,10 +1,18 @@
 package synq
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/SYNQfm/SYNQ-Golang/test_server"
 	"github.com/SYNQfm/SYNQ-Golang/upload"
@@ -116,7 +124,7 @@ func TestHandleAssetReq(t *testing.T) {
 	asset, _ := video.GetAsset(testAssetId)
 	ogAsset := asset
 	url := video.Api.getBaseUrl() + "/assets/" + testAssetId
-	err := asset.handleAssetReq("GET", url, nil)
+	err := asset.handleAssetReq(context.Background(), "GET", url, nil)
 	assert.Nil(err)
 	assert.Equal(ogAsset, asset)
 }
@@ -156,7 +164,169 @@ func TestAssetUploadFile(t *testing.T) {
 	assert.Equal("file 'fake' does not exist", err.Error())
 	err = asset.UploadFile(fileName)
 	assert.Nil(err)
+	// the upload is saved to the asset
+	content, _ := ioutil.ReadFile(fileName)
+	info := asset.UploadInfo
+	assert.Equal("test.mp4", info.Filename)
+	assert.Equal(int64(len(content)), info.Size)
+	assert.Equal(int64(len(content)), info.ChecksumSize)
+	assert.Equal(fmt.Sprintf("%x", md5.Sum(content)), info.Checksum)
+	assert.Equal(fmt.Sprintf("%x", sha256.Sum256(content)), info.Sha256)
+	assert.False(info.Finished.Before(*info.Started))
+	reqs, vals = testServer.GetReqs()
+	last := len(reqs) - 1
+	assert.Equal("PUT", reqs[last].Method)
+	assert.Equal("/"+SYNQ_ROUTE+"/assets/"+asset.Id, reqs[last].URL.Path)
+	assert.Contains(vals[last].Get("body"), info.Checksum)
 	recvParams := test_server.GetParams()
 	assert.Len(recvParams, 1)
 	assert.Equal(asset.UploadParameters, recvParams[0])
+	recvOptions := test_server.GetOptions()
+	assert.Len(recvOptions, 1)
+	assert.Equal(video.Api.GetClient(), recvOptions[0].Client)
+}
+
+func TestAssetUploadFileCtx(t *testing.T) {
+	assert := require.New(t)
+	video := setupTestVideoV2()
+	asset := Asset{
+		Id:    test_server.ASSET_ID,
+		Video: video,
+	}
+	asset.Api.UploadUrl = "http://test.com"
+	setupTestParams(&asset)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := asset.UploadFileCtx(ctx, DEFAULT_SAMPLE_DIR+"/test.mp4")
+	assert.Equal(context.Canceled, err)
+}
+
+func TestAssetUploadFileResumable(t *testing.T) {
+	assert := require.New(t)
+	video := setupTestVideoV2()
+	asset := Asset{
+		Id:    test_server.ASSET_ID,
+		Video: video,
+	}
+	fileName := DEFAULT_SAMPLE_DIR + "/test.mp4"
+	err := asset.UploadFileResumable(fileName)
+	assert.Equal("invalid upload url, can not upload file", err.Error())
+	asset.Api.UploadUrl = "http://test.com"
+	setupTestParams(&asset)
+	err = asset.UploadFileResumable("fake")
+	assert.Equal("file 'fake' does not exist", err.Error())
+	assert.Nil(asset.UploadFileResumable(fileName))
+	assert.Nil(asset.VerifyUpload(fileName))
+	assert.Nil(asset.UploadFileResumable(fileName, "/tmp/test.mp4.state"))
+	checkpoints := test_server.GetCheckpoints()
+	assert.Equal([]string{"", "/tmp/test.mp4.state"}, checkpoints[len(checkpoints)-2:])
+	// the progress func is passed to the uploader
+	options := test_server.GetOptions()
+	assert.Nil(options[len(options)-1].Progress)
+	asset.Progress = func(upload.Progress) {}
+	assert.Nil(asset.UploadFile(fileName))
+	options = test_server.GetOptions()
+	assert.NotNil(options[len(options)-1].Progress)
+	// and so are the part options of the api
+	parts := upload.PartOptions{PartSize: 64 * 1024 * 1024, Concurrency: 8}
+	video.Api.SetPartOptions(parts)
+	assert.Nil(asset.UploadFile(fileName))
+	options = test_server.GetOptions()
+	assert.Equal(parts, options[len(options)-1].PartOptions)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = asset.UploadFileResumableCtx(ctx, fileName)
+	assert.Equal(context.Canceled, err)
+}
+
+func TestAssetVerifyUpload(t *testing.T) {
+	assert := require.New(t)
+	fileName := DEFAULT_SAMPLE_DIR + "/test.mp4"
+	content, _ := ioutil.ReadFile(fileName)
+	asset := Asset{Id: test_server.ASSET_ID}
+	err := asset.VerifyUpload(fileName)
+	assert.Equal("asset '"+asset.Id+"' has no upload checksum", err.Error())
+	asset.UploadInfo = AssetUpload{
+		Checksum:     fmt.Sprintf("%x", md5.Sum(content)),
+		ChecksumSize: int64(len(content)),
+		Size:         int64(len(content)),
+		Sha256:       fmt.Sprintf("%x", sha256.Sum256(content)),
+	}
+	assert.Nil(asset.VerifyUpload(fileName))
+	assert.True(os.IsNotExist(asset.VerifyUpload("fake")))
+	// the checksum can be of the start of the file only
+	asset.UploadInfo = AssetUpload{
+		Checksum:     fmt.Sprintf("%x", md5.Sum(content[:100])),
+		ChecksumSize: 100,
+		Size:         int64(len(content)),
+	}
+	assert.Nil(asset.VerifyUpload(fileName))
+	asset.UploadInfo.Size++
+	err = asset.VerifyUpload(fileName)
+	assert.Contains(err.Error(), "uploaded 14749 bytes")
+	asset.UploadInfo.Size--
+	asset.UploadInfo.ChecksumSize = 101
+	err = asset.VerifyUpload(fileName)
+	assert.Contains(err.Error(), "does not match")
+	asset.UploadInfo = AssetUpload{
+		Checksum: fmt.Sprintf("%x", md5.Sum(content)),
+		Sha256:   "abc",
+	}
+	err = asset.VerifyUpload(fileName)
+	assert.Contains(err.Error(), "sha256 'abc' does not match")
+}
+
+func TestAssetUploadSignerError(t *testing.T) {
+	assert := require.New(t)
+	// use the real uploader against the test s3 server
+	upload.CreatorFn = func(params upload.UploadParameters, options ...upload.UploadOptions) (upload.AwsUploadF, error) {
+		opts := options[0]
+		opts.Endpoint = params.Action
+		return upload.NewAwsUpload(params, opts)
+	}
+	defer func() { upload.CreatorFn = test_server.NewTestAwsUpload }()
+	video := setupTestVideoV2()
+	policy := video.Api.GetRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	video.Api.SetRetryPolicy(policy)
+	asset := Asset{Id: test_server.ASSET_ID, Video: video}
+	asset.Api.UploadUrl = "http://test.com"
+	setupTestParams(&asset)
+	s3Server := test_server.LastServer()
+	defer s3Server.Close()
+	asset.UploadParameters.SignatureUrl = s3Server.SignatureUrl()
+	fileName := DEFAULT_SAMPLE_DIR + "/test.mp4"
+
+	// a broken signature server fails the upload before anything is sent to S3
+	s3Server.BreakSignature(http.StatusForbidden, `{"message":"invalid upload token"}`)
+	err := asset.UploadFile(fileName)
+	signErr, ok := err.(*upload.SignerError)
+	assert.True(ok)
+	assert.Equal(http.StatusForbidden, signErr.StatusCode)
+	assert.Equal(`{"message":"invalid upload token"}`, string(signErr.Body))
+	assert.Equal(1, signErr.Attempts)
+	reqs, _ := s3Server.GetReqs()
+	assert.Len(reqs, 1)
+	assert.Equal(test_server.SIGNATURE_PATH, reqs[0].URL.Path)
+
+	// transient failures are retried, so the request is signed and sent to
+	// S3 (which refuses it)
+	s3Server.Reset()
+	s3Server.FailNext(2, http.StatusServiceUnavailable, "")
+	err = asset.UploadFile(fileName)
+	_, ok = err.(*S3Error)
+	assert.True(ok)
+	reqs, _ = s3Server.GetReqs()
+	assert.Len(reqs, 4)
+	assert.Equal(test_server.SIGNATURE_PATH, reqs[2].URL.Path)
+	assert.NotEqual(test_server.SIGNATURE_PATH, reqs[3].URL.Path)
+
+	// until they run out of attempts
+	s3Server.Reset()
+	s3Server.FailNext(DEFAULT_RETRY_ATTEMPTS, http.StatusBadGateway, "")
+	err = asset.UploadFileResumable(fileName, filepath.Join(os.TempDir(), "signer.state"))
+	signErr, ok = err.(*upload.SignerError)
+	assert.True(ok)
+	assert.Equal(http.StatusBadGateway, signErr.StatusCode)
+	assert.Equal(DEFAULT_RETRY_ATTEMPTS, signErr.Attempts)
 }
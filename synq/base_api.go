@@ -1,14 +1,16 @@
 package synq
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/SYNQfm/SYNQ-Golang/upload"
 	"github.com/SYNQfm/helpers/common"
 )
 
@@ -23,6 +25,15 @@ type BaseApi struct {
 	Timeout       time.Duration
 	UploadTimeout time.Duration
 	Version       string
+	// Client is shared by every call made through this api (including login,
+	// the upload signature server and S3), set it to configure proxies, TLS or
+	// a custom transport. Timeouts are applied per request, so leave
+	// Client.Timeout unset.
+	Client *http.Client
+	// Retry controls how transient failures are retried
+	Retry RetryPolicy
+	// Parts controls the part size, concurrency and memory of file uploads
+	Parts upload.PartOptions
 }
 
 type ApiF interface {
@@ -34,6 +45,12 @@ type ApiF interface {
 	ParseError(int, []byte) error
 	SetUrl(string)
 	SetKey(string)
+	GetClient() *http.Client
+	SetClient(*http.Client)
+	GetRetryPolicy() RetryPolicy
+	SetRetryPolicy(RetryPolicy)
+	GetPartOptions() upload.PartOptions
+	SetPartOptions(upload.PartOptions)
 }
 
 type AwsError struct {
@@ -66,7 +83,7 @@ func parseAwsResp(resp *http.Response, err error, v interface{}) error {
 		log.Println("could not parse xml", err)
 		return err
 	}
-	return errors.New(xmlErr.Message)
+	return &S3Error{AwsError: xmlErr, StatusCode: resp.StatusCode, Body: responseAsBytes}
 }
 
 func parseSynqResp(a ApiF, resp *http.Response, err error, v interface{}) error {
@@ -93,11 +110,16 @@ func parseSynqResp(a ApiF, resp *http.Response, err error, v interface{}) error
 		}
 		return nil
 	default:
-		return a.ParseError(resp.StatusCode, responseAsBytes)
+		return setRequest(a.ParseError(resp.StatusCode, responseAsBytes), resp)
 	}
 }
 
 func NewBase(key string, timeouts ...time.Duration) BaseApi {
+	return NewBaseWithClient(key, &http.Client{}, timeouts...)
+}
+
+// NewBaseWithClient creates a base api which sends all of its requests through client
+func NewBaseWithClient(key string, client *http.Client, timeouts ...time.Duration) BaseApi {
 	timeout := time.Duration(DEFAULT_TIMEOUT_MS) * time.Millisecond
 	up_timeout := time.Duration(DEFAULT_UPLOAD_MS) * time.Millisecond
 	if len(timeouts) > 1 {
@@ -110,6 +132,8 @@ func NewBase(key string, timeouts ...time.Duration) BaseApi {
 		Key:           key,
 		Timeout:       timeout,
 		UploadTimeout: up_timeout,
+		Client:        client,
+		Retry:         DefaultRetryPolicy(),
 	}
 }
 
@@ -145,14 +169,100 @@ func (b *BaseApi) SetKey(key string) {
 	b.Key = key
 }
 
+func (b *BaseApi) GetClient() *http.Client {
+	if b.Client == nil {
+		return http.DefaultClient
+	}
+	return b.Client
+}
+
+func (b *BaseApi) SetClient(client *http.Client) {
+	b.Client = client
+}
+
+func (b *BaseApi) GetRetryPolicy() RetryPolicy {
+	return b.Retry
+}
+
+func (b *BaseApi) SetRetryPolicy(policy RetryPolicy) {
+	b.Retry = policy
+}
+
+func (b *BaseApi) GetPartOptions() upload.PartOptions {
+	return b.Parts
+}
+
+func (b *BaseApi) SetPartOptions(opts upload.PartOptions) {
+	b.Parts = opts
+}
+
+// sendReq sends the request through the api's shared client, limiting each
+// attempt to the timeout configured for type_ and retrying transient failures.
+// A 401 is replayed once with a new token if the api can log in again.
+// The returned cancel func must be called once the response body has been read.
+func sendReq(a ApiF, type_ string, req *http.Request) (*http.Response, context.CancelFunc, error) {
+	send := func(r *http.Request) (*http.Response, context.CancelFunc, error) {
+		// the caller defers cancel, it can only run once the body is read
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout := a.GetTimeout(type_); timeout > 0 {
+			ctx, cancel = context.WithTimeout(r.Context(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(r.Context())
+		}
+		resp, err := a.GetClient().Do(r.WithContext(ctx))
+		return resp, cancel, err
+	}
+	resp, cancel, err := sendWithRetry(a.GetRetryPolicy(), req, send)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, cancel, err
+	}
+	// the token was rejected, log in again (once) and replay the request
+	r, ok := a.(reauther)
+	staleAuth := req.Header.Get("Authorization")
+	if !ok || staleAuth == "" || !replayable(req) {
+		return resp, cancel, err
+	}
+	auth, ok := r.reauth(req.Context(), staleAuth)
+	if !ok {
+		return resp, cancel, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	cancel()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, func() {}, err
+		}
+		req.Body = body
+	}
+	req.Header.Set("Authorization", auth)
+	return sendWithRetry(a.GetRetryPolicy(), req, send)
+}
+
 func handleReq(a ApiF, req *http.Request, v interface{}) error {
-	httpClient := &http.Client{Timeout: a.GetTimeout("")}
-	resp, err := httpClient.Do(req)
-	return parseSynqResp(a, resp, err, v)
+	_, err := handleReqHeader(a, req, v)
+	return err
+}
+
+// handleReqHeader is handleReq that also returns the response headers
+func handleReqHeader(a ApiF, req *http.Request, v interface{}) (http.Header, error) {
+	resp, cancel, err := sendReq(a, "", req)
+	defer cancel()
+	header := http.Header{}
+	if err == nil {
+		defer resp.Body.Close()
+		header = resp.Header
+	}
+	return header, parseSynqResp(a, resp, err, v)
 }
 
 func handleUploadReq(a ApiF, req *http.Request, v interface{}) error {
-	httpClient := &http.Client{Timeout: a.GetTimeout("upload")}
-	resp, err := httpClient.Do(req)
+	resp, cancel, err := sendReq(a, "upload", req)
+	defer cancel()
+	if err == nil {
+		defer resp.Body.Close()
+	}
 	return parseAwsResp(resp, err, v)
 }
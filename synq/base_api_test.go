@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/SYNQfm/SYNQ-Golang/test_server"
 	"github.com/stretchr/testify/assert"
@@ -66,3 +67,18 @@ func TestParseAwsResp(t *testing.T) {
 	e = parseAwsResp(&resp, nil, v)
 	assert.Nil(e)
 }
+
+func TestNewBaseWithClient(t *testing.T) {
+	assert := assert.New(t)
+	base := NewBase("key")
+	assert.NotNil(base.Client)
+	assert.False(http.DefaultClient == base.GetClient())
+	client := &http.Client{}
+	base = NewBaseWithClient("key", client, time.Second)
+	assert.Equal(client, base.GetClient())
+	assert.Equal(time.Second, base.GetTimeout(""))
+	base = BaseApi{}
+	assert.Equal(http.DefaultClient, base.GetClient())
+	base.SetClient(client)
+	assert.Equal(client, base.GetClient())
+}
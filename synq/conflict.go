@@ -0,0 +1,98 @@
+package synq
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	DEFAULT_UPDATE_ATTEMPTS = 5
+)
+
+// UpdateIfUnchanged is Update, but returns a *ConflictError (which matches
+// ErrConflict) if the video was changed on the server since it was loaded.
+// It uses the ETag when the server sent one, otherwise it compares UpdatedAt.
+func (v *VideoV2) UpdateIfUnchanged() error {
+	return v.UpdateIfUnchangedCtx(context.Background())
+}
+
+func (v *VideoV2) UpdateIfUnchangedCtx(ctx context.Context) error {
+	conflict := &ConflictError{Id: v.Id, ETag: v.ETag, UpdatedAt: v.UpdatedAt.Format(time.RFC3339Nano)}
+	if v.ETag == "" {
+		current, err := v.Api.GetVideoCtx(ctx, v.Id)
+		if err != nil {
+			return err
+		}
+		if !current.UpdatedAt.Equal(v.UpdatedAt) {
+			conflict.ServerUpdatedAt = current.UpdatedAt.Format(time.RFC3339Nano)
+			return conflict
+		}
+		// protect the gap between the check and the update if we can
+		conflict.ETag = current.ETag
+	}
+	err := v.update(ctx, conflict.ETag)
+	if errors.Is(err, ErrConflict) {
+		conflict.Err = err
+		return conflict
+	}
+	return err
+}
+
+// UpdateWithRetry loads the latest copy of the video, applies mutate to it and
+// saves it with UpdateIfUnchanged, starting over when someone else changed the
+// video in between. If mutate returns an error, nothing is saved.
+func (v *VideoV2) UpdateWithRetry(mutate func(*VideoV2) error) error {
+	return v.UpdateWithRetryCtx(context.Background(), mutate)
+}
+
+func (v *VideoV2) UpdateWithRetryCtx(ctx context.Context, mutate func(*VideoV2) error) error {
+	var conflict error
+	for attempt := 0; attempt < DEFAULT_UPDATE_ATTEMPTS; attempt++ {
+		current, err := v.Api.GetVideoCtx(ctx, v.Id)
+		if err != nil {
+			return err
+		}
+		if err = mutate(&current); err != nil {
+			return err
+		}
+		err = current.UpdateIfUnchangedCtx(ctx)
+		if err == nil {
+			*v = current
+			return nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+		conflict = err
+	}
+	return conflict
+}
+
+// UpdateIfUnchanged is Update, but returns a *ConflictError (which matches
+// ErrConflict) if the asset was changed on the server since it was loaded.
+// It uses the ETag when the server sent one, otherwise it compares UpdatedAt.
+func (a *Asset) UpdateIfUnchanged() error {
+	return a.UpdateIfUnchangedCtx(context.Background())
+}
+
+func (a *Asset) UpdateIfUnchangedCtx(ctx context.Context) error {
+	conflict := &ConflictError{Id: a.Id, ETag: a.ETag, UpdatedAt: a.UpdatedAt}
+	if a.ETag == "" {
+		current, err := a.getApi().GetAssetCtx(ctx, a.Id)
+		if err != nil {
+			return err
+		}
+		if current.UpdatedAt != a.UpdatedAt {
+			conflict.ServerUpdatedAt = current.UpdatedAt
+			return conflict
+		}
+		conflict.ETag = current.ETag
+	}
+	err := a.update(ctx, conflict.ETag)
+	if errors.Is(err, ErrConflict) {
+		conflict.Err = err
+		return conflict
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package synq
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVideoUpdateIfUnchangedETag(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	testServer.EnableETags()
+	video, err := api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(`"0"`, video.ETag)
+	assert.Nil(video.UpdateIfUnchanged())
+	assert.Equal(`"1"`, video.ETag)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 2)
+	assert.Equal(`"0"`, reqs[1].Header.Get("If-Match"))
+
+	testServer.Touch(testVideoIdV2)
+	err = video.UpdateIfUnchanged()
+	assert.True(errors.Is(err, ErrConflict))
+	var conflict *ConflictError
+	assert.True(errors.As(err, &conflict))
+	assert.Equal(testVideoIdV2, conflict.Id)
+	assert.Equal(`"1"`, conflict.ETag)
+	var apiErr *APIError
+	assert.True(errors.As(err, &apiErr))
+	assert.Equal(412, apiErr.StatusCode)
+}
+
+func TestVideoUpdateIfUnchangedUpdatedAt(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	video, err := api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	testServer.Reset()
+	assert.Nil(video.UpdateIfUnchanged())
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 2)
+	assert.Equal("GET", reqs[0].Method)
+	assert.Equal("PUT", reqs[1].Method)
+	assert.Equal("", reqs[1].Header.Get("If-Match"))
+
+	testServer.Reset()
+	video, _ = api.GetVideo(testVideoIdV2)
+	serverTime := video.UpdatedAt
+	video.UpdatedAt = video.UpdatedAt.Add(-time.Minute)
+	err = video.UpdateIfUnchanged()
+	var conflict *ConflictError
+	assert.True(errors.As(err, &conflict))
+	assert.Equal(serverTime.Format(time.RFC3339Nano), conflict.ServerUpdatedAt)
+	assert.Nil(conflict.Err)
+	reqs, _ = testServer.GetReqs()
+	// nothing was saved
+	assert.Len(reqs, 2)
+}
+
+func TestVideoUpdateWithRetry(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	testServer.EnableETags()
+	video := VideoV2{Id: testVideoIdV2, Api: &api}
+	calls := 0
+	err := video.UpdateWithRetry(func(v *VideoV2) error {
+		calls++
+		if calls == 1 {
+			// someone else saves the video first
+			testServer.Touch(testVideoIdV2)
+		}
+		v.Metadata = json.RawMessage(`{"title":"retried"}`)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(2, calls)
+	assert.Equal(`"2"`, video.ETag)
+	_, values := testServer.GetReqs()
+	assert.Contains(values[len(values)-1].Get("body"), "retried")
+
+	calls = 0
+	err = video.UpdateWithRetry(func(v *VideoV2) error {
+		calls++
+		testServer.Touch(testVideoIdV2)
+		return nil
+	})
+	assert.True(errors.Is(err, ErrConflict))
+	assert.Equal(DEFAULT_UPDATE_ATTEMPTS, calls)
+
+	testServer.Reset()
+	failed := errors.New("failed")
+	err = video.UpdateWithRetry(func(v *VideoV2) error {
+		return failed
+	})
+	assert.Equal(failed, err)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 1)
+}
+
+func TestAssetUpdateIfUnchanged(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	testServer.EnableETags()
+	asset, err := api.GetAsset(testAssetId)
+	assert.Nil(err)
+	assert.Equal(`"0"`, asset.ETag)
+	assert.Nil(asset.UpdateIfUnchanged())
+	assert.Equal(`"1"`, asset.ETag)
+	testServer.Touch(testAssetId)
+	assert.True(errors.Is(asset.UpdateIfUnchanged(), ErrConflict))
+
+	testServer.Reset()
+	asset, err = api.GetAsset(testAssetId)
+	assert.Nil(err)
+	assert.Equal("", asset.ETag)
+	assert.Nil(asset.UpdateIfUnchanged())
+	asset.UpdatedAt = "2017-01-01T00:00:00Z"
+	err = asset.UpdateIfUnchanged()
+	var conflict *ConflictError
+	assert.True(errors.As(err, &conflict))
+	assert.Equal("2017-01-01T00:00:00Z", conflict.UpdatedAt)
+	assert.Equal("2017-11-16T16:37:14.547327Z", conflict.ServerUpdatedAt)
+}
@@ -0,0 +1,153 @@
+package synq
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/SYNQfm/SYNQ-Golang/upload"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// These can be used with errors.Is to check what kind of failure an
+// *APIError or *S3Error is
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// ErrNoETag is the Err of the *ConflictError returned when a save can not be
+// made conditional, because the server sent no ETag
+var ErrNoETag = errors.New("no ETag")
+
+// APIError is returned for every non successful response from the SYNQ api
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+	Method     string
+	Endpoint   string
+	RequestId  string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func (e *APIError) Is(target error) bool {
+	return statusIs(e.StatusCode, target)
+}
+
+// S3Error is returned when S3 rejects an upload, it keeps all of the fields
+// from the xml error response
+type S3Error struct {
+	AwsError
+	StatusCode int
+	Body       []byte
+}
+
+func (e *S3Error) Error() string {
+	return e.Message
+}
+
+func (e *S3Error) Is(target error) bool {
+	switch e.Code {
+	case "NoSuchKey", "NoSuchBucket", "NoSuchUpload":
+		return target == ErrNotFound
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken":
+		return target == ErrUnauthorized
+	case "PreconditionFailed", "OperationAborted":
+		return target == ErrConflict
+	case "SlowDown":
+		return target == ErrRateLimited
+	}
+	return statusIs(e.StatusCode, target)
+}
+
+// ConflictError is returned by the conditional updates when the copy on the
+// server was changed since it was loaded
+type ConflictError struct {
+	Id string
+	// ETag is the version the update was conditional on
+	ETag string
+	// UpdatedAt is when the local copy was last updated, ServerUpdatedAt is when
+	// the server copy was (if it was compared)
+	UpdatedAt       string
+	ServerUpdatedAt string
+	// Err is the server's response, if it rejected the update
+	Err error
+}
+
+func (e *ConflictError) Error() string {
+	if e.Err == ErrNoETag {
+		return fmt.Sprintf("'%s' has no ETag, it could be changed on the server while it is saved", e.Id)
+	}
+	return fmt.Sprintf("'%s' was changed on the server", e.Id)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+func statusIs(status int, target error) bool {
+	switch target {
+	case ErrNotFound:
+		return status == http.StatusNotFound
+	case ErrUnauthorized:
+		return status == http.StatusUnauthorized || status == http.StatusForbidden
+	case ErrConflict:
+		return status == http.StatusConflict || status == http.StatusPreconditionFailed
+	case ErrRateLimited:
+		return status == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// setRequest fills in where the request went and the request id, if err is an *APIError
+func setRequest(err error, resp *http.Response) error {
+	apiErr, ok := err.(*APIError)
+	if !ok || resp == nil {
+		return err
+	}
+	if resp.Request != nil {
+		apiErr.Method = resp.Request.Method
+		apiErr.Endpoint = resp.Request.URL.String()
+	}
+	if apiErr.RequestId == "" {
+		apiErr.RequestId = resp.Header.Get("X-Request-Id")
+	}
+	return apiErr
+}
+
+// toS3Error converts a failed S3 request from the aws sdk (which may be wrapped
+// by the multipart uploader) into an *S3Error, or the *upload.SignerError if
+// the request could not be signed. Other errors are returned as is.
+func toS3Error(err error) error {
+	if signErr, ok := upload.AsSignerError(err); ok {
+		return signErr
+	}
+	for e := err; e != nil; {
+		if reqErr, ok := e.(awserr.RequestFailure); ok {
+			s3Err := &S3Error{StatusCode: reqErr.StatusCode()}
+			s3Err.Code = reqErr.Code()
+			s3Err.Message = reqErr.Message()
+			s3Err.RequestId = reqErr.RequestID()
+			if h, ok := reqErr.(interface{ HostID() string }); ok {
+				s3Err.HostId = h.HostID()
+			}
+			return s3Err
+		}
+		awsErr, ok := e.(awserr.Error)
+		if !ok {
+			break
+		}
+		e = awsErr.OrigErr()
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package synq
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorTyped(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	statuses := map[int]error{
+		401: ErrUnauthorized,
+		403: ErrUnauthorized,
+		404: ErrNotFound,
+		409: ErrConflict,
+		412: ErrConflict,
+		429: ErrRateLimited,
+	}
+	for status, target := range statuses {
+		err := api.ParseError(status, []byte(`{"message":"failed"}`))
+		assert.True(errors.Is(err, target), "status %d", status)
+		var apiErr *APIError
+		assert.True(errors.As(err, &apiErr))
+		assert.Equal(status, apiErr.StatusCode)
+	}
+	err := api.ParseError(500, []byte(`{"message":"server error"}`))
+	assert.Equal("server error", err.Error())
+	assert.False(errors.Is(err, ErrNotFound))
+	assert.False(errors.Is(err, ErrRateLimited))
+
+	err = api.ParseError(400, []byte(`{}`))
+	assert.Equal("Failed with status 400", err.Error())
+	err = api.ParseError(400, []byte(`not json`))
+	assert.Equal("could not parse error 400 : not json", err.Error())
+	assert.Equal("not json", string(err.(*APIError).Body))
+}
+
+func TestAPIErrorRequest(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2("Bearer " + testAuth)
+	err := api.CreateAssetSettings("bad asset id", []string{})
+	assert.True(errors.Is(err, ErrNotFound))
+	var apiErr *APIError
+	assert.True(errors.As(err, &apiErr))
+	assert.Equal(404, apiErr.StatusCode)
+	assert.Equal("POST", apiErr.Method)
+	assert.Contains(apiErr.Endpoint, testServer.GetUrl())
+}
+
+func TestS3Error(t *testing.T) {
+	assert := require.New(t)
+	resp := http.Response{
+		StatusCode: 412,
+		Body:       ioutil.NopCloser(bytes.NewBuffer(loadSample("aws_err.xml"))),
+	}
+	err := parseAwsResp(&resp, nil, nil)
+	var s3Err *S3Error
+	assert.True(errors.As(err, &s3Err))
+	assert.Equal(412, s3Err.StatusCode)
+	assert.Equal("PreconditionFailed", s3Err.Code)
+	assert.True(errors.Is(err, ErrConflict))
+	assert.False(errors.Is(err, ErrNotFound))
+
+	err = &S3Error{AwsError: AwsError{Code: "NoSuchKey"}, StatusCode: 404}
+	assert.True(errors.Is(err, ErrNotFound))
+	err = &S3Error{AwsError: AwsError{Code: "SlowDown"}, StatusCode: 503}
+	assert.True(errors.Is(err, ErrRateLimited))
+	err = &S3Error{StatusCode: 403}
+	assert.True(errors.Is(err, ErrUnauthorized))
+}
+
+func TestToS3Error(t *testing.T) {
+	assert := require.New(t)
+	reqErr := awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, "req-1")
+	wrapped := awserr.New("MultipartUpload", "upload multipart failed", reqErr)
+	err := toS3Error(wrapped)
+	var s3Err *S3Error
+	assert.True(errors.As(err, &s3Err))
+	assert.Equal("AccessDenied", s3Err.Code)
+	assert.Equal("Access Denied", s3Err.Error())
+	assert.Equal("req-1", s3Err.RequestId)
+	assert.Equal(403, s3Err.StatusCode)
+	assert.True(errors.Is(err, ErrUnauthorized))
+
+	other := errors.New("failed")
+	assert.Equal(other, toS3Error(other))
+	assert.Nil(toS3Error(nil))
+}
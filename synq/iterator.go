@@ -0,0 +1,212 @@
+package synq
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// IteratorOptions controls how a VideoIterator pages through the videos
+type IteratorOptions struct {
+	// StartPage is the first page to load (1 based), use it with Page() to
+	// resume an earlier iteration
+	StartPage int
+	// Prefetch is how many pages are requested ahead of the one being read,
+	// 0 loads every page only when it is needed
+	Prefetch int
+	// Query filters and sorts the videos on the server
+	Query VideoQuery
+}
+
+type videoPage struct {
+	number int
+	VideoList
+	err error
+}
+
+// VideoIterator streams videos page by page, so the whole catalog never has
+// to be held in memory. Videos are always returned in page order.
+//
+//	it := api.Videos("")
+//	defer it.Close()
+//	for it.Next() {
+//		video := it.Video()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type VideoIterator struct {
+	api     *ApiV2
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	url     string
+	query   url.Values
+	next    int
+	pending chan chan videoPage
+	buf     []json.RawMessage
+	page    int
+	raw     json.RawMessage
+	video   *VideoV2
+	err     error
+	done    bool
+	// loaded counts the pages read, last is set once the page in buf is the
+	// last one
+	loaded int
+	last   bool
+}
+
+// Videos returns an iterator over all videos, or the videos of accountId if it is set
+func (a *ApiV2) Videos(accountId string, options ...IteratorOptions) *VideoIterator {
+	return a.VideosCtx(context.Background(), accountId, options...)
+}
+
+func (a *ApiV2) VideosCtx(ctx context.Context, accountId string, options ...IteratorOptions) *VideoIterator {
+	path := "/videos"
+	if accountId != "" {
+		path = "/accounts/" + accountId + path
+	}
+	return a.newVideoIterator(ctx, a.getBaseUrl()+path, options...)
+}
+
+func (a *ApiV2) newVideoIterator(ctx context.Context, baseUrl string, options ...IteratorOptions) *VideoIterator {
+	var opts IteratorOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.StartPage < 1 {
+		opts.StartPage = 1
+	}
+	it := &VideoIterator{api: a, parent: ctx, url: baseUrl, query: opts.Query.Values(), next: opts.StartPage}
+	it.ctx, it.cancel = context.WithCancel(ctx)
+	if opts.Prefetch > 0 {
+		it.pending = make(chan chan videoPage, opts.Prefetch)
+		go it.prefetch()
+	}
+	return it
+}
+
+// prefetch requests pages in order until the iterator is closed, the buffer of
+// pending pages bounds how far ahead it gets
+func (it *VideoIterator) prefetch() {
+	defer close(it.pending)
+	for number := it.next; ; number++ {
+		result := make(chan videoPage, 1)
+		select {
+		case it.pending <- result:
+		case <-it.ctx.Done():
+			return
+		}
+		go func(number int) {
+			list, err := it.api.getVideoPage(it.ctx, it.url, it.query, number)
+			result <- videoPage{number: number, VideoList: list, err: err}
+		}(number)
+	}
+}
+
+func (it *VideoIterator) nextPage() videoPage {
+	if it.pending == nil {
+		number := it.next
+		it.next++
+		list, err := it.api.getVideoPage(it.ctx, it.url, it.query, number)
+		return videoPage{number: number, VideoList: list, err: err}
+	}
+	result, ok := <-it.pending
+	if !ok {
+		return videoPage{err: it.ctx.Err()}
+	}
+	return <-result
+}
+
+// Next moves to the next video, loading the next page if needed. It returns
+// false when there are no more videos or an error happened.
+func (it *VideoIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done {
+			return false
+		}
+		if it.last {
+			it.stop(nil)
+			return false
+		}
+		if err := it.parent.Err(); err != nil {
+			it.stop(err)
+			return false
+		}
+		page := it.nextPage()
+		if page.err != nil {
+			// report the caller's own cancellation as is
+			if err := it.parent.Err(); err != nil {
+				page.err = err
+			}
+			it.stop(page.err)
+			return false
+		}
+		if len(page.Videos) == 0 {
+			it.stop(nil)
+			return false
+		}
+		if it.loaded++; it.loaded > it.api.maxPages() {
+			it.stop(it.api.tooManyPages(it.url))
+			return false
+		}
+		it.last = lastPage(len(page.Videos), page.PageSize)
+		it.page = page.number
+		it.buf = page.Videos
+	}
+	it.raw = it.buf[0]
+	it.buf = it.buf[1:]
+	it.video = nil
+	return true
+}
+
+func (it *VideoIterator) stop(err error) {
+	it.done = true
+	it.err = err
+	it.buf = nil
+	it.cancel()
+}
+
+// Video returns the current video, if it can not be parsed the iteration
+// stops and Err returns why
+func (it *VideoIterator) Video() VideoV2 {
+	if it.video == nil {
+		it.video = &VideoV2{}
+		if err := json.Unmarshal(it.raw, it.video); err != nil {
+			it.stop(err)
+		}
+		it.video.Api = it.api
+	}
+	return *it.video
+}
+
+// Raw returns the json of the current video
+func (it *VideoIterator) Raw() json.RawMessage {
+	return it.raw
+}
+
+// Page returns the page number of the current video
+func (it *VideoIterator) Page() int {
+	return it.page
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *VideoIterator) Err() error {
+	return it.err
+}
+
+// Close stops any prefetching, it is safe to call more than once
+func (it *VideoIterator) Close() {
+	if !it.done {
+		it.stop(nil)
+	}
+}
+
+func (a *ApiV2) getVideoPage(ctx context.Context, baseUrl string, query url.Values, pageNumber int) (VideoList, error) {
+	var obj VideoList
+	req, err := a.makeRequestCtx(ctx, "GET", pageUrl(baseUrl, query, pageNumber, a.PageSize), nil)
+	if err != nil {
+		return obj, err
+	}
+	err = handleReq(a, req, &obj)
+	return obj, err
+}
@@ -0,0 +1,150 @@
+package synq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/stretchr/testify/require"
+)
+
+func collectIds(it *VideoIterator) (ids []string) {
+	for it.Next() {
+		ids = append(ids, it.Video().Id)
+	}
+	return ids
+}
+
+func expectedIds(from, pages, perPage int) (ids []string) {
+	for p := from; p <= pages; p++ {
+		for i := 0; i < perPage; i++ {
+			ids = append(ids, test_server.VideoPageId(p, i))
+		}
+	}
+	return ids
+}
+
+func TestVideoIterator(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	it := api.Videos("")
+	assert.True(it.Next())
+	assert.Equal(testVideoIdV2, it.Video().Id)
+	assert.Equal(1, it.Page())
+	assert.NotNil(it.Video().Api)
+	assert.True(it.Next())
+	assert.Equal(testVideoId2V2, it.Video().Id)
+	assert.False(it.Next())
+	assert.Nil(it.Err())
+	assert.False(it.Next())
+
+	testServer.Reset()
+	testServer.SetVideoPages(5, 3)
+	api.PageSize = 3
+	it = api.Videos("")
+	assert.Equal(expectedIds(1, 5, 3), collectIds(it))
+	assert.Nil(it.Err())
+	reqs, _ := testServer.GetReqs()
+	// the 6th page is empty
+	assert.Len(reqs, 6)
+
+	// a short page ends the list
+	testServer.Reset()
+	testServer.SetVideoPages(5, 3)
+	api.PageSize = 4
+	it = api.Videos("")
+	assert.Equal(expectedIds(1, 1, 3), collectIds(it))
+	assert.Nil(it.Err())
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 1)
+
+	// lists that do not end give up after MaxPages
+	testServer.Reset()
+	testServer.SetVideoPages(5, 3)
+	api.PageSize = 3
+	api.MaxPages = 2
+	it = api.Videos("")
+	assert.Equal(expectedIds(1, 2, 3), collectIds(it))
+	assert.Contains(it.Err().Error(), "returned more than 2 pages")
+}
+
+func TestVideoIteratorPrefetch(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	testServer.SetVideoPages(10, 2)
+	api.PageSize = 2
+	testServer.SetDelay(20 * time.Millisecond)
+	it := api.Videos("", IteratorOptions{Prefetch: 3})
+	defer it.Close()
+	assert.Equal(expectedIds(1, 10, 2), collectIds(it))
+	assert.Nil(it.Err())
+	assert.True(testServer.MaxInFlight() > 1)
+	// the page being read plus 3 ahead
+	assert.True(testServer.MaxInFlight() <= 4)
+}
+
+func TestVideoIteratorResume(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	testServer.SetVideoPages(4, 2)
+	api.PageSize = 2
+	for _, prefetch := range []int{0, 2} {
+		it := api.Videos(test_server.ACCOUNT_ID, IteratorOptions{StartPage: 3, Prefetch: prefetch})
+		assert.True(it.Next())
+		assert.Equal(3, it.Page())
+		assert.Equal(test_server.VideoPageId(3, 0), it.Video().Id)
+		ids := append([]string{it.Video().Id}, collectIds(it)...)
+		assert.Equal(expectedIds(3, 4, 2), ids)
+		it.Close()
+	}
+	reqs, _ := testServer.GetReqs()
+	assert.Contains(reqs[0].URL.Path, "/accounts/"+test_server.ACCOUNT_ID+"/videos")
+	assert.Equal("3", reqs[0].URL.Query().Get("page_number"))
+}
+
+func TestVideoIteratorError(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	for _, prefetch := range []int{0, 2} {
+		// fail every request, prefetched pages may arrive in any order
+		testServer.Reset()
+		testServer.SetVideoPages(3, 2)
+		testServer.FailNext(10, 500, "")
+		it := api.Videos("", IteratorOptions{Prefetch: prefetch})
+		assert.False(it.Next())
+		var apiErr *APIError
+		assert.True(errors.As(it.Err(), &apiErr))
+		assert.Equal(500, apiErr.StatusCode)
+		it.Close()
+	}
+}
+
+func TestVideoIteratorCancel(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	testServer.SetVideoPages(5, 2)
+	api.PageSize = 2
+	for _, prefetch := range []int{0, 2} {
+		ctx, cancel := context.WithCancel(context.Background())
+		it := api.VideosCtx(ctx, "", IteratorOptions{Prefetch: prefetch})
+		assert.True(it.Next())
+		cancel()
+		assert.True(it.Next())
+		assert.False(it.Next())
+		assert.Equal(context.Canceled, it.Err())
+	}
+
+	it := api.Videos("", IteratorOptions{Prefetch: 2})
+	assert.True(it.Next())
+	it.Close()
+	it.Close()
+	assert.False(it.Next())
+	assert.Nil(it.Err())
+}
@@ -0,0 +1,309 @@
+package synq
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/metadata"
+	"github.com/buger/jsonparser"
+)
+
+// GetMetaData decodes the video's metadata
+func (v VideoV2) GetMetaData() (meta metadata.MetaData, err error) {
+	if len(v.Metadata) == 0 || string(v.Metadata) == "null" {
+		return meta, nil
+	}
+	err = json.Unmarshal(v.Metadata, &meta)
+	return meta, err
+}
+
+// SetMetaData stores meta as the video's metadata, keys in the existing
+// metadata that metadata.MetaData does not know about are kept (also inside
+// objects it knows, like series, and the items of lists like credits) and
+// the ones it leaves out when empty are removed. Call Update to save it.
+func (v *VideoV2) SetMetaData(meta metadata.MetaData) error {
+	current, err := decodeDoc(v.Metadata)
+	if err != nil {
+		return err
+	}
+	fields, _ := current.(map[string]interface{})
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	decoded, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	updated := decoded.(map[string]interface{})
+	for key, value := range updated {
+		// don't add empty values for fields the metadata never had
+		if _, ok := fields[key]; !ok && isEmptyJSON(rawJSON(value)) {
+			delete(updated, key)
+		}
+	}
+	v.Metadata, err = json.Marshal(keepUnknown(current, updated, reflect.TypeOf(meta)))
+	return err
+}
+
+// keepUnknown adds the keys of before that t does not have a field for to
+// after (which is a t encoded as json), at any depth. Known keys that after
+// leaves out stay removed, and items of lists are matched by their index.
+func keepUnknown(before, after interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		b, bok := before.(map[string]interface{})
+		a, aok := after.(map[string]interface{})
+		if !bok || !aok {
+			return after
+		}
+		known := jsonFields(t)
+		for key, value := range b {
+			field, ok := known[key]
+			if !ok {
+				a[key] = value
+			} else if v, ok := a[key]; ok {
+				a[key] = keepUnknown(value, v, field)
+			}
+		}
+		return a
+	case reflect.Slice, reflect.Array:
+		b, bok := before.([]interface{})
+		a, aok := after.([]interface{})
+		if !bok || !aok {
+			return after
+		}
+		for i := range a {
+			if i < len(b) {
+				a[i] = keepUnknown(b[i], a[i], t.Elem())
+			}
+		}
+		return a
+	}
+	return after
+}
+
+// jsonFields returns the type of every json key of the struct type t
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			// unexported
+			continue
+		}
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		if name != "-" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+	return fields
+}
+
+func isEmptyJSON(value json.RawMessage) bool {
+	switch string(value) {
+	case "null", `""`, "false", "0", "[]", "{}":
+		return true
+	}
+	return false
+}
+
+func (v *VideoV2) changeMetaData(change func(*metadata.MetaData)) error {
+	meta, err := v.GetMetaData()
+	if err != nil {
+		return err
+	}
+	change(&meta)
+	return v.SetMetaData(meta)
+}
+
+// GetTitle returns the title in lang, falling back to the original title
+func (v VideoV2) GetTitle(lang string) (string, error) {
+	meta, err := v.GetMetaData()
+	if err != nil {
+		return "", err
+	}
+	title, _ := metadata.DefaultResolver.Title(meta, lang)
+	return title.Value, nil
+}
+
+func (v *VideoV2) SetTitle(lang, title string) error {
+	return v.changeMetaData(func(meta *metadata.MetaData) {
+		if meta.Title == nil {
+			meta.Title = metadata.LanguageList{}
+		}
+		meta.Title.Set(lang, metadata.CONTENT, title)
+	})
+}
+
+// GetDescription returns the description in lang with the given size
+// (metadata.DESCRIPTION_SHORT etc), if size is "" the longest one is returned
+func (v VideoV2) GetDescription(lang, size string) (string, error) {
+	meta, err := v.GetMetaData()
+	if err != nil {
+		return "", err
+	}
+	if size != "" {
+		return meta.Description.Get(lang, size), nil
+	}
+	for _, s := range metadata.DESCRIPTION_SIZES {
+		if desc := meta.Description.Get(lang, s); desc != "" {
+			return desc, nil
+		}
+	}
+	return "", nil
+}
+
+func (v *VideoV2) SetDescription(lang, size, description string) error {
+	return v.changeMetaData(func(meta *metadata.MetaData) {
+		if meta.Description == nil {
+			meta.Description = metadata.LanguageList{}
+		}
+		meta.Description.Set(lang, size, description)
+	})
+}
+
+func (v VideoV2) GetGenres() ([]string, error) {
+	meta, err := v.GetMetaData()
+	return meta.Genres, err
+}
+
+func (v *VideoV2) SetGenres(genres []string) error {
+	return v.changeMetaData(func(meta *metadata.MetaData) {
+		meta.Genres = genres
+	})
+}
+
+func (v VideoV2) GetSeries() (metadata.Series, error) {
+	meta, err := v.GetMetaData()
+	return meta.Series, err
+}
+
+func (v *VideoV2) SetSeries(series metadata.Series) error {
+	return v.changeMetaData(func(meta *metadata.MetaData) {
+		meta.Series = series
+	})
+}
+
+func (v VideoV2) GetCredits() ([]metadata.Credit, error) {
+	meta, err := v.GetMetaData()
+	return meta.Credits, err
+}
+
+func (v *VideoV2) SetCredits(credits []metadata.Credit) error {
+	return v.changeMetaData(func(meta *metadata.MetaData) {
+		meta.Credits = credits
+	})
+}
+
+// MissingLanguages reports which of langs the video has no title or description in
+func (v VideoV2) MissingLanguages(langs ...string) (metadata.LanguageReport, error) {
+	meta, err := v.GetMetaData()
+	if err != nil {
+		return metadata.LanguageReport{}, err
+	}
+	return metadata.MissingLanguages(meta, langs...), nil
+}
+
+// ValidateMetaData checks the video's metadata, it returns nil or metadata.ValidationErrors
+func (v VideoV2) ValidateMetaData() error {
+	meta, err := v.GetMetaData()
+	if err != nil {
+		return err
+	}
+	return meta.Validate()
+}
+
+// ScoreCompleteness scores the video's metadata (with metadata.DefaultScorer
+// unless a scorer is given) and sets CompletenessScore, which Update sends
+func (v *VideoV2) ScoreCompleteness(scorer ...metadata.Scorer) (metadata.Score, error) {
+	meta, err := v.GetMetaData()
+	if err != nil {
+		return metadata.Score{}, err
+	}
+	s := metadata.DefaultScorer
+	if len(scorer) > 0 {
+		s = scorer[0]
+	}
+	score := s.Score(meta)
+	v.CompletenessScore = score.Total
+	return score, nil
+}
+
+// probedDuration returns the duration and frame rate the video's source asset
+// was probed with, falling back to the first asset that has a duration
+func (v VideoV2) probedDuration() (time.Duration, float64, bool) {
+	var found *Asset
+	for i, asset := range v.Assets {
+		if _, err := jsonparser.GetFloat(asset.Metadata, "duration"); err != nil {
+			continue
+		}
+		if asset.Type == "source" {
+			found = &v.Assets[i]
+			break
+		}
+		if found == nil {
+			found = &v.Assets[i]
+		}
+	}
+	if found == nil {
+		return 0, 0, false
+	}
+	secs, _ := jsonparser.GetFloat(found.Metadata, "duration")
+	rate, _ := jsonparser.GetFloat(found.Metadata, "video_framerate")
+	return time.Duration(secs * float64(time.Second)), rate, true
+}
+
+// CheckDuration compares expected_duration with the duration of the video's
+// media (from its assets), it returns a metadata.ValidationError if they are
+// more than tolerance (metadata.DEFAULT_DURATION_TOLERANCE if 0) apart.
+// Nothing is checked if either is missing.
+func (v VideoV2) CheckDuration(tolerance time.Duration) error {
+	meta, err := v.GetMetaData()
+	if err != nil {
+		return err
+	}
+	probed, rate, ok := v.probedDuration()
+	if !ok {
+		return nil
+	}
+	return meta.CheckDuration(probed, rate, tolerance)
+}
+
+// GetRights returns the rights windows stored in the video's metadata (by
+// ApplyAkkaXML for example)
+func (v VideoV2) GetRights() (rights []metadata.VideoRights, err error) {
+	if len(v.Metadata) == 0 {
+		return rights, nil
+	}
+	data, dataType, _, err := jsonparser.Get(v.Metadata, "rights")
+	if err == jsonparser.KeyPathNotFoundError || dataType == jsonparser.Null {
+		return rights, nil
+	}
+	if err != nil {
+		return rights, err
+	}
+	err = json.Unmarshal(data, &rights)
+	return rights, err
+}
+
+// VideoRightsSet collects the rights of videos by id, for the queries in
+// metadata.RightsEngine
+func VideoRightsSet(videos []VideoV2) (metadata.RightsSet, error) {
+	set := metadata.RightsSet{}
+	for _, v := range videos {
+		rights, err := v.GetRights()
+		if err != nil {
+			return set, err
+		}
+		set[v.Id] = rights
+	}
+	return set, nil
+}
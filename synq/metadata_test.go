@@ -0,0 +1,171 @@
+package synq
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/metadata"
+	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/buger/jsonparser"
+	"github.com/stretchr/testify/require"
+)
+
+func loadMetadataVideo() VideoV2 {
+	resp := VideoResp{}
+	data := test_server.LoadSampleDir("41101458-bc49-40db-badc-1b480831b79b", DEFAULT_SAMPLE_DIR)
+	json.Unmarshal(data, &resp)
+	return resp.Video
+}
+
+func TestGetMetaData(t *testing.T) {
+	assert := require.New(t)
+	video := loadMetadataVideo()
+	meta, err := video.GetMetaData()
+	assert.Nil(err)
+	assert.Equal(2012, meta.Year)
+	assert.Equal("movie", meta.Type)
+	title, err := video.GetTitle("nor")
+	assert.Nil(err)
+	assert.Equal("Tears of Steel", title)
+	title, _ = video.GetTitle("eng")
+	assert.Equal("Tears of Steel", title)
+	desc, err := video.GetDescription("nor", metadata.DESCRIPTION_TINY)
+	assert.Nil(err)
+	assert.Equal("Thom just wanted to be an astronaut.", desc)
+	desc, _ = video.GetDescription("nor", "")
+	assert.Contains(desc, "40 years ago")
+	genres, err := video.GetGenres()
+	assert.Nil(err)
+	assert.Equal([]string{"Sci-Fi"}, genres)
+	credits, err := video.GetCredits()
+	assert.Nil(err)
+	assert.Len(credits, 7)
+	assert.Equal(metadata.Credit{Name: "Derek de Lint", Function: "actor"}, credits[0])
+
+	empty := VideoV2{}
+	meta, err = empty.GetMetaData()
+	assert.Nil(err)
+	assert.Equal("", meta.Type)
+	video.Metadata = json.RawMessage(`{"title":"not a language list"}`)
+	_, err = video.GetTitle("nor")
+	assert.NotNil(err)
+}
+
+func TestSetMetaData(t *testing.T) {
+	assert := require.New(t)
+	video := loadMetadataVideo()
+	original := string(video.Metadata)
+	meta, _ := video.GetMetaData()
+	assert.Nil(video.SetMetaData(meta))
+	assert.JSONEq(original, string(video.Metadata))
+
+	video.Metadata = json.RawMessage(`{"custom":{"a":1},"genres":["Drama"]}`)
+	assert.Nil(video.SetTitle("eng", "Title"))
+	assert.Nil(video.SetDescription("eng", metadata.DESCRIPTION_SHORT, "Short"))
+	assert.Nil(video.SetGenres([]string{"Comedy"}))
+	assert.Nil(video.SetSeries(metadata.Series{Season: 2, Episode: 3}))
+	assert.Nil(video.SetCredits([]metadata.Credit{{Name: "Someone", Function: "director"}}))
+	assert.JSONEq(`{
+		"custom":{"a":1},
+		"title":{"eng":{"content":"Title"}},
+		"description":{"eng":{"content-short":"Short"}},
+		"genres":["Comedy"],
+		"series":{"season":2,"episode_number":3},
+		"credits":[{"name":"Someone","role":"director"}]
+	}`, string(video.Metadata))
+	series, err := video.GetSeries()
+	assert.Nil(err)
+	assert.Equal(3, series.Episode)
+
+	// fields that were set can be cleared
+	assert.Nil(video.SetGenres(nil))
+	assert.Contains(string(video.Metadata), `"genres":null`)
+	// including the ones left out when empty
+	video.Metadata = json.RawMessage(`{"custom":{"a":1},"production_year":2012,"imdb_url":"http://imdb"}`)
+	meta, _ = video.GetMetaData()
+	meta.Year = 0
+	meta.ImdbUrl = ""
+	assert.Nil(video.SetMetaData(meta))
+	assert.JSONEq(`{"custom":{"a":1}}`, string(video.Metadata))
+
+	// unknown keys inside known objects and list items are kept too
+	video.Metadata = json.RawMessage(`{
+		"series":{"season":1,"episode_number":2,"arc":"pilot"},
+		"credits":[{"name":"A","role":"actor","agency":"x"},{"name":"B","role":"director"}]
+	}`)
+	meta, _ = video.GetMetaData()
+	meta.Series.Season = 0
+	meta.Credits[0].Name = "C"
+	meta.Credits = meta.Credits[:1]
+	assert.Nil(video.SetMetaData(meta))
+	assert.JSONEq(`{
+		"series":{"episode_number":2,"arc":"pilot"},
+		"credits":[{"name":"C","role":"actor","agency":"x"}]
+	}`, string(video.Metadata))
+}
+
+func TestVideoMissingLanguages(t *testing.T) {
+	assert := require.New(t)
+	video := loadMetadataVideo()
+	report, err := video.MissingLanguages("nb-NO", "eng")
+	assert.Nil(err)
+	assert.Equal([]string{"eng"}, report.Title)
+	assert.Equal([]string{"eng"}, report.Description)
+	title, _ := video.GetTitle("nb")
+	assert.Equal("Tears of Steel", title)
+}
+
+func TestVideoScoreCompleteness(t *testing.T) {
+	assert := require.New(t)
+	video := loadMetadataVideo()
+	assert.Nil(video.ValidateMetaData())
+	score, err := video.ScoreCompleteness()
+	assert.Nil(err)
+	// only original_language is missing
+	assert.Equal(94.4, score.Total)
+	assert.Equal(94.4, video.CompletenessScore)
+	score, _ = video.ScoreCompleteness(metadata.Scorer{Weights: map[string]float64{"original_language": 1}})
+	assert.Equal(0.0, video.CompletenessScore)
+	assert.Equal("missing", score.Fields[0].Reason)
+	video.Metadata = json.RawMessage(`{"type":"podcast"}`)
+	assert.NotNil(video.ValidateMetaData())
+}
+
+func TestVideoCheckDuration(t *testing.T) {
+	assert := require.New(t)
+	video := loadMetadataVideo()
+	assert.Nil(video.CheckDuration(0))
+	video.Metadata, _ = jsonparser.Set(video.Metadata, []byte(`"00:11:00:00"`), "expected_duration")
+	err := video.CheckDuration(0)
+	var verr metadata.ValidationError
+	assert.True(errors.As(err, &verr))
+	assert.Equal("expected_duration", verr.Field)
+	assert.Nil(video.CheckDuration(2 * time.Minute))
+	// nothing to compare with
+	video.Assets = nil
+	assert.Nil(video.CheckDuration(0))
+}
+
+func TestVideoRights(t *testing.T) {
+	assert := require.New(t)
+	videos := []VideoV2{
+		{Id: "a", Metadata: json.RawMessage(`{"rights":[{"valid_from":"2018-01-01","valid_to":"2018-01-05","unlimited":false,"devices":["web"]}]}`)},
+		{Id: "b", Metadata: json.RawMessage(`{"rights":null}`)},
+		{Id: "c"},
+	}
+	rights, err := videos[0].GetRights()
+	assert.Nil(err)
+	assert.Len(rights, 1)
+	set, err := VideoRightsSet(videos)
+	assert.Nil(err)
+	assert.Len(set, 3)
+	ids, err := metadata.DefaultRightsEngine.PlayableVideos(set, "web", time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC))
+	assert.Nil(err)
+	// no rights at all is never playable
+	assert.Equal([]string{"a"}, ids)
+	videos[0].Metadata = json.RawMessage(`{"rights":"all"}`)
+	_, err = VideoRightsSet(videos)
+	assert.NotNil(err)
+}
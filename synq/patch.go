@@ -0,0 +1,576 @@
+package synq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SYNQfm/helpers/common"
+)
+
+// Change is one difference between two json documents, Path is a json pointer
+// (RFC 6901) and Op is "add", "remove" or "replace"
+type Change struct {
+	Op   string          `json:"op"`
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// JSONPatchOp is a single RFC 6902 operation
+type JSONPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeDoc decodes a metadata or user_data document, a missing one is empty
+func decodeDoc(doc json.RawMessage) (interface{}, error) {
+	trimmed := bytes.TrimSpace(doc)
+	if len(trimmed) == 0 || string(trimmed) == "null" {
+		return map[string]interface{}{}, nil
+	}
+	return decodeJSON(trimmed)
+}
+
+// ApplyPatch applies patch to doc and returns the result. A patch that is a
+// json array is a list of RFC 6902 operations, anything else is a RFC 7396
+// merge patch.
+func ApplyPatch(doc json.RawMessage, patch json.RawMessage) (json.RawMessage, error) {
+	target, err := decodeDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(patch)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var ops []JSONPatchOp
+		if err := json.Unmarshal(trimmed, &ops); err != nil {
+			return nil, err
+		}
+		target, err = applyOps(target, ops)
+	} else {
+		var p interface{}
+		p, err = decodeJSON(trimmed)
+		if err == nil {
+			target = mergePatch(target, p)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(target)
+}
+
+// mergePatch implements RFC 7396
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+func applyOps(doc interface{}, ops []JSONPatchOp) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = applyOp(doc, op)
+		if err != nil {
+			return nil, common.NewError("patch operation %d (%s %s) failed : %s", i, op.Op, op.Path, err.Error())
+		}
+	}
+	return doc, nil
+}
+
+func applyOp(doc interface{}, op JSONPatchOp) (interface{}, error) {
+	path, err := pointerTokens(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, errors.New("missing value")
+		}
+		value, err := decodeJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "test" {
+			current, err := getValue(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !jsonEqual(current, value) {
+				return nil, errors.New("test failed")
+			}
+			return doc, nil
+		}
+		if op.Op == "replace" {
+			if doc, _, err = removeValue(doc, path); err != nil {
+				return nil, err
+			}
+		}
+		return addValue(doc, path, value)
+	case "remove":
+		doc, _, err = removeValue(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := pointerTokens(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, errors.New("can not move a value into itself")
+			}
+			doc, value, err = removeValue(doc, from)
+		} else {
+			value, err = getValue(doc, from)
+			if err == nil {
+				// the copy must not share maps or slices with the original
+				data, _ := json.Marshal(value)
+				value, err = decodeJSON(data)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	}
+	return nil, common.NewError("unknown op '%s'", op.Op)
+}
+
+// pointerTokens splits a json pointer (RFC 6901) into its reference tokens
+func pointerTokens(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, common.NewError("invalid json pointer '%s'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func escapeToken(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+func arrayIndex(token string, length int) (int, error) {
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || idx >= length || (len(token) > 1 && token[0] == '0') {
+		return 0, common.NewError("invalid array index '%s'", token)
+	}
+	return idx, nil
+}
+
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, common.NewError("'%s' not found", token)
+			}
+			doc = v
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			doc = node[idx]
+		default:
+			return nil, common.NewError("'%s' not found", token)
+		}
+	}
+	return doc, nil
+}
+
+// addValue sets value at path, returning the (possibly new) document
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, common.NewError("'%s' not found", token)
+		}
+		child, err := addValue(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []interface{}:
+		if len(path) == 1 {
+			idx := len(node)
+			if token != "-" {
+				var err error
+				// inserting at the end is allowed
+				if idx, err = arrayIndex(token, len(node)+1); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		}
+		idx, err := arrayIndex(token, len(node))
+		if err != nil {
+			return nil, err
+		}
+		child, err := addValue(node[idx], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = child
+		return node, nil
+	}
+	return nil, common.NewError("'%s' not found", token)
+}
+
+// removeValue removes the value at path, returning the new document and the removed value
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, common.NewError("'%s' not found", token)
+		}
+		if len(path) == 1 {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := removeValue(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+	case []interface{}:
+		idx, err := arrayIndex(token, len(node))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := node[idx]
+			return append(node[:idx:idx], node[idx+1:]...), removed, nil
+		}
+		child, removed, err := removeValue(node[idx], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[idx] = child
+		return node, removed, nil
+	}
+	return nil, nil, common.NewError("'%s' not found", token)
+}
+
+// jsonEqual compares two decoded documents, numbers are compared by value
+func jsonEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			other, ok := bv[k]
+			if !ok || !jsonEqual(v, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, err1 := av.Float64()
+		bf, err2 := bv.Float64()
+		return err1 == nil && err2 == nil && af == bf
+	}
+	return a == b
+}
+
+// DiffJSON returns what changed between before and after, objects are compared
+// key by key and anything else (including arrays) is replaced as a whole
+func DiffJSON(before json.RawMessage, after json.RawMessage) ([]Change, error) {
+	b, err := decodeDoc(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := decodeDoc(after)
+	if err != nil {
+		return nil, err
+	}
+	return diffValues("", b, a), nil
+}
+
+func rawJSON(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func diffValues(path string, before, after interface{}) (changes []Change) {
+	b, bok := before.(map[string]interface{})
+	a, aok := after.(map[string]interface{})
+	if !bok || !aok {
+		if !jsonEqual(before, after) {
+			changes = append(changes, Change{Op: "replace", Path: path, Old: rawJSON(before), New: rawJSON(after)})
+		}
+		return changes
+	}
+	keys := []string{}
+	for k := range b {
+		keys = append(keys, k)
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := path + "/" + escapeToken(k)
+		bv, inBefore := b[k]
+		av, inAfter := a[k]
+		switch {
+		case !inAfter:
+			changes = append(changes, Change{Op: "remove", Path: p, Old: rawJSON(bv)})
+		case !inBefore:
+			changes = append(changes, Change{Op: "add", Path: p, New: rawJSON(av)})
+		default:
+			changes = append(changes, diffValues(p, bv, av)...)
+		}
+	}
+	return changes
+}
+
+// patchDoc applies patch to doc, returning the new document and what changed
+func patchDoc(doc json.RawMessage, patch json.RawMessage) (json.RawMessage, []Change, error) {
+	patched, err := ApplyPatch(doc, patch)
+	if err != nil {
+		return nil, nil, err
+	}
+	changes, err := DiffJSON(doc, patched)
+	if err != nil {
+		return nil, nil, err
+	}
+	return patched, changes, nil
+}
+
+func (c Change) String() string {
+	switch c.Op {
+	case "add":
+		return fmt.Sprintf("add %s = %s", c.Path, c.New)
+	case "remove":
+		return fmt.Sprintf("remove %s (was %s)", c.Path, c.Old)
+	}
+	return fmt.Sprintf("replace %s = %s (was %s)", c.Path, c.New, c.Old)
+}
+
+// PatchOptions controls how PatchMetadata and PatchUserdata save
+type PatchOptions struct {
+	// WithoutETag saves even if the server sent no ETag. A change someone else
+	// makes between the read and the save is then lost.
+	WithoutETag bool
+}
+
+// PatchMetadata applies patch (a RFC 7396 merge patch or a list of RFC 6902
+// operations) to the latest copy of the video's metadata on the server and
+// saves only the metadata, so changes others made since the video was loaded
+// are kept. It returns what changed, nothing is saved when the patch changes
+// nothing.
+//
+// The api has no partial update of the metadata, the whole patched document
+// is sent. So the save is made conditional on the ETag the server sent, and
+// is retried from the start if the video changed after it was read. Without
+// an ETag a *ConflictError wrapping ErrNoETag is returned, unless the save is
+// allowed with PatchOptions.WithoutETag.
+func (v *VideoV2) PatchMetadata(patch json.RawMessage, options ...PatchOptions) ([]Change, error) {
+	return v.PatchMetadataCtx(context.Background(), patch, options...)
+}
+
+func (v *VideoV2) PatchMetadataCtx(ctx context.Context, patch json.RawMessage, options ...PatchOptions) ([]Change, error) {
+	return v.patchField(ctx, "metadata", patch, options...)
+}
+
+// PatchUserdata is PatchMetadata for the video's user_data
+func (v *VideoV2) PatchUserdata(patch json.RawMessage, options ...PatchOptions) ([]Change, error) {
+	return v.PatchUserdataCtx(context.Background(), patch, options...)
+}
+
+func (v *VideoV2) PatchUserdataCtx(ctx context.Context, patch json.RawMessage, options ...PatchOptions) ([]Change, error) {
+	return v.patchField(ctx, "user_data", patch, options...)
+}
+
+func patchOptions(options []PatchOptions) PatchOptions {
+	if len(options) > 0 {
+		return options[0]
+	}
+	return PatchOptions{}
+}
+
+func (v *VideoV2) patchField(ctx context.Context, field string, patch json.RawMessage, options ...PatchOptions) ([]Change, error) {
+	opts := patchOptions(options)
+	var conflict error
+	for attempt := 0; attempt < DEFAULT_UPDATE_ATTEMPTS; attempt++ {
+		current, err := v.Api.GetVideoCtx(ctx, v.Id)
+		if err != nil {
+			return nil, err
+		}
+		doc := current.Metadata
+		if field == "user_data" {
+			doc = current.Userdata
+		}
+		patched, changes, err := patchDoc(doc, patch)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 && current.ETag == "" && !opts.WithoutETag {
+			return nil, &ConflictError{Id: v.Id, UpdatedAt: current.UpdatedAt.Format(time.RFC3339Nano), Err: ErrNoETag}
+		}
+		if len(changes) > 0 {
+			err = current.putField(ctx, field, patched)
+		}
+		if err == nil {
+			v.Metadata = current.Metadata
+			v.Userdata = current.Userdata
+			v.UpdatedAt = current.UpdatedAt
+			v.ETag = current.ETag
+			return changes, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		conflict = err
+	}
+	return nil, conflict
+}
+
+// putField saves field as doc, if the video is unchanged when it has an ETag
+func (v *VideoV2) putField(ctx context.Context, field string, doc json.RawMessage) error {
+	data, err := json.Marshal(map[string]json.RawMessage{field: doc})
+	if err != nil {
+		return err
+	}
+	url := v.GetBaseUrl() + "/videos/" + v.Id
+	req, err := v.Api.makeRequestCtx(ctx, "PUT", url, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-Match", v.ETag)
+	}
+	resp := VideoResp{}
+	header, err := handleReqHeader(v.Api, req, &resp)
+	if err != nil {
+		return err
+	}
+	v.Metadata = resp.Video.Metadata
+	v.Userdata = resp.Video.Userdata
+	if !resp.Video.UpdatedAt.IsZero() {
+		v.UpdatedAt = resp.Video.UpdatedAt
+	}
+	v.ETag = header.Get("ETag")
+	return nil
+}
+
+// PatchMetadata is VideoV2.PatchMetadata for the asset's metadata
+func (a *Asset) PatchMetadata(patch json.RawMessage, options ...PatchOptions) ([]Change, error) {
+	return a.PatchMetadataCtx(context.Background(), patch, options...)
+}
+
+func (a *Asset) PatchMetadataCtx(ctx context.Context, patch json.RawMessage, options ...PatchOptions) ([]Change, error) {
+	opts := patchOptions(options)
+	var conflict error
+	for attempt := 0; attempt < DEFAULT_UPDATE_ATTEMPTS; attempt++ {
+		current, err := a.getApi().GetAssetCtx(ctx, a.Id)
+		if err != nil {
+			return nil, err
+		}
+		patched, changes, err := patchDoc(current.Metadata, patch)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) == 0 {
+			a.Metadata = current.Metadata
+			a.UpdatedAt = current.UpdatedAt
+			a.ETag = current.ETag
+			return changes, nil
+		}
+		if current.ETag == "" && !opts.WithoutETag {
+			return nil, &ConflictError{Id: a.Id, UpdatedAt: current.UpdatedAt, Err: ErrNoETag}
+		}
+		data, err := json.Marshal(map[string]json.RawMessage{"metadata": patched})
+		if err != nil {
+			return nil, err
+		}
+		url := a.getApi().getBaseUrl() + "/assets/" + a.Id
+		err = a.handleAssetReqIf(ctx, "PUT", url, bytes.NewBuffer(data), current.ETag)
+		if err == nil {
+			return changes, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		conflict = err
+	}
+	return nil, conflict
+}
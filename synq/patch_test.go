@@ -0,0 +1,164 @@
+package synq
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	assert := require.New(t)
+	doc := json.RawMessage(`{"title":"a","tags":["x"],"info":{"year":2017,"genre":"drama"}}`)
+	patched, err := ApplyPatch(doc, json.RawMessage(`{"title":"b","tags":null,"info":{"genre":null,"lang":"en"}}`))
+	assert.Nil(err)
+	assert.JSONEq(`{"title":"b","info":{"year":2017,"lang":"en"}}`, string(patched))
+	patched, err = ApplyPatch(nil, json.RawMessage(`{"title":"new"}`))
+	assert.Nil(err)
+	assert.JSONEq(`{"title":"new"}`, string(patched))
+	_, err = ApplyPatch(doc, json.RawMessage(`{"title":`))
+	assert.NotNil(err)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	assert := require.New(t)
+	doc := json.RawMessage(`{"title":"a","tags":["x","y"],"info":{"year":2017},"a/b":1}`)
+	ops := json.RawMessage(`[
+		{"op":"test","path":"/info/year","value":2017.0},
+		{"op":"replace","path":"/title","value":"b"},
+		{"op":"add","path":"/tags/1","value":"z"},
+		{"op":"add","path":"/tags/-","value":"last"},
+		{"op":"remove","path":"/tags/0"},
+		{"op":"copy","from":"/info","path":"/copy"},
+		{"op":"add","path":"/copy/extra","value":true},
+		{"op":"move","from":"/a~1b","path":"/info/moved"}
+	]`)
+	patched, err := ApplyPatch(doc, ops)
+	assert.Nil(err)
+	assert.JSONEq(`{"title":"b","tags":["z","y","last"],"info":{"year":2017,"moved":1},"copy":{"year":2017,"extra":true}}`, string(patched))
+
+	bad := []string{
+		`[{"op":"test","path":"/title","value":"c"}]`,
+		`[{"op":"remove","path":"/missing"}]`,
+		`[{"op":"replace","path":"/missing","value":1}]`,
+		`[{"op":"add","path":"/missing/child","value":1}]`,
+		`[{"op":"add","path":"/tags/5","value":1}]`,
+		`[{"op":"add","path":"/title"}]`,
+		`[{"op":"move","from":"/info","path":"/info/child"}]`,
+		`[{"op":"unknown","path":"/title"}]`,
+		`[{"op":"add","path":"title","value":1}]`,
+	}
+	for _, b := range bad {
+		_, err = ApplyPatch(doc, json.RawMessage(b))
+		assert.NotNil(err, b)
+	}
+	// failed patches leave the original alone
+	assert.JSONEq(`{"title":"a","tags":["x","y"],"info":{"year":2017},"a/b":1}`, string(doc))
+}
+
+func TestDiffJSON(t *testing.T) {
+	assert := require.New(t)
+	changes, err := DiffJSON(json.RawMessage(`{"a":1,"b":{"c":"x","d":[1]},"e/f":true}`), json.RawMessage(`{"a":1.0,"b":{"c":"y","d":[1,2]},"g":null}`))
+	assert.Nil(err)
+	assert.Len(changes, 4)
+	assert.Equal(Change{Op: "replace", Path: "/b/c", Old: json.RawMessage(`"x"`), New: json.RawMessage(`"y"`)}, changes[0])
+	assert.Equal("replace", changes[1].Op)
+	assert.Equal("/b/d", changes[1].Path)
+	assert.Equal(Change{Op: "remove", Path: "/e~1f", Old: json.RawMessage(`true`)}, changes[2])
+	assert.Equal(Change{Op: "add", Path: "/g", New: json.RawMessage(`null`)}, changes[3])
+	changes, err = DiffJSON(nil, json.RawMessage(`{}`))
+	assert.Nil(err)
+	assert.Len(changes, 0)
+}
+
+func TestVideoPatch(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	video, err := api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	testServer.Reset()
+
+	changes, err := video.PatchUserdata(json.RawMessage(`{"type":"test"}`))
+	assert.Nil(err)
+	assert.Len(changes, 0)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 1)
+	assert.Equal("GET", reqs[0].Method)
+
+	// the whole document is sent, so without an ETag nothing is saved
+	// unless that is allowed
+	testServer.Reset()
+	patch := json.RawMessage(`[{"op":"replace","path":"/type","value":"test2"}]`)
+	_, err = video.PatchUserdata(patch)
+	assert.True(errors.Is(err, ErrConflict))
+	assert.True(errors.Is(err, ErrNoETag))
+	assert.Contains(err.Error(), "has no ETag")
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 1)
+
+	// the patch is applied to the server's copy, not the stale local one
+	testServer.Reset()
+	video.Userdata = json.RawMessage(`{"type":"stale"}`)
+	changes, err = video.PatchUserdata(patch, PatchOptions{WithoutETag: true})
+	assert.Nil(err)
+	assert.Equal([]Change{{Op: "replace", Path: "/type", Old: json.RawMessage(`"test"`), New: json.RawMessage(`"test2"`)}}, changes)
+	reqs, values := testServer.GetReqs()
+	assert.Len(reqs, 2)
+	assert.Equal("PUT", reqs[1].Method)
+	assert.JSONEq(`{"user_data":{"type":"test2","description":"test in postman"}}`, values[1].Get("body"))
+	assert.JSONEq(`{"type": "test2", "description": "test in postman2"}`, string(video.Userdata))
+
+	testServer.Reset()
+	testServer.EnableETags()
+	video, _ = api.GetVideo(testVideoIdV2)
+	// someone else changes the video, the patch is made on top of their version
+	testServer.Touch(testVideoIdV2)
+	changes, err = video.PatchMetadata(json.RawMessage(`{"title":"patched"}`))
+	assert.Nil(err)
+	assert.Equal([]Change{{Op: "add", Path: "/title", New: json.RawMessage(`"patched"`)}}, changes)
+	reqs, values = testServer.GetReqs()
+	assert.Len(reqs, 3)
+	assert.Equal(`"1"`, reqs[2].Header.Get("If-Match"))
+	assert.JSONEq(`{"metadata":{"title":"patched"}}`, values[2].Get("body"))
+	assert.Equal(`"2"`, video.ETag)
+
+	_, err = video.PatchMetadata(json.RawMessage(`[{"op":"remove","path":"/missing"}]`))
+	assert.NotNil(err)
+}
+
+func TestAssetPatch(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	asset, err := api.GetAsset(testAssetId)
+	assert.Nil(err)
+	testServer.Reset()
+	_, err = asset.PatchMetadata(json.RawMessage(`{"test":true}`))
+	assert.True(errors.Is(err, ErrNoETag))
+	reqs, _ := testServer.GetReqs()
+	for _, req := range reqs {
+		assert.Equal("GET", req.Method)
+	}
+
+	testServer.Reset()
+	changes, err := asset.PatchMetadata(json.RawMessage(`{"test":true}`), PatchOptions{WithoutETag: true})
+	assert.Nil(err)
+	assert.Equal([]Change{{Op: "add", Path: "/test", New: json.RawMessage(`true`)}}, changes)
+	reqs, values := testServer.GetReqs()
+	last := len(reqs) - 1
+	assert.Equal("PUT", reqs[last].Method)
+	assert.JSONEq(`{"metadata":{"test":true}}`, values[last].Get("body"))
+	assert.JSONEq(`{"test":true}`, string(asset.Metadata))
+
+	// with an ETag the save is conditional
+	testServer.Reset()
+	testServer.EnableETags()
+	_, err = asset.PatchMetadata(json.RawMessage(`{"other":true}`))
+	assert.Nil(err)
+	reqs, _ = testServer.GetReqs()
+	last = len(reqs) - 1
+	assert.Equal("PUT", reqs[last].Method)
+	assert.Equal(`"0"`, reqs[last].Header.Get("If-Match"))
+}
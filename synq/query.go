@@ -0,0 +1,125 @@
+package synq
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SYNQfm/helpers/common"
+)
+
+type SortOrder string
+
+const (
+	SORT_ASC  SortOrder = "asc"
+	SORT_DESC SortOrder = "desc"
+)
+
+// VideoQuery filters and sorts the videos returned by GetVideos and Videos,
+// zero values are not sent
+type VideoQuery struct {
+	UpdatedSince  time.Time
+	UpdatedBefore time.Time
+	CreatedSince  time.Time
+	CreatedBefore time.Time
+	// State and Type only return videos with an asset in that state or of
+	// that type
+	State string
+	Type  string
+	// VideoIds only returns these videos
+	VideoIds []string
+	// SortBy is the field to sort on, like "updated_at"
+	SortBy string
+	Order  SortOrder
+}
+
+// AssetQuery filters and sorts the assets returned by GetAssetList, zero
+// values are not sent
+type AssetQuery struct {
+	UpdatedSince  time.Time
+	UpdatedBefore time.Time
+	CreatedSince  time.Time
+	CreatedBefore time.Time
+	State         string
+	Type          string
+	AccountId     string
+	VideoId       string
+	// SortBy is the field to sort on, like "updated_at"
+	SortBy string
+	Order  SortOrder
+}
+
+func setTime(v url.Values, key string, t time.Time) {
+	if !t.IsZero() {
+		v.Set(key, t.UTC().Format(time.RFC3339Nano))
+	}
+}
+
+func setString(v url.Values, key string, value string) {
+	if value != "" {
+		v.Set(key, value)
+	}
+}
+
+// Values returns the query as url parameters
+func (q VideoQuery) Values() url.Values {
+	v := url.Values{}
+	setTime(v, "updated_since", q.UpdatedSince)
+	setTime(v, "updated_before", q.UpdatedBefore)
+	setTime(v, "created_since", q.CreatedSince)
+	setTime(v, "created_before", q.CreatedBefore)
+	setString(v, "state", q.State)
+	setString(v, "type", q.Type)
+	setString(v, "video_id", strings.Join(q.VideoIds, ","))
+	setString(v, "sort_by", q.SortBy)
+	setString(v, "sort_order", string(q.Order))
+	return v
+}
+
+// Values returns the query as url parameters
+func (q AssetQuery) Values() url.Values {
+	v := url.Values{}
+	setTime(v, "updated_since", q.UpdatedSince)
+	setTime(v, "updated_before", q.UpdatedBefore)
+	setTime(v, "created_since", q.CreatedSince)
+	setTime(v, "created_before", q.CreatedBefore)
+	setString(v, "state", q.State)
+	setString(v, "type", q.Type)
+	setString(v, "account_id", q.AccountId)
+	setString(v, "video_id", q.VideoId)
+	setString(v, "sort_by", q.SortBy)
+	setString(v, "sort_order", string(q.Order))
+	return v
+}
+
+// lastPage returns true if a page of count items ends a list, which is when it
+// is empty or shorter than the page_size the server returned. The server may
+// cap the page size that was asked for, so without a page_size only an empty
+// page ends the list.
+func lastPage(count, pageSize int) bool {
+	return count == 0 || count < pageSize
+}
+
+func (a *ApiV2) maxPages() int {
+	if a.MaxPages <= 0 {
+		return DEFAULT_MAX_PAGES
+	}
+	return a.MaxPages
+}
+
+// tooManyPages is the error for a list that did not end after maxPages pages
+func (a *ApiV2) tooManyPages(url string) error {
+	return common.NewError("'%s' returned more than %d pages", url, a.maxPages())
+}
+
+// pageUrl adds the paging parameters to the query
+func pageUrl(baseUrl string, query url.Values, pageNumber, pageSize int) string {
+	v := url.Values{}
+	for key, values := range query {
+		v[key] = values
+	}
+	v.Set("page_number", strconv.Itoa(pageNumber))
+	v.Set("page_size", strconv.Itoa(pageSize))
+	return baseUrl + "?" + v.Encode()
+}
@@ -0,0 +1,126 @@
+package synq
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryValues(t *testing.T) {
+	assert := require.New(t)
+	assert.Len(VideoQuery{}.Values(), 0)
+	assert.Len(AssetQuery{}.Values(), 0)
+
+	since := time.Date(2018, 3, 20, 23, 50, 22, 0, time.FixedZone("test", 3600))
+	v := VideoQuery{UpdatedSince: since, SortBy: "updated_at", Order: SORT_DESC}.Values()
+	assert.Len(v, 3)
+	assert.Equal("2018-03-20T22:50:22Z", v.Get("updated_since"))
+	assert.Equal("updated_at", v.Get("sort_by"))
+	assert.Equal("desc", v.Get("sort_order"))
+	v = VideoQuery{State: "uploaded", Type: "hls", VideoIds: []string{testVideoIdV2, testVideoId2V2}}.Values()
+	assert.Len(v, 3)
+	assert.Equal("uploaded", v.Get("state"))
+	assert.Equal("hls", v.Get("type"))
+	assert.Equal(testVideoIdV2+","+testVideoId2V2, v.Get("video_id"))
+
+	v = AssetQuery{
+		CreatedBefore: since,
+		State:         "created",
+		Type:          "mp4",
+		AccountId:     "account",
+		VideoId:       testVideoIdV2,
+		Order:         SORT_ASC,
+	}.Values()
+	assert.Len(v, 6)
+	assert.Equal("2018-03-20T22:50:22Z", v.Get("created_before"))
+	assert.Equal("created", v.Get("state"))
+	assert.Equal("mp4", v.Get("type"))
+	assert.Equal("account", v.Get("account_id"))
+	assert.Equal(testVideoIdV2, v.Get("video_id"))
+	assert.Equal("asc", v.Get("sort_order"))
+}
+
+func TestGetVideosQuery(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	since := time.Now().Add(-time.Hour)
+	videos, err := api.GetVideos("", VideoQuery{UpdatedSince: since, SortBy: "updated_at"})
+	assert.Nil(err)
+	assert.Len(videos, 2)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 1)
+	for i, req := range reqs {
+		q := req.URL.Query()
+		assert.Equal(since.UTC().Format(time.RFC3339Nano), q.Get("updated_since"))
+		assert.Equal("updated_at", q.Get("sort_by"))
+		assert.Equal(strconv.Itoa(i+1), q.Get("page_number"))
+		assert.Equal("100", q.Get("page_size"))
+	}
+}
+
+func TestGetAssetListQuery(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	assets, err := api.GetAssetList(AssetQuery{State: "created", VideoId: testVideoIdV2})
+	assert.Nil(err)
+	assert.Len(assets, 1)
+	assert.Equal("created", assets[0].State)
+	reqs, _ := testServer.GetReqs()
+	// the first page is short, so it is the last one
+	assert.Len(reqs, 1)
+	q := reqs[0].URL.Query()
+	assert.Equal("created", q.Get("state"))
+	assert.Equal(testVideoIdV2, q.Get("video_id"))
+	assert.Equal("1", q.Get("page_number"))
+}
+
+func TestListsCappedPageSize(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	// the server sends 1 item per page whatever page size is asked for, a
+	// page shorter than the one asked for does not end the list
+	testServer.CapPageSize(1)
+	accounts, err := api.GetAccountList()
+	assert.Nil(err)
+	assert.Len(accounts, 2)
+	assert.Equal(test_server.DISTRIBUTOR_ID, accounts[1].Id)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 3)
+	assert.Equal("100", reqs[2].URL.Query().Get("page_size"))
+
+	testServer.Reset()
+	testServer.CapPageSize(1)
+	assets, err := api.GetAssetList()
+	assert.Nil(err)
+	assert.Len(assets, 1)
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 2)
+
+	testServer.Reset()
+	testServer.CapPageSize(1)
+	settings, err := api.GetSettingsList()
+	assert.Nil(err)
+	assert.Len(settings, 1)
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 2)
+
+	testServer.Reset()
+	testServer.CapPageSize(1)
+	it := api.Videos("")
+	assert.Equal([]string{testVideoIdV2, testVideoId2V2}, collectIds(it))
+	assert.Nil(it.Err())
+
+	testServer.Reset()
+	testServer.SetVideoPages(5, 3)
+	testServer.CapPageSize(3)
+	it = api.Videos("", IteratorOptions{Prefetch: 2})
+	assert.Equal(expectedIds(1, 5, 3), collectIds(it))
+	assert.Nil(it.Err())
+	it.Close()
+}
@@ -0,0 +1,217 @@
+package synq
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	DEFAULT_RETRY_ATTEMPTS = 3
+	DEFAULT_RETRY_BASE_MS  = 500
+	DEFAULT_RETRY_MAX_MS   = 30000 // 30 seconds
+)
+
+// RetryPolicy controls how requests that fail with a transient error
+// (429, 502, 503, 504 or a network error) are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Anything less than 2 disables retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt, it doubles for every
+	// attempt after that (with jitter) up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// POST requests (like Create) are not idempotent, so they are only retried
+	// when this is set
+	RetryPost bool
+	// OnAttempt is called after every attempt, whether it will be retried or not
+	OnAttempt func(Attempt)
+}
+
+// Attempt describes a single try of a request
+type Attempt struct {
+	Method     string
+	Url        string
+	Number     int
+	StatusCode int
+	Err        error
+	// Retry is set when another attempt will be made after waiting Delay
+	Retry bool
+	Delay time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DEFAULT_RETRY_ATTEMPTS,
+		BaseDelay:   time.Duration(DEFAULT_RETRY_BASE_MS) * time.Millisecond,
+		MaxDelay:    time.Duration(DEFAULT_RETRY_MAX_MS) * time.Millisecond,
+	}
+}
+
+// canRetry returns true if the request may be sent more than once
+func (p RetryPolicy) canRetry(req *http.Request) bool {
+	if p.MaxAttempts < 2 {
+		return false
+	}
+	if !replayable(req) {
+		return false
+	}
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	case "POST":
+		return p.RetryPost
+	}
+	return false
+}
+
+// replayable returns true if the request can be sent again, which needs the
+// body to be re-created for every attempt
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func retryableErr(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay after attempt n (1 based), exponential with jitter
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < n && delay < p.MaxDelay; i++ {
+		delay = delay * 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// keep half of the delay and randomize the rest
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// parseRetryAfter reads the Retry-After header, which is either a number of
+// seconds or an http date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if t.Before(now) {
+			return 0, true
+		}
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// delay returns how long to wait after attempt n, using the Retry-After header
+// when the server sent one
+func (p RetryPolicy) delay(n int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxDelay > 0 && d > p.MaxDelay {
+				return p.MaxDelay
+			}
+			return d
+		}
+	}
+	return p.backoff(n)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendWithRetry sends the request with send, retrying transient failures
+// according to the policy. The returned cancel func must be called once the
+// response body has been read.
+func sendWithRetry(p RetryPolicy, req *http.Request, send func(*http.Request) (*http.Response, context.CancelFunc, error)) (*http.Response, context.CancelFunc, error) {
+	retry := p.canRetry(req)
+	for n := 1; ; n++ {
+		if n > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			req.Body = body
+		}
+		resp, cancel, err := send(req)
+		attempt := Attempt{Method: req.Method, Url: req.URL.String(), Number: n, Err: err}
+		if resp != nil {
+			attempt.StatusCode = resp.StatusCode
+		}
+		if retry && n < p.MaxAttempts && req.Context().Err() == nil {
+			if err != nil {
+				attempt.Retry = retryableErr(err)
+			} else {
+				attempt.Retry = retryableStatus(resp.StatusCode)
+			}
+		}
+		if attempt.Retry {
+			attempt.Delay = p.delay(n, resp)
+		}
+		if p.OnAttempt != nil {
+			p.OnAttempt(attempt)
+		}
+		if !attempt.Retry {
+			return resp, cancel, err
+		}
+		if resp != nil {
+			// drain the body so the connection can be re-used
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+		if err := sleepCtx(req.Context(), attempt.Delay); err != nil {
+			return nil, func() {}, err
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package synq
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setupRetryApi(policy RetryPolicy) (ApiV2, *[]Attempt) {
+	api := setupTestApiV2(testAuth)
+	attempts := []Attempt{}
+	policy.OnAttempt = func(a Attempt) {
+		attempts = append(attempts, a)
+	}
+	api.SetRetryPolicy(policy)
+	return api, &attempts
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := require.New(t)
+	now := time.Now()
+	_, ok := parseRetryAfter("", now)
+	assert.False(ok)
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(ok)
+	d, ok := parseRetryAfter("3", now)
+	assert.True(ok)
+	assert.Equal(3*time.Second, d)
+	date := now.Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	d, ok = parseRetryAfter(date, now)
+	assert.True(ok)
+	assert.True(d > 8*time.Second && d <= 10*time.Second)
+	d, ok = parseRetryAfter(now.Add(-time.Hour).UTC().Format(http.TimeFormat), now)
+	assert.True(ok)
+	assert.Equal(time.Duration(0), d)
+}
+
+func TestBackoff(t *testing.T) {
+	assert := require.New(t)
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for i := 0; i < 20; i++ {
+		d := p.backoff(1)
+		assert.True(d >= 50*time.Millisecond && d <= 100*time.Millisecond)
+		d = p.backoff(3)
+		assert.True(d >= 200*time.Millisecond && d <= 400*time.Millisecond)
+		d = p.backoff(10)
+		assert.True(d >= 500*time.Millisecond && d <= time.Second)
+	}
+	assert.Equal(time.Duration(0), RetryPolicy{}.backoff(2))
+}
+
+func TestCanRetry(t *testing.T) {
+	assert := require.New(t)
+	p := DefaultRetryPolicy()
+	get, _ := http.NewRequest("GET", "http://test", nil)
+	put, _ := http.NewRequest("PUT", "http://test", strings.NewReader("{}"))
+	post, _ := http.NewRequest("POST", "http://test", strings.NewReader("{}"))
+	assert.True(p.canRetry(get))
+	assert.True(p.canRetry(put))
+	assert.False(p.canRetry(post))
+	p.RetryPost = true
+	assert.True(p.canRetry(post))
+	put.GetBody = nil
+	assert.False(p.canRetry(put))
+	assert.False(RetryPolicy{MaxAttempts: 1}.canRetry(get))
+}
+
+func TestRetryGet(t *testing.T) {
+	assert := require.New(t)
+	api, attempts := setupRetryApi(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	testServer.FailNext(1, http.StatusServiceUnavailable, "")
+	testServer.FailNext(1, http.StatusTooManyRequests, "0")
+	video, err := api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(testVideoIdV2, video.Id)
+	assert.Len(*attempts, 3)
+	assert.Equal(http.StatusServiceUnavailable, (*attempts)[0].StatusCode)
+	assert.True((*attempts)[0].Retry)
+	assert.Equal(time.Duration(0), (*attempts)[1].Delay)
+	assert.Equal(http.StatusOK, (*attempts)[2].StatusCode)
+	assert.False((*attempts)[2].Retry)
+
+	// give up after MaxAttempts
+	testServer.Reset()
+	*attempts = (*attempts)[:0]
+	testServer.FailNext(3, http.StatusBadGateway, "")
+	_, err = api.GetVideo(testVideoIdV2)
+	assert.NotNil(err)
+	assert.Len(*attempts, 3)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 3)
+
+	// anything but a transient failure is returned right away
+	testServer.Reset()
+	*attempts = (*attempts)[:0]
+	testServer.FailNext(1, http.StatusBadRequest, "")
+	_, err = api.GetVideo(testVideoIdV2)
+	assert.NotNil(err)
+	assert.Len(*attempts, 1)
+}
+
+func TestRetryPutBody(t *testing.T) {
+	assert := require.New(t)
+	api, attempts := setupRetryApi(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+	video := VideoV2{Id: testVideoIdV2, Api: &api, Metadata: []byte(`{"a":1}`)}
+	testServer.FailNext(1, http.StatusServiceUnavailable, "")
+	err := video.Update()
+	assert.Nil(err)
+	assert.Len(*attempts, 2)
+	_, vals := testServer.GetReqs()
+	assert.Len(vals, 1)
+	assert.Contains(vals[0].Get("body"), `"metadata":{"a":1}`)
+}
+
+func TestRetryPost(t *testing.T) {
+	assert := require.New(t)
+	api, attempts := setupRetryApi(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	testServer.FailNext(1, http.StatusServiceUnavailable, "")
+	_, err := api.Create()
+	assert.NotNil(err)
+	assert.Len(*attempts, 1)
+	assert.False((*attempts)[0].Retry)
+
+	policy := api.GetRetryPolicy()
+	policy.RetryPost = true
+	api.SetRetryPolicy(policy)
+	*attempts = (*attempts)[:0]
+	testServer.FailNext(1, http.StatusServiceUnavailable, "")
+	video, err := api.Create()
+	assert.Nil(err)
+	assert.Equal(testVideoIdV2, video.Id)
+	assert.Len(*attempts, 2)
+}
+
+func TestRetryCancel(t *testing.T) {
+	assert := require.New(t)
+	api, attempts := setupRetryApi(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	testServer.FailNext(1, http.StatusServiceUnavailable, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := api.GetVideoCtx(ctx, testVideoIdV2)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Len(*attempts, 1)
+	assert.True((*attempts)[0].Retry)
+}
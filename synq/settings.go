@@ -0,0 +1,150 @@
+package synq
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// SettingsRequest is the body used to create or update a settings profile,
+// blank fields are left unchanged on update
+type SettingsRequest struct {
+	Name   string          `json:"name,omitempty"`
+	Type   string          `json:"type,omitempty"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+type SettingsList struct {
+	Settings   []Settings `json:"data"`
+	PageSize   int        `json:"page_size"`
+	PageNumber int        `json:"page_number"`
+}
+
+// SettingsChanges is what ReconcileAssetSettings changed
+type SettingsChanges struct {
+	Added   []string
+	Removed []string
+}
+
+func (a *ApiV2) settingsUrl(id string) string {
+	return a.getBaseUrl() + "/settings/" + id
+}
+
+func (a *ApiV2) assetSettingsUrl(assetId string) string {
+	return a.getBaseUrl() + "/assets/" + assetId + "/settings"
+}
+
+// GetSettingsList returns every settings profile, loading all of the pages
+func (a *ApiV2) GetSettingsList() ([]Settings, error) {
+	return a.GetSettingsListCtx(context.Background())
+}
+
+func (a *ApiV2) GetSettingsListCtx(ctx context.Context) (settings []Settings, err error) {
+	baseUrl := a.getBaseUrl() + "/settings"
+	for pageNumber := 1; pageNumber <= a.maxPages(); pageNumber++ {
+		if err := ctx.Err(); err != nil {
+			return settings, err
+		}
+		var list SettingsList
+		err = a.handleGetCtx(ctx, pageUrl(baseUrl, nil, pageNumber, a.PageSize), &list)
+		if err != nil {
+			return settings, err
+		}
+		settings = append(settings, list.Settings...)
+		if lastPage(len(list.Settings), list.PageSize) {
+			return settings, nil
+		}
+	}
+	return settings, a.tooManyPages(baseUrl)
+}
+
+func (a *ApiV2) CreateSettings(settings SettingsRequest) (Settings, error) {
+	return a.CreateSettingsCtx(context.Background(), settings)
+}
+
+func (a *ApiV2) CreateSettingsCtx(ctx context.Context, settings SettingsRequest) (Settings, error) {
+	var resp SettingsResp
+	err := a.handleJsonCtx(ctx, "POST", a.getBaseUrl()+"/settings", settings, &resp)
+	return resp.Settings, err
+}
+
+func (a *ApiV2) UpdateSettings(id string, settings SettingsRequest) (Settings, error) {
+	return a.UpdateSettingsCtx(context.Background(), id, settings)
+}
+
+func (a *ApiV2) UpdateSettingsCtx(ctx context.Context, id string, settings SettingsRequest) (Settings, error) {
+	var resp SettingsResp
+	err := a.handleJsonCtx(ctx, "PUT", a.settingsUrl(id), settings, &resp)
+	return resp.Settings, err
+}
+
+func (a *ApiV2) DeleteSettings(id string) error {
+	return a.DeleteSettingsCtx(context.Background(), id)
+}
+
+func (a *ApiV2) DeleteSettingsCtx(ctx context.Context, id string) error {
+	return a.handleJsonCtx(ctx, "DELETE", a.settingsUrl(id), nil, nil)
+}
+
+// GetAssetSettings returns the settings attached to the asset
+func (a *ApiV2) GetAssetSettings(assetId string) ([]Settings, error) {
+	return a.GetAssetSettingsCtx(context.Background(), assetId)
+}
+
+func (a *ApiV2) GetAssetSettingsCtx(ctx context.Context, assetId string) ([]Settings, error) {
+	var list SettingsList
+	err := a.handleGetCtx(ctx, a.assetSettingsUrl(assetId), &list)
+	return list.Settings, err
+}
+
+// RemoveAssetSettings detaches the settings from the asset
+func (a *ApiV2) RemoveAssetSettings(assetId string, settingIds []string) error {
+	return a.RemoveAssetSettingsCtx(context.Background(), assetId, settingIds)
+}
+
+func (a *ApiV2) RemoveAssetSettingsCtx(ctx context.Context, assetId string, settingIds []string) error {
+	body := map[string][]string{"settings_ids": settingIds}
+	return a.handleJsonCtx(ctx, "DELETE", a.assetSettingsUrl(assetId), body, nil)
+}
+
+// ReconcileAssetSettings makes the settings of the asset exactly settingIds,
+// attaching the missing ones and detaching the rest
+func (a *ApiV2) ReconcileAssetSettings(assetId string, settingIds []string) (SettingsChanges, error) {
+	return a.ReconcileAssetSettingsCtx(context.Background(), assetId, settingIds)
+}
+
+func (a *ApiV2) ReconcileAssetSettingsCtx(ctx context.Context, assetId string, settingIds []string) (changes SettingsChanges, err error) {
+	current, err := a.GetAssetSettingsCtx(ctx, assetId)
+	if err != nil {
+		return changes, err
+	}
+	want := make(map[string]bool)
+	for _, id := range settingIds {
+		want[id] = true
+	}
+	have := make(map[string]bool)
+	for _, s := range current {
+		have[s.Id] = true
+		if !want[s.Id] {
+			changes.Removed = append(changes.Removed, s.Id)
+		}
+	}
+	for _, id := range settingIds {
+		if !have[id] {
+			changes.Added = append(changes.Added, id)
+			// skip duplicates in settingIds
+			have[id] = true
+		}
+	}
+	if len(changes.Removed) > 0 {
+		if err = a.RemoveAssetSettingsCtx(ctx, assetId, changes.Removed); err != nil {
+			return SettingsChanges{}, err
+		}
+	}
+	if len(changes.Added) > 0 {
+		if err = a.CreateAssetSettingsCtx(ctx, assetId, changes.Added); err != nil {
+			// the removals already happened
+			return SettingsChanges{Removed: changes.Removed}, err
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,88 @@
+package synq
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingsCrud(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	list, err := api.GetSettingsList()
+	assert.Nil(err)
+	assert.Len(list, 1)
+	assert.Equal(testSettingsName, list[0].Name)
+
+	config := json.RawMessage(`{"key_server":"https://drm.example.com"}`)
+	settings, err := api.CreateSettings(SettingsRequest{Name: "playready", Type: "video", Config: config})
+	assert.Nil(err)
+	assert.Equal(test_server.NEW_SETTING_ID, settings.Id)
+	assert.Equal("playready", settings.Name)
+	assert.JSONEq(string(config), string(settings.Config))
+
+	settings, err = api.UpdateSettings(test_server.SETTING_ID, SettingsRequest{Config: config})
+	assert.Nil(err)
+	assert.Equal(testSettingsName, settings.Name)
+	assert.JSONEq(string(config), string(settings.Config))
+	_, err = api.UpdateSettings(test_server.NEW_SETTING_ID, SettingsRequest{Name: "missing"})
+	assert.True(errors.Is(err, ErrNotFound))
+
+	assert.Nil(api.DeleteSettings(test_server.SETTING_ID))
+	assert.True(errors.Is(api.DeleteSettings(test_server.NEW_SETTING_ID), ErrNotFound))
+}
+
+func TestAssetSettings(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	settings, err := api.GetAssetSettings(testAssetId)
+	assert.Nil(err)
+	assert.Len(settings, 0)
+	assert.Nil(api.CreateAssetSettings(testAssetId, []string{"a", "b"}))
+	settings, err = api.GetAssetSettings(testAssetId)
+	assert.Nil(err)
+	assert.Len(settings, 2)
+	assert.Nil(api.RemoveAssetSettings(testAssetId, []string{"a"}))
+	settings, err = api.GetAssetSettings(testAssetId)
+	assert.Nil(err)
+	assert.Len(settings, 1)
+	assert.Equal("b", settings[0].Id)
+	_, err = api.GetAssetSettings("missing")
+	assert.True(errors.Is(err, ErrNotFound))
+}
+
+func TestReconcileAssetSettings(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(testAuth)
+	defer testServer.Close()
+	assert.Nil(api.CreateAssetSettings(testAssetId, []string{"a", "b"}))
+	changes, err := api.ReconcileAssetSettings(testAssetId, []string{"b", "c", "c"})
+	assert.Nil(err)
+	assert.Equal([]string{"c"}, changes.Added)
+	assert.Equal([]string{"a"}, changes.Removed)
+	settings, err := api.GetAssetSettings(testAssetId)
+	assert.Nil(err)
+	ids := []string{}
+	for _, s := range settings {
+		ids = append(ids, s.Id)
+	}
+	assert.ElementsMatch([]string{"b", "c"}, ids)
+
+	// nothing to do makes no changes
+	testServer.Reset()
+	assert.Nil(api.CreateAssetSettings(testAssetId, []string{"b"}))
+	changes, err = api.ReconcileAssetSettings(testAssetId, []string{"b"})
+	assert.Nil(err)
+	assert.Len(changes.Added, 0)
+	assert.Len(changes.Removed, 0)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 2)
+
+	_, err = api.ReconcileAssetSettings("missing", []string{"b"})
+	assert.True(errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,198 @@
+package synq
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_TOKEN_REFRESH_MS = 60000 // refresh 1 minute before the token expires
+)
+
+// tokenState is shared by every copy of an ApiV2, so a token refreshed by one
+// goroutine is used by all of them
+type tokenState struct {
+	// mu guards the api key and expiry
+	mu     sync.RWMutex
+	expiry time.Time
+	// refreshMu makes sure only one login happens at a time
+	refreshMu sync.Mutex
+}
+
+// authMu guards setting up the token state of an ApiV2 that was not created
+// with NewV2
+var authMu sync.Mutex
+
+// initAuth sets up the token state of an ApiV2 struct literal, so it can
+// refresh its token once it has a User and Password. Copies made before this
+// do not share the refreshed token.
+func (a *ApiV2) initAuth() {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if a.auth != nil {
+		return
+	}
+	expiry := a.TokenExpiry
+	if expiry.IsZero() && a.BaseApi != nil {
+		expiry, _ = tokenExpiry(a.BaseApi.GetKey())
+	}
+	a.auth = &tokenState{expiry: expiry}
+}
+
+// reauther is implemented by apis that can log in again when their token is rejected
+type reauther interface {
+	reauth(ctx context.Context, staleAuth string) (string, bool)
+}
+
+// tokenExpiry reads the exp claim of a jwt, the signature is not verified
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	claims := struct {
+		Exp json.Number `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == "" {
+		return time.Time{}, false
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(exp), 0), true
+}
+
+func bearer(key string) string {
+	if strings.HasPrefix(key, "Bearer ") {
+		return key
+	}
+	return "Bearer " + key
+}
+
+// UnmarshalJSON accepts "exp" as either unix seconds or a timestamp
+func (l *LoginResp) UnmarshalJSON(data []byte) error {
+	resp := struct {
+		Token  string          `json:"jwt"`
+		Expiry json.RawMessage `json:"exp"`
+	}{}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	l.Token = resp.Token
+	var secs json.Number
+	if err := json.Unmarshal(resp.Expiry, &secs); err == nil {
+		if exp, err := secs.Float64(); err == nil {
+			l.TokenExpiry = time.Unix(int64(exp), 0)
+			return nil
+		}
+	}
+	var t time.Time
+	if err := json.Unmarshal(resp.Expiry, &t); err == nil {
+		l.TokenExpiry = t
+	}
+	return nil
+}
+
+func (a ApiV2) GetKey() string {
+	if a.auth == nil {
+		return a.BaseApi.GetKey()
+	}
+	a.auth.mu.RLock()
+	defer a.auth.mu.RUnlock()
+	return a.BaseApi.GetKey()
+}
+
+// SetKey sets the token to use, its expiry is read from the jwt
+func (a ApiV2) SetKey(key string) {
+	expiry, _ := tokenExpiry(key)
+	a.setToken(key, expiry)
+}
+
+// GetTokenExpiry returns when the current token expires, which is updated every
+// time the token is refreshed (unlike TokenExpiry)
+func (a ApiV2) GetTokenExpiry() time.Time {
+	if a.auth == nil {
+		return a.TokenExpiry
+	}
+	a.auth.mu.RLock()
+	defer a.auth.mu.RUnlock()
+	return a.auth.expiry
+}
+
+func (a ApiV2) setToken(key string, expiry time.Time) {
+	if a.auth == nil {
+		a.BaseApi.SetKey(key)
+		return
+	}
+	a.auth.mu.Lock()
+	defer a.auth.mu.Unlock()
+	a.BaseApi.SetKey(key)
+	a.auth.expiry = expiry
+}
+
+// canRefresh is true when the api knows how to log in again
+func (a ApiV2) canRefresh() bool {
+	return a.auth != nil && a.User != "" && a.Password != ""
+}
+
+// refreshIfExpiring logs in again if the token expires within
+// DEFAULT_TOKEN_REFRESH_MS. If that fails the current token is used until it
+// has expired, only then is the error returned.
+func (a ApiV2) refreshIfExpiring(ctx context.Context) error {
+	if !a.canRefresh() {
+		return nil
+	}
+	expiry := a.GetTokenExpiry()
+	window := time.Duration(DEFAULT_TOKEN_REFRESH_MS) * time.Millisecond
+	if expiry.IsZero() || time.Until(expiry) > window {
+		return nil
+	}
+	_, err := a.refreshToken(ctx, bearer(a.GetKey()))
+	if err != nil && time.Now().Before(expiry) {
+		log.Printf("could not refresh the token, using it until it expires at %s : %s\n", expiry.Format(time.RFC3339), err.Error())
+		return nil
+	}
+	return err
+}
+
+// refreshToken logs in again, unless another goroutine already replaced
+// staleAuth while we were waiting. It returns the new authorization header.
+func (a ApiV2) refreshToken(ctx context.Context, staleAuth string) (string, error) {
+	a.auth.refreshMu.Lock()
+	defer a.auth.refreshMu.Unlock()
+	if current := bearer(a.GetKey()); current != staleAuth {
+		return current, nil
+	}
+	resp, err := a.login(ctx, a.User, a.Password)
+	if err != nil {
+		return "", err
+	}
+	expiry := resp.TokenExpiry
+	if expiry.IsZero() {
+		expiry, _ = tokenExpiry(resp.Token)
+	}
+	a.setToken(resp.Token, expiry)
+	return bearer(resp.Token), nil
+}
+
+// reauth is called when a request sent with staleAuth got a 401
+func (a ApiV2) reauth(ctx context.Context, staleAuth string) (string, bool) {
+	if !a.canRefresh() {
+		return "", false
+	}
+	auth, err := a.refreshToken(ctx, staleAuth)
+	if err != nil {
+		return "", false
+	}
+	return auth, true
+}
@@ -0,0 +1,166 @@
+package synq
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SYNQfm/SYNQ-Golang/test_server"
+	"github.com/stretchr/testify/require"
+)
+
+func makeToken(exp time.Time) string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"typ":"JWT","alg":"HS256"}`))
+	payload := enc.EncodeToString([]byte(fmt.Sprintf(`{"sub":"test","exp":%d}`, exp.Unix())))
+	return header + "." + payload + ".sig"
+}
+
+func TestTokenExpiry(t *testing.T) {
+	assert := require.New(t)
+	exp, ok := tokenExpiry(test_server.TEST_AUTH)
+	assert.True(ok)
+	assert.Equal(int64(1493439511), exp.Unix())
+	exp, ok = tokenExpiry("Bearer " + test_server.TEST_AUTH)
+	assert.True(ok)
+	assert.Equal(int64(1493439511), exp.Unix())
+	for _, token := range []string{"", "fake", "a.b.c", "a." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".c"} {
+		_, ok = tokenExpiry(token)
+		assert.False(ok, token)
+	}
+	api := NewV2(test_server.TEST_AUTH)
+	assert.Equal(int64(1493439511), api.TokenExpiry.Unix())
+	assert.Equal(api.TokenExpiry, api.GetTokenExpiry())
+}
+
+func TestLoginExpiry(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2("")
+	defer testServer.Close()
+	assert.Nil(api.Login("user", "pass"))
+	assert.True(api.TokenExpiry.After(time.Now()))
+	assert.Equal(api.TokenExpiry, api.GetTokenExpiry())
+}
+
+func TestRefreshBeforeExpiry(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(makeToken(time.Now().Add(30 * time.Second)))
+	defer testServer.Close()
+	api.User = "user"
+	api.Password = "pass"
+	expiry := time.Now().Add(time.Hour)
+	testServer.SetLoginToken("new-token", expiry)
+	_, err := api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(1, testServer.Logins())
+	assert.Equal("new-token", api.GetKey())
+	assert.Equal(expiry.Unix(), api.GetTokenExpiry().Unix())
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 2)
+	assert.Equal("Bearer new-token", reqs[1].Header.Get("Authorization"))
+	_, err = api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(1, testServer.Logins())
+}
+
+func TestRefreshLiteral(t *testing.T) {
+	assert := require.New(t)
+	server := test_server.SetupServer(SYNQ_VERSION, DEFAULT_SAMPLE_DIR)
+	defer server.Close()
+	// an api that was not made with NewV2 refreshes its token too
+	base := NewBase(makeToken(time.Now().Add(30 * time.Second)))
+	base.SetUrl(server.GetUrl())
+	api := ApiV2{BaseApi: &base, User: "user", Password: "pass"}
+	server.SetLoginToken("new-token", time.Now().Add(time.Hour))
+	_, err := api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	assert.Equal(1, server.Logins())
+	assert.Equal("new-token", api.GetKey())
+
+	var literal ApiV2
+	literal.BaseApi = &base
+	assert.Nil(literal.Login("user", "pass"))
+	assert.True(literal.GetTokenExpiry().After(time.Now()))
+}
+
+func TestRefreshFailure(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2(makeToken(time.Now().Add(30 * time.Second)))
+	defer testServer.Close()
+	// the test server refuses this login
+	api.User = "fake"
+	api.Password = "fake"
+	// the token still works, so the request is sent with it
+	_, err := api.GetVideo(testVideoIdV2)
+	assert.Nil(err)
+	reqs, _ := testServer.GetReqs()
+	assert.Len(reqs, 2)
+	assert.Equal("/v1/login", reqs[0].URL.Path)
+	assert.Equal(bearer(api.GetKey()), reqs[1].Header.Get("Authorization"))
+
+	// once it has expired the failed login is returned
+	testServer.Reset()
+	api.SetKey(makeToken(time.Now().Add(-time.Second)))
+	_, err = api.GetVideo(testVideoIdV2)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "error getting login 404")
+	reqs, _ = testServer.GetReqs()
+	assert.Len(reqs, 1)
+}
+
+func TestReauthOn401(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2("old-token")
+	defer testServer.Close()
+	api.User = "user"
+	api.Password = "pass"
+	testServer.ExpireToken("old-token")
+	testServer.SetLoginToken("new-token", time.Now().Add(time.Hour))
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = api.GetVideo(testVideoIdV2)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.Nil(err)
+	}
+	assert.Equal(1, testServer.Logins())
+	assert.Equal("new-token", api.GetKey())
+
+	// a PUT is replayed with its body
+	asset, err := api.GetAsset(testAssetId)
+	assert.Nil(err)
+	testServer.ExpireToken("new-token")
+	testServer.SetLoginToken("newer-token", time.Now().Add(time.Hour))
+	assert.Nil(asset.Update())
+	assert.Equal(2, testServer.Logins())
+	_, values := testServer.GetReqs()
+	last := values[len(values)-1].Get("body")
+	assert.Contains(last, testAssetId)
+}
+
+func TestReauthNoCredentials(t *testing.T) {
+	assert := require.New(t)
+	api := setupTestApiV2("old-token")
+	defer testServer.Close()
+	testServer.ExpireToken("old-token")
+	_, err := api.GetVideo(testVideoIdV2)
+	assert.NotNil(err)
+	assert.True(errors.Is(err, ErrUnauthorized))
+	assert.Equal("token expired", err.Error())
+	assert.Equal(0, testServer.Logins())
+
+	// a failed login returns the original 401
+	api.User = "fake"
+	api.Password = "fake"
+	_, err = api.GetVideo(testVideoIdV2)
+	assert.True(errors.Is(err, ErrUnauthorized))
+}
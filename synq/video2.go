@@ -2,6 +2,7 @@ package synq
 
 import (
 	"bytes"
+	"context"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
@@ -30,6 +31,9 @@ type VideoV2 struct {
 	Assets            []Asset         `json:"assets"`
 	AccountIds        []string        `json:"account_ids"`
 	CompletenessScore float64         `json:"completeness_score"`
+	// ETag is the version of the video returned by the server (if it sends
+	// one), UpdateIfUnchanged uses it
+	ETag string `json:"-"`
 }
 
 func (v VideoV2) Value() (driver.Value, error) {
@@ -59,9 +63,13 @@ func (v *VideoV2) GetBaseUrl() string {
 }
 
 func (v *VideoV2) GetVideoAssetList() error {
+	return v.GetVideoAssetListCtx(context.Background())
+}
+
+func (v *VideoV2) GetVideoAssetListCtx(ctx context.Context) error {
 	list := AssetList{}
 	url := v.GetBaseUrl() + "/videos/" + v.Id + "/assets"
-	err := v.Api.handleGet(url, &list)
+	err := v.Api.handleGetCtx(ctx, url, &list)
 	if err != nil {
 		return err
 	}
@@ -70,6 +78,16 @@ func (v *VideoV2) GetVideoAssetList() error {
 }
 
 func (v *VideoV2) Update() error {
+	return v.UpdateCtx(context.Background())
+}
+
+func (v *VideoV2) UpdateCtx(ctx context.Context) error {
+	return v.update(ctx, "")
+}
+
+// update PUTs the metadata and user_data, only if the server version matches
+// ifMatch when it is set
+func (v *VideoV2) update(ctx context.Context, ifMatch string) error {
 	url := v.GetBaseUrl() + "/videos/" + v.Id
 	type Update struct {
 		Metadata          json.RawMessage `json:"metadata"`
@@ -79,22 +97,33 @@ func (v *VideoV2) Update() error {
 	update := Update{Metadata: v.Metadata, Userdata: v.Userdata, CompletenessScore: v.CompletenessScore}
 	b, _ := json.Marshal(update)
 	body := bytes.NewBuffer(b)
-	req, err := v.Api.makeRequest("PUT", url, body)
+	req, err := v.Api.makeRequestCtx(ctx, "PUT", url, body)
 	if err != nil {
 		return err
 	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
 	resp := VideoResp{}
-	err = handleReq(v.Api, req, &resp)
+	header, err := handleReqHeader(v.Api, req, &resp)
 	if err != nil {
 		return err
 	}
 	v.Metadata = resp.Video.Metadata
 	v.Userdata = resp.Video.Userdata
 	v.CompletenessScore = resp.Video.CompletenessScore
+	if !resp.Video.UpdatedAt.IsZero() {
+		v.UpdatedAt = resp.Video.UpdatedAt
+	}
+	v.ETag = header.Get("ETag")
 	return nil
 }
 
 func (v *VideoV2) AddAccount(accountId string) error {
+	return v.AddAccountCtx(context.Background(), accountId)
+}
+
+func (v *VideoV2) AddAccountCtx(ctx context.Context, accountId string) error {
 	url := v.GetBaseUrl() + "/videos/" + v.Id
 	account := VideoAccount{Id: accountId}
 	update := struct {
@@ -103,7 +132,7 @@ func (v *VideoV2) AddAccount(accountId string) error {
 	update.VideoAccounts = append(update.VideoAccounts, account)
 	b, _ := json.Marshal(update)
 	body := bytes.NewBuffer(b)
-	req, err := v.Api.makeRequest("PUT", url, body)
+	req, err := v.Api.makeRequestCtx(ctx, "PUT", url, body)
 	if err != nil {
 		return err
 	}
@@ -115,11 +144,56 @@ func (v *VideoV2) AddAccount(accountId string) error {
 	return nil
 }
 
+// Delete removes the video, see DeleteOptions for deleting its assets too or
+// keeping an archive to restore it from
+func (v *VideoV2) Delete(options ...DeleteOptions) error {
+	return v.DeleteCtx(context.Background(), options...)
+}
+
+func (v *VideoV2) DeleteCtx(ctx context.Context, options ...DeleteOptions) error {
+	var opts DeleteOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Cascade || opts.Archive != nil {
+		// make sure the archive has all of the assets
+		if err := v.GetVideoAssetListCtx(ctx); err != nil {
+			return err
+		}
+	}
+	if opts.Archive != nil {
+		inPlace, err := opts.Archive.Archive(ctx, v)
+		if err != nil || inPlace {
+			return err
+		}
+	}
+	if opts.Cascade {
+		for _, asset := range v.Assets {
+			asset.Api = *v.Api
+			err := asset.DeleteCtx(ctx)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		v.Assets = []Asset{}
+	}
+	url := v.GetBaseUrl() + "/videos/" + v.Id
+	req, err := v.Api.makeRequestCtx(ctx, "DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	return handleReq(v.Api, req, nil)
+}
+
 func (v VideoV2) GetAsset(assetId string) (Asset, error) {
+	return v.GetAssetCtx(context.Background(), assetId)
+}
+
+func (v VideoV2) GetAssetCtx(ctx context.Context, assetId string) (Asset, error) {
 	url := v.GetBaseUrl() + "/assets/" + assetId
 	var asset Asset
 	asset.Api = *v.Api
-	err := asset.handleAssetReq("GET", url, nil)
+	err := asset.handleAssetReq(ctx, "GET", url, nil)
 	return asset, err
 }
 
@@ -153,18 +227,22 @@ func (v *VideoV2) FindAssetByType(assetType string) ([]Asset, bool) {
 }
 
 func (v *VideoV2) CreateOrUpdateAsset(asset *Asset) error {
+	return v.CreateOrUpdateAssetCtx(context.Background(), asset)
+}
+
+func (v *VideoV2) CreateOrUpdateAssetCtx(ctx context.Context, asset *Asset) error {
 	// make sure the API is set
 	asset.Api = *v.Api
 	// check if this asset exists, if it does, just update
 	a, found := v.FindAsset(asset.Location)
 	if found {
 		asset.Id = a.Id
-		return asset.Update()
+		return asset.UpdateCtx(ctx)
 	} else {
 		url := v.GetBaseUrl() + "/assets"
 		data, _ := json.Marshal(asset)
 		body := bytes.NewBuffer(data)
-		err := asset.handleAssetReq("POST", url, body)
+		err := asset.handleAssetReq(ctx, "POST", url, body)
 		if err == nil {
 			v.Assets = append(v.Assets, *asset)
 		}
@@ -175,23 +253,31 @@ func (v *VideoV2) CreateOrUpdateAsset(asset *Asset) error {
 // This will get the upload params for a sepcific video, if assetId is passed in
 // it will be used instead (assuming it exists)
 func (v *VideoV2) GetUploadParams(req upload.UploadRequest) (up upload.UploadParameters, err error) {
+	return v.GetUploadParamsCtx(context.Background(), req)
+}
+
+func (v *VideoV2) GetUploadParamsCtx(ctx context.Context, req upload.UploadRequest) (up upload.UploadParameters, err error) {
 	api := v.Api
 	if api == nil {
 		return up, errors.New("api is blank")
 	}
-	return api.GetUploadParams(v.Id, req)
+	return api.GetUploadParamsCtx(ctx, v.Id, req)
 }
 
 // This will call Unicorn's /v2/video/<id>/upload API, which will
 // create an asset and create a signed S3 location to upload to, including
 // the signature url for multipart uploads
 func (v *VideoV2) CreateAssetForUpload(req upload.UploadRequest) (asset Asset, err error) {
-	up, err := v.GetUploadParams(req)
+	return v.CreateAssetForUploadCtx(context.Background(), req)
+}
+
+func (v *VideoV2) CreateAssetForUploadCtx(ctx context.Context, req upload.UploadRequest) (asset Asset, err error) {
+	up, err := v.GetUploadParamsCtx(ctx, req)
 	if err != nil {
 		return asset, err
 	}
 	// now load the asset
-	asset, err = v.GetAsset(up.AssetId)
+	asset, err = v.GetAssetCtx(ctx, up.AssetId)
 	if err != nil {
 		return asset, err
 	}
@@ -201,12 +287,16 @@ func (v *VideoV2) CreateAssetForUpload(req upload.UploadRequest) (asset Asset, e
 }
 
 func (v *VideoV2) CreateAsset(state, fileType, location string) (Asset, error) {
+	return v.CreateAssetCtx(context.Background(), state, fileType, location)
+}
+
+func (v *VideoV2) CreateAssetCtx(ctx context.Context, state, fileType, location string) (Asset, error) {
 	var asset Asset
 	asset.VideoId = v.Id
 	asset.State = state
 	asset.Type = fileType
 	asset.Location = location
-	err := v.CreateOrUpdateAsset(&asset)
+	err := v.CreateOrUpdateAssetCtx(ctx, &asset)
 	return asset, err
 }
 
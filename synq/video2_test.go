@@ -189,3 +189,26 @@ func TestMisc(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(video.Id, video2.Id)
 }
+
+func TestVideoDelete(t *testing.T) {
+	assert := require.New(t)
+	video := setupTestVideoV2()
+	assert.Nil(video.Delete())
+	reqs, _ := test_server.GetReqs()
+	assert.Len(reqs, 1)
+	assert.Equal("DELETE", reqs[0].Method)
+	assert.Equal("/v1/videos/"+testVideoIdV2, reqs[0].URL.Path)
+
+	testServer.Reset()
+	assert.Nil(video.Delete(DeleteOptions{Cascade: true}))
+	reqs, _ = test_server.GetReqs()
+	assert.Len(reqs, 3)
+	assert.Equal("/v1/videos/"+testVideoIdV2+"/assets", reqs[0].URL.Path)
+	assert.Equal("DELETE", reqs[1].Method)
+	assert.Equal("/v1/assets/"+testAssetId, reqs[1].URL.Path)
+	assert.Equal("/v1/videos/"+testVideoIdV2, reqs[2].URL.Path)
+	assert.Len(video.Assets, 0)
+
+	video.Id = "missing"
+	assert.NotNil(video.Delete())
+}
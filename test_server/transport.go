@@ -0,0 +1,27 @@
+package test_server
+
+import (
+	"net/http"
+	"sync"
+)
+
+// CountingTransport sends requests with http.DefaultTransport and counts them,
+// use it to check that a custom http.Client is used
+type CountingTransport struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (c *CountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.count++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// Count returns how many requests were sent
+func (c *CountingTransport) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
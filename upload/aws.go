@@ -2,6 +2,7 @@ package upload
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -33,6 +34,14 @@ const (
 type AwsUpload struct {
 	UploadParams UploadParameters
 	Uploader     *s3manager.Uploader
+	Client       *http.Client
+	Progress     ProgressFunc
+	// Parts are the part settings the upload was created with, Uploader is
+	// set up from them
+	Parts        PartOptions
+	SignAttempts int
+	SignBackoff  time.Duration
+	checksum     Checksum
 }
 
 type V4Request struct {
@@ -98,16 +107,29 @@ func (r *V4Request) Sign(awsKey, awsSecret string) (resp V4Response, err error)
 	}, nil
 }
 
-var CreatorFn func(UploadParameters) (AwsUploadF, error)
+var CreatorFn func(UploadParameters, ...UploadOptions) (AwsUploadF, error)
 
 func init() {
 	CreatorFn = NewAwsUpload
 }
 
 // UploadParameters is retrieved from the Unicorn API, so we're creating an AwsUpload from the settings
-func NewAwsUpload(params UploadParameters) (AwsUploadF, error) {
+func NewAwsUpload(params UploadParameters, options ...UploadOptions) (AwsUploadF, error) {
+	var opts UploadOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
 	au := &AwsUpload{
 		UploadParams: params,
+		Client:       client,
+		Progress:     opts.Progress,
+		Parts:        opts.PartOptions,
+		SignAttempts: opts.SignAttempts,
+		SignBackoff:  opts.SignBackoff,
 	}
 	provider := credentials.StaticProvider{}
 	// use dummy values
@@ -128,7 +150,14 @@ func NewAwsUpload(params UploadParameters) (AwsUploadF, error) {
 		return au, err
 	}
 
-	svc := s3.New(sess)
+	// set the client on the service rather than the session, as the session
+	// can only load a custom CA bundle into an *http.Transport
+	config := &aws.Config{HTTPClient: client}
+	if opts.Endpoint != "" {
+		config.Endpoint = aws.String(opts.Endpoint)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	svc := s3.New(sess, config)
 
 	customSigner := true
 	if customSigner {
@@ -137,8 +166,15 @@ func NewAwsUpload(params UploadParameters) (AwsUploadF, error) {
 		svc.Handlers.Sign.PushBack(signer)
 	}
 
+	svc.Handlers.Complete.PushBackNamed(progressHandler)
+	svc.Handlers.Complete.PushBackNamed(etagHandler)
+
 	// s3manager uploader
-	au.Uploader = s3manager.NewUploaderWithClient(svc)
+	au.Uploader = s3manager.NewUploaderWithClient(svc, func(u *s3manager.Uploader) {
+		u.PartSize = AutoPartSize(-1, opts.PartSize)
+		u.Concurrency = opts.concurrency(u.PartSize, false)
+		u.LeavePartsOnError = opts.LeavePartsOnError
+	})
 	return au, nil
 }
 
@@ -162,6 +198,13 @@ func (a *AwsUpload) AwsKeyId() string {
 	return a.UploadParams.AwsAccessKeyId
 }
 
+func (a *AwsUpload) getClient() *http.Client {
+	if a.Client == nil {
+		return http.DefaultClient
+	}
+	return a.Client
+}
+
 func (a *AwsUpload) UploaderSigUrl() string {
 	// take the UploadParams signature and append it to the uploader url
 	return a.UploadParams.SignatureUrl
@@ -202,6 +245,26 @@ func (a AwsUpload) GetRegion() (string, error) {
 }
 
 func (a *AwsUpload) Upload(body io.Reader) (*s3manager.UploadOutput, error) {
+	return a.UploadCtx(context.Background(), body)
+}
+
+// UploadCtx uploads body, cancelling ctx aborts the multipart upload as well as
+// any outstanding calls to the signature server. Body is checksummed as it is
+// sent (see LastChecksum) and the upload fails if the ETag S3 returns does not
+// match.
+func (a *AwsUpload) UploadCtx(ctx context.Context, body io.Reader) (*s3manager.UploadOutput, error) {
+	total := readerSize(body)
+	size := partSize(total, a.Uploader.PartSize)
+	var tracker *progressTracker
+	if a.Progress != nil {
+		parts := 0
+		if total >= 0 {
+			parts = int(partCount(total, size))
+		}
+		tracker = newProgressTracker(a.Progress, total, parts)
+		ctx = withTracker(ctx, tracker)
+		tracker.begin(0, 0)
+	}
 	// upload parameters
 	acl := a.Acl()
 	bucket, err := a.GetBucket()
@@ -210,6 +273,15 @@ func (a *AwsUpload) Upload(body io.Reader) (*s3manager.UploadOutput, error) {
 	}
 	contentType := a.ContentType()
 	key := a.Key()
+	// a seekable body is checksummed before it is sent, so s3manager still
+	// reads its parts with section readers instead of buffering them. Other
+	// bodies are checksummed through a tee as the parts are read, in order.
+	// Either way every part is seekable, and the s3 client sends its MD5 as
+	// Content-MD5.
+	sum, body, err := checksumBody(body, size)
+	if err != nil {
+		return nil, err
+	}
 	uploadInput := &s3manager.UploadInput{
 		ACL:         &acl,
 		Bucket:      &bucket,
@@ -217,7 +289,30 @@ func (a *AwsUpload) Upload(body io.Reader) (*s3manager.UploadOutput, error) {
 		ContentType: &contentType,
 		Key:         &key,
 	}
-	return a.Uploader.Upload(uploadInput)
+	var result Checksum
+	ctx = withETag(ctx, &result)
+	out, err := a.Uploader.UploadWithContext(ctx, uploadInput, func(u *s3manager.Uploader) {
+		u.PartSize = size
+		u.Concurrency = a.Parts.concurrency(size, true)
+	})
+	if err != nil {
+		return out, err
+	}
+	a.checksum = sum.checksum()
+	a.checksum.ETag = result.ETag
+	a.checksum.Encrypted = result.Encrypted
+	if err = a.checksum.Verify(); err != nil {
+		return out, err
+	}
+	if tracker != nil {
+		tracker.done()
+	}
+	return out, nil
+}
+
+// LastChecksum returns the checksum of the last upload that completed
+func (a *AwsUpload) LastChecksum() Checksum {
+	return a.checksum
 }
 
 // MultipartUploadSigner returns a function that can be added to an s3 client's
@@ -244,11 +339,12 @@ func (a *AwsUpload) Upload(body io.Reader) (*s3manager.UploadOutput, error) {
 //
 //         // S3 requests are now signed by signer().
 
+// A request that can not be signed fails with a *SignerError, and is not sent
+// to S3.
 func (a AwsUpload) Signer() func(r *request.Request) {
 	signer := func(r *request.Request) {
-		err := a.SignRequest(r)
-		if err != nil {
-			return // TODO(mastensg): how to report errors from handlers?
+		if err := a.SignRequest(r); err != nil {
+			r.Error = err
 		}
 	}
 
@@ -257,7 +353,8 @@ func (a AwsUpload) Signer() func(r *request.Request) {
 
 func (a *AwsUpload) ServerSignV2(r *request.Request) (string, error) {
 	v4 := CreateV4Request(a.UploadParams, r)
-	resp, err := a.V4Sig(v4)
+	// the aws request carries the context passed to UploadCtx
+	resp, err := a.V4SigCtx(r.Context(), v4)
 	if err != nil {
 		return "", err
 	}
@@ -289,42 +386,82 @@ func (a *AwsUpload) SignRequest(r *request.Request) error {
 }
 
 func (a *AwsUpload) V4Sig(req V4Request) (resp V4Response, err error) {
+	return a.V4SigCtx(context.Background(), req)
+}
+
+func (a *AwsUpload) V4SigCtx(ctx context.Context, req V4Request) (resp V4Response, err error) {
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return resp, err
 	}
-	respBody, err := a.Request(reqBody)
+	respBody, err := a.RequestCtx(ctx, reqBody)
 	if err != nil {
 		return resp, err
 	}
 	err = json.Unmarshal(respBody, &resp)
+	if err == nil && resp.Authorization == "" {
+		err = errors.New("no authorization in the response")
+	}
 	if err != nil {
-		return resp, err
+		return resp, &SignerError{Url: a.UploaderSigUrl(), StatusCode: http.StatusOK, Body: respBody, Err: err, Attempts: 1}
 	}
 	return resp, nil
 }
 
 func (a *AwsUpload) Request(body []byte) ([]byte, error) {
+	return a.RequestCtx(context.Background(), body)
+}
+
+// RequestCtx sends body to the signature server, retrying transient failures
+// with backoff. A failure is returned as a *SignerError.
+func (a *AwsUpload) RequestCtx(ctx context.Context, body []byte) ([]byte, error) {
+	attempts := a.signAttempts()
+	for n := 1; ; n++ {
+		respBody, err := a.request(ctx, body)
+		if err == nil {
+			return respBody, nil
+		}
+		err.Attempts = n
+		if n >= attempts || !err.Temporary() || ctx.Err() != nil {
+			return nil, err
+		}
+		delay := signBackoff(a.signBackoff(), n)
+		log.Printf("signature request failed (%s), retrying in %s\n", err.Error(), delay)
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		}
+	}
+}
+
+// request makes one call to the signature server
+func (a *AwsUpload) request(ctx context.Context, body []byte) ([]byte, *SignerError) {
 	url := a.UploaderSigUrl()
 
-	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, &SignerError{Url: url, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.getClient().Do(req.WithContext(ctx))
 	if err != nil {
 		log.Printf("could not call %s : %s\n", url, err.Error())
-		return nil, err
+		return nil, &SignerError{Url: url, Err: err}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		// TODO(mastensg): report status and maybe body
-		// TODO(mastensg): handle known error responses specifically
-		log.Printf("invalid response code %d from response\n", resp.StatusCode)
-		return nil, errors.New("HTTP response status not OK.")
-	}
 	// read response
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Println("error reading response body", err.Error())
-		return nil, err
+		return nil, &SignerError{Url: url, StatusCode: resp.StatusCode, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("invalid response code %d from response\n", resp.StatusCode)
+		return nil, &SignerError{Url: url, StatusCode: resp.StatusCode, Body: respBody}
 	}
 	return respBody, nil
 }
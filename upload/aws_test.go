@@ -1,6 +1,7 @@
 package upload
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -121,7 +122,12 @@ func TestServerSign(t *testing.T) {
 	r := createTestAwsReq()
 	_, err = au.ServerSignV2(r)
 	assert.NotNil(err)
-	assert.Equal("Post sig: unsupported protocol scheme \"\"", err.Error())
+	// the url error is formatted differently by go versions
+	assert.Contains(err.Error(), "unsupported protocol scheme")
+	signErr, ok := err.(*SignerError)
+	assert.True(ok)
+	assert.Equal("sig", signErr.Url)
+	assert.False(signErr.Temporary())
 	server := setupServer()
 	params.SignatureUrl = server.URL + "/sig"
 	u, _ = NewAwsUpload(params)
@@ -130,3 +136,49 @@ func TestServerSign(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal("sig123", sig)
 }
+
+func TestServerSignCtx(t *testing.T) {
+	assert := require.New(t)
+	server := setupServer()
+	defer server.Close()
+	params := UploadParameters{
+		Key:          "abc",
+		SignatureUrl: server.URL + "/sig",
+		Action:       "https://synqfm.s3.amazonaws.com",
+	}
+	u, _ := NewAwsUpload(params)
+	au := u.(*AwsUpload)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := createTestAwsReq()
+	r.SetContext(ctx)
+	_, err := au.ServerSignV2(r)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "context canceled")
+	_, err = au.UploadCtx(ctx, strings.NewReader("data"))
+	assert.NotNil(err)
+}
+
+func TestUploadClient(t *testing.T) {
+	assert := require.New(t)
+	server := setupServer()
+	defer server.Close()
+	params := UploadParameters{
+		SignatureUrl: server.URL + "/sig",
+		Action:       "https://synqfm.s3.amazonaws.com",
+	}
+	u, _ := NewAwsUpload(params)
+	assert.Equal(http.DefaultClient, u.(*AwsUpload).Client)
+	count := 0
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		count++
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	u, err := NewAwsUpload(params, UploadOptions{Client: client})
+	assert.Nil(err)
+	au := u.(*AwsUpload)
+	assert.Equal(client, au.Client)
+	_, err = au.ServerSignV2(createTestAwsReq())
+	assert.Nil(err)
+	assert.Equal(1, count)
+}
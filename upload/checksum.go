@@ -0,0 +1,210 @@
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SYNQfm/helpers/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Checksum is the checksum of an uploaded file, it is computed while the file
+// is sent
+type Checksum struct {
+	Size int64
+	// MD5 and SHA256 are hex encoded
+	MD5    string
+	SHA256 string
+	// Parts is the hex MD5 of every PartSize bytes of the file, S3 builds the
+	// ETag of a multipart upload from them
+	PartSize int64
+	Parts    []string
+	// ETag is the ETag S3 returned for the uploaded object
+	ETag string
+	// Encrypted is set when the object is stored with SSE-KMS or SSE-C, its
+	// ETag is then not an MD5
+	Encrypted bool
+}
+
+// ChecksumUploadF is implemented by uploaders that checksum the files they
+// upload
+type ChecksumUploadF interface {
+	// LastChecksum returns the checksum of the last completed upload
+	LastChecksum() Checksum
+}
+
+// MultipartETag returns the ETag S3 gives an object uploaded in parts with
+// the (hex) MD5s parts
+func MultipartETag(parts []string) string {
+	h := md5.New()
+	for _, p := range parts {
+		sum, _ := hex.DecodeString(p)
+		h.Write(sum)
+	}
+	return fmt.Sprintf("%x-%d", h.Sum(nil), len(parts))
+}
+
+// Verify returns an error if the ETag S3 returned does not match the
+// checksum, an upload without an ETag or that is encrypted is not checked
+func (c Checksum) Verify() error {
+	etag := strings.Trim(c.ETag, `"`)
+	if etag == "" || c.Encrypted {
+		return nil
+	}
+	expected := c.MD5
+	if strings.Contains(etag, "-") {
+		expected = MultipartETag(c.Parts)
+	}
+	if etag != expected {
+		return common.NewError("uploaded ETag '%s' does not match the file checksum '%s'", etag, expected)
+	}
+	return nil
+}
+
+// ChecksumReader reads r to the end and returns its checksum, with the MD5 of
+// every partSize bytes if partSize is set
+func ChecksumReader(r io.Reader, partSize int64) (Checksum, error) {
+	c := newChecksummer(partSize)
+	if _, err := io.Copy(c, r); err != nil {
+		return Checksum{}, err
+	}
+	return c.checksum(), nil
+}
+
+// checksumBody returns a checksummer that has the checksum of body once the
+// returned reader has been read to the end. A seekable body is read ahead and
+// returned as is.
+func checksumBody(body io.Reader, partSize int64) (*checksummer, io.Reader, error) {
+	sum := newChecksummer(partSize)
+	rs, ok := body.(io.ReadSeeker)
+	if !ok {
+		return sum, io.TeeReader(body, sum), nil
+	}
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = io.Copy(sum, rs); err != nil {
+		return nil, nil, err
+	}
+	if _, err = rs.Seek(pos, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	return sum, body, nil
+}
+
+// checksummer is an io.Writer that hashes everything written to it
+type checksummer struct {
+	md5      hash.Hash
+	sha256   hash.Hash
+	part     hash.Hash
+	partSize int64
+	partFill int64
+	size     int64
+	parts    []string
+}
+
+func newChecksummer(partSize int64) *checksummer {
+	return &checksummer{md5: md5.New(), sha256: sha256.New(), part: md5.New(), partSize: partSize}
+}
+
+func (c *checksummer) Write(p []byte) (int, error) {
+	n := len(p)
+	c.md5.Write(p)
+	c.sha256.Write(p)
+	c.size += int64(n)
+	for c.partSize > 0 && len(p) > 0 {
+		k := c.partSize - c.partFill
+		if int64(len(p)) < k {
+			k = int64(len(p))
+		}
+		c.part.Write(p[:k])
+		c.partFill += k
+		p = p[k:]
+		if c.partFill == c.partSize {
+			c.endPart()
+		}
+	}
+	return n, nil
+}
+
+// endPart finishes the current part, even if it is not full
+func (c *checksummer) endPart() {
+	if c.partFill == 0 {
+		return
+	}
+	c.parts = append(c.parts, hex.EncodeToString(c.part.Sum(nil)))
+	c.part.Reset()
+	c.partFill = 0
+}
+
+// lastPart returns the MD5 of the last finished part, as hex and base64 for
+// the Content-MD5 header
+func (c *checksummer) lastPart() (string, string) {
+	if len(c.parts) == 0 {
+		return "", ""
+	}
+	last := c.parts[len(c.parts)-1]
+	sum, _ := hex.DecodeString(last)
+	return last, base64.StdEncoding.EncodeToString(sum)
+}
+
+func (c *checksummer) checksum() Checksum {
+	c.endPart()
+	return Checksum{
+		Size:     c.size,
+		MD5:      hex.EncodeToString(c.md5.Sum(nil)),
+		SHA256:   hex.EncodeToString(c.sha256.Sum(nil)),
+		PartSize: c.partSize,
+		Parts:    c.parts,
+	}
+}
+
+type etagKey struct{}
+
+// withETag stores the ETag of the object uploaded with ctx, and whether it is
+// encrypted, in result
+func withETag(ctx context.Context, result *Checksum) context.Context {
+	return context.WithValue(ctx, etagKey{}, result)
+}
+
+// encryptedETag returns true if the object of an s3 response is stored with
+// SSE-KMS or SSE-C, S3 does not use the MD5 of the object as their ETag
+func encryptedETag(h http.Header) bool {
+	return h.Get("X-Amz-Server-Side-Encryption") == s3.ServerSideEncryptionAwsKms ||
+		h.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != ""
+}
+
+// etagHandler saves the ETag of completed uploads that have somewhere to
+// store it in their context, as s3manager does not return it
+var etagHandler = request.NamedHandler{
+	Name: "synq.ETagHandler",
+	Fn: func(r *request.Request) {
+		if r.Error != nil {
+			return
+		}
+		result, ok := r.Context().Value(etagKey{}).(*Checksum)
+		if !ok {
+			return
+		}
+		switch out := r.Data.(type) {
+		case *s3.PutObjectOutput:
+			result.ETag = aws.StringValue(out.ETag)
+		case *s3.CompleteMultipartUploadOutput:
+			result.ETag = aws.StringValue(out.ETag)
+		default:
+			return
+		}
+		result.Encrypted = r.HTTPResponse != nil && encryptedETag(r.HTTPResponse.Header)
+	},
+}
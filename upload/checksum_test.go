@@ -0,0 +1,194 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumReader(t *testing.T) {
+	assert := require.New(t)
+	content := []byte("0123456789")
+	sum, err := ChecksumReader(bytes.NewReader(content), 4)
+	assert.Nil(err)
+	assert.Equal(int64(10), sum.Size)
+	assert.Equal(fmt.Sprintf("%x", md5.Sum(content)), sum.MD5)
+	assert.Equal(fmt.Sprintf("%x", sha256.Sum256(content)), sum.SHA256)
+	assert.Equal([]string{
+		fmt.Sprintf("%x", md5.Sum(content[:4])),
+		fmt.Sprintf("%x", md5.Sum(content[4:8])),
+		fmt.Sprintf("%x", md5.Sum(content[8:])),
+	}, sum.Parts)
+	// without a part size there are no parts
+	sum, err = ChecksumReader(bytes.NewReader(content), 0)
+	assert.Nil(err)
+	assert.Empty(sum.Parts)
+	assert.Equal(fmt.Sprintf("%x", md5.Sum(content)), sum.MD5)
+}
+
+func TestMultipartETag(t *testing.T) {
+	assert := require.New(t)
+	// the ETag S3 returns for a 2 part upload of "aa" and "b"
+	a := md5.Sum([]byte("aa"))
+	b := md5.Sum([]byte("b"))
+	sums := append(a[:], b[:]...)
+	expected := fmt.Sprintf("%x-2", md5.Sum(sums))
+	assert.Equal(expected, MultipartETag([]string{fmt.Sprintf("%x", a), fmt.Sprintf("%x", b)}))
+}
+
+func TestChecksumVerify(t *testing.T) {
+	assert := require.New(t)
+	sum, _ := ChecksumReader(bytes.NewReader([]byte("0123456789")), 4)
+	assert.Nil(sum.Verify())
+	sum.ETag = `"` + sum.MD5 + `"`
+	assert.Nil(sum.Verify())
+	sum.ETag = `"` + MultipartETag(sum.Parts) + `"`
+	assert.Nil(sum.Verify())
+	sum.ETag = `"` + MultipartETag(sum.Parts[:2]) + `"`
+	err := sum.Verify()
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "does not match the file checksum")
+	sum.ETag = "abc"
+	assert.NotNil(sum.Verify())
+}
+
+func TestUploadChecksum(t *testing.T) {
+	assert := require.New(t)
+	size := int(2*s3manager.MinUploadPartSize) + 100
+	s3, au, fileName, content, cleanup := setupResumable(t, size)
+	defer cleanup()
+	f, err := os.Open(fileName)
+	assert.Nil(err)
+	defer f.Close()
+	_, err = au.Upload(f)
+	assert.Nil(err)
+	assert.Equal(3, s3.call("part"))
+	assert.Equal(0, s3.call("nomd5"))
+	sum := au.LastChecksum()
+	assert.Equal(int64(size), sum.Size)
+	assert.Equal(fmt.Sprintf("%x", md5.Sum(content)), sum.MD5)
+	assert.Equal(fmt.Sprintf("%x", sha256.Sum256(content)), sum.SHA256)
+	assert.Equal(s3manager.MinUploadPartSize, sum.PartSize)
+	assert.Len(sum.Parts, 3)
+	assert.Equal(`"`+MultipartETag(sum.Parts)+`"`, sum.ETag)
+
+	// a single put is checked against the MD5 of the file
+	_, err = au.Upload(bytes.NewReader([]byte("small")))
+	assert.Nil(err)
+	assert.Equal(1, s3.call("put"))
+	assert.Equal(0, s3.call("nomd5"))
+	sum = au.LastChecksum()
+	assert.Equal(int64(5), sum.Size)
+	assert.Equal(fmt.Sprintf(`"%x"`, md5.Sum([]byte("small"))), sum.ETag)
+
+	// S3 stored something else
+	s3.etag = `"abc"`
+	_, err = au.Upload(bytes.NewReader([]byte("small")))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "uploaded ETag 'abc' does not match")
+	f.Seek(0, 0)
+	_, err = au.Upload(f)
+	assert.NotNil(err)
+	_, err = au.UploadFileResumable(fileName, "")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "uploaded ETag 'abc' does not match")
+}
+
+func TestUploadFileResumableChanged(t *testing.T) {
+	assert := require.New(t)
+	size := int(2*s3manager.MinUploadPartSize) + 100
+	s3, au, fileName, content, cleanup := setupResumable(t, size)
+	defer cleanup()
+	s3.failPart = 3
+	_, err := au.UploadFileResumable(fileName, "")
+	assert.NotNil(err)
+	// the middle of the file changes without changing its fingerprint, so
+	// the checkpoint is used but the changed part is sent again
+	info, _ := os.Stat(fileName)
+	content[s3manager.MinUploadPartSize+10]++
+	assert.Nil(ioutil.WriteFile(fileName, content, 0644))
+	assert.Nil(os.Chtimes(fileName, time.Now(), info.ModTime()))
+	s3.failPart = 0
+	out, err := au.UploadFileResumable(fileName, "")
+	assert.Nil(err)
+	assert.Equal("upload-1", out.UploadID)
+	assert.Equal(5, s3.call("part"))
+	assert.Equal(content, s3.uploaded("upload-1"))
+	assert.True(strings.HasSuffix(au.LastChecksum().ETag, `-3"`))
+}
+
+func TestUploadChecksumStream(t *testing.T) {
+	assert := require.New(t)
+	size := int(2*s3manager.MinUploadPartSize) + 100
+	s3, au, _, content, cleanup := setupResumable(t, size)
+	defer cleanup()
+	// a reader that can not seek is checksummed as its parts are read, the
+	// parts still carry their Content-MD5
+	_, err := au.Upload(struct{ io.Reader }{bytes.NewReader(content)})
+	assert.Nil(err)
+	assert.Equal(3, s3.call("part"))
+	assert.Equal(0, s3.call("nomd5"))
+	sum := au.LastChecksum()
+	assert.Equal(fmt.Sprintf("%x", md5.Sum(content)), sum.MD5)
+	assert.Equal(`"`+MultipartETag(sum.Parts)+`"`, sum.ETag)
+
+	_, err = au.Upload(struct{ io.Reader }{bytes.NewReader([]byte("small"))})
+	assert.Nil(err)
+	assert.Equal(1, s3.call("put"))
+	assert.Equal(0, s3.call("nomd5"))
+	assert.Equal(fmt.Sprintf(`"%x"`, md5.Sum([]byte("small"))), au.LastChecksum().ETag)
+}
+
+func TestChecksumBody(t *testing.T) {
+	assert := require.New(t)
+	r := bytes.NewReader([]byte("0123456789"))
+	r.Seek(2, io.SeekStart)
+	sum, body, err := checksumBody(r, 4)
+	assert.Nil(err)
+	// a seekable body is sent as is, from where it was
+	assert.Equal(r, body)
+	rest, _ := ioutil.ReadAll(body)
+	assert.Equal("23456789", string(rest))
+	assert.Equal(fmt.Sprintf("%x", md5.Sum(rest)), sum.checksum().MD5)
+}
+
+func TestUploadChecksumEncrypted(t *testing.T) {
+	assert := require.New(t)
+	size := int(2*s3manager.MinUploadPartSize) + 100
+	s3, au, fileName, _, cleanup := setupResumable(t, size)
+	defer cleanup()
+	// the ETag of an object encrypted with SSE-KMS or SSE-C is not its MD5
+	s3.etag = `"abc"`
+	for _, h := range []map[string]string{
+		{"x-amz-server-side-encryption": "aws:kms"},
+		{"x-amz-server-side-encryption-customer-algorithm": "AES256"},
+	} {
+		s3.headers = h
+		_, err := au.Upload(bytes.NewReader([]byte("small")))
+		assert.Nil(err)
+		assert.True(au.LastChecksum().Encrypted)
+		f, err := os.Open(fileName)
+		assert.Nil(err)
+		_, err = au.Upload(f)
+		f.Close()
+		assert.Nil(err)
+		assert.True(au.LastChecksum().Encrypted)
+	}
+	_, err := au.UploadFileResumable(fileName, "")
+	assert.Nil(err)
+	// SSE-S3 keeps the MD5 as ETag
+	s3.headers = map[string]string{"x-amz-server-side-encryption": "AES256"}
+	_, err = au.Upload(bytes.NewReader([]byte("small")))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "uploaded ETag 'abc' does not match")
+}
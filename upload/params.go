@@ -1,9 +1,12 @@
 package upload
 
 import (
+	"context"
 	"encoding/json"
 	"io"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/SYNQfm/helpers/common"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -16,6 +19,7 @@ const (
 
 type AwsUploadF interface {
 	Upload(io.Reader) (*s3manager.UploadOutput, error)
+	UploadCtx(context.Context, io.Reader) (*s3manager.UploadOutput, error)
 }
 
 type UploadParameters struct {
@@ -33,6 +37,75 @@ type UploadParameters struct {
 	AssetId        string `json:"asset_id"`
 }
 
+// UploadOptions holds the client side settings of an upload, unlike
+// UploadParameters these are never sent to or returned by the api
+type UploadOptions struct {
+	// Client is used for the signature server and S3 requests, defaults to
+	// http.DefaultClient
+	Client *http.Client
+	// Progress is called as the upload progresses, if it is set
+	Progress ProgressFunc
+	// SignAttempts is how many times the signature server is called for a
+	// request that fails with a transient error (DEFAULT_SIGN_ATTEMPTS if it
+	// is 0), SignBackoff is the wait before the second call and doubles after
+	// that
+	SignAttempts int
+	SignBackoff  time.Duration
+	// Endpoint sends the S3 requests to an S3 compatible server (with path
+	// style addressing) instead of the bucket in UploadParameters.Action
+	Endpoint string
+	PartOptions
+}
+
+// PartOptions controls how a file is split into parts and sent, the zero
+// value uses the s3manager defaults (5 MB parts, 5 at once)
+type PartOptions struct {
+	// PartSize is the preferred size of a part, it is raised for files that
+	// would need more than s3manager.MaxUploadParts parts (see AutoPartSize)
+	PartSize int64 `json:"part_size,omitempty"`
+	// Concurrency is how many parts are sent at once
+	Concurrency int `json:"concurrency,omitempty"`
+	// LeavePartsOnError keeps the parts of a failed upload on S3 rather than
+	// aborting it. Resumable uploads always keep them.
+	LeavePartsOnError bool `json:"leave_parts_on_error,omitempty"`
+	// MaxBuffer limits the bytes of parts held in memory when uploading a
+	// stream, Concurrency is lowered to stay under it (one part is always
+	// sent at a time). Resumable uploads read the parts from the file and do
+	// not buffer them.
+	MaxBuffer int64 `json:"max_buffer,omitempty"`
+}
+
+// AutoPartSize returns the part size for a file of size (-1 if unknown): the
+// preferred size, or the default if it is not set, raised so the file fits in
+// s3manager.MaxUploadParts parts
+func AutoPartSize(size, preferred int64) int64 {
+	if preferred <= 0 {
+		preferred = s3manager.DefaultUploadPartSize
+	}
+	return partSize(size, preferred)
+}
+
+// concurrency returns how many parts of partSize to send at once, when
+// buffered is set the parts are held in memory and MaxBuffer applies
+func (o PartOptions) concurrency(partSize int64, buffered bool) int {
+	c := o.Concurrency
+	if c <= 0 {
+		c = s3manager.DefaultUploadConcurrency
+	}
+	if buffered && o.MaxBuffer > 0 && partSize > 0 {
+		// s3manager holds a part for every worker, as many queued for them
+		// and the one it is reading
+		max := int((o.MaxBuffer/partSize - 1) / 2)
+		if max < 1 {
+			max = 1
+		}
+		if c > max {
+			c = max
+		}
+	}
+	return c
+}
+
 type UploadRequest struct {
 	AssetId     string `json:"asset_id"`
 	ContentType string `json:"content_type"`
@@ -4,7 +4,9 @@ import (
 	"log"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseCtype(t *testing.T) {
@@ -42,3 +44,45 @@ func TestNewReq(t *testing.T) {
 	assert.Equal("source", req.GetType())
 	assert.Equal("mov", req.GetExt())
 }
+
+func TestPartOptions(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal(s3manager.DefaultUploadPartSize, AutoPartSize(-1, 0))
+	assert.Equal(int64(64*1024*1024), AutoPartSize(1024*1024*1024, 64*1024*1024))
+	// 200 GB does not fit in 10000 parts of 5 MB
+	size := int64(200 * 1024 * 1024 * 1024)
+	part := AutoPartSize(size, 0)
+	assert.True(part > s3manager.DefaultUploadPartSize)
+	assert.True(partCount(size, part) <= s3manager.MaxUploadParts)
+
+	opts := PartOptions{}
+	assert.Equal(s3manager.DefaultUploadConcurrency, opts.concurrency(5, true))
+	opts.Concurrency = 20
+	assert.Equal(20, opts.concurrency(5, true))
+	// 100 bytes of 10 byte parts is 4 workers (and 4 parts queued, 1 read)
+	opts.MaxBuffer = 100
+	assert.Equal(4, opts.concurrency(10, true))
+	assert.Equal(1, opts.concurrency(100, true))
+	// parts read from a file are not buffered
+	assert.Equal(20, opts.concurrency(10, false))
+}
+
+func TestNewAwsUploadPartOptions(t *testing.T) {
+	assert := require.New(t)
+	params := UploadParameters{Action: "https://synq-abucket.s3.amazonaws.com"}
+	u, err := NewAwsUpload(params, UploadOptions{PartOptions: PartOptions{
+		PartSize:          16 * 1024 * 1024,
+		Concurrency:       10,
+		LeavePartsOnError: true,
+	}})
+	assert.Nil(err)
+	au := u.(*AwsUpload)
+	assert.Equal(int64(16*1024*1024), au.Uploader.PartSize)
+	assert.Equal(10, au.Uploader.Concurrency)
+	assert.True(au.Uploader.LeavePartsOnError)
+	// parts can not be smaller than S3 allows
+	u, _ = NewAwsUpload(params, UploadOptions{PartOptions: PartOptions{PartSize: 1024}})
+	au = u.(*AwsUpload)
+	assert.Equal(s3manager.MinUploadPartSize, au.Uploader.PartSize)
+	assert.Equal(s3manager.DefaultUploadConcurrency, au.Uploader.Concurrency)
+}
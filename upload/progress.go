@@ -0,0 +1,221 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// throughputWindow is how far back the current throughput is measured
+const throughputWindow = 10 * time.Second
+
+// Progress is the state of an upload when a ProgressFunc is called
+type Progress struct {
+	// Sent and Total are in bytes, Total is -1 if the size is not known
+	Sent  int64
+	Total int64
+	// Parts and TotalParts count the parts of a multipart upload
+	Parts      int
+	TotalParts int
+	// Throughput is the bytes per second sent recently, ETA is 0 if it is
+	// not known
+	Throughput float64
+	ETA        time.Duration
+	Elapsed    time.Duration
+	Done       bool
+}
+
+// ProgressFunc is called when an upload starts, after every part and when it
+// is done. It is never called concurrently, but it should return quickly as
+// it blocks the upload.
+type ProgressFunc func(Progress)
+
+// ProgressChan returns a ProgressFunc that sends to ch, updates are dropped
+// if ch is not ready for them (but never the last one)
+func ProgressChan(ch chan<- Progress) ProgressFunc {
+	return func(p Progress) {
+		if p.Done {
+			ch <- p
+			return
+		}
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// Percent returns how much of the upload is done (0 - 100), -1 if the size
+// is not known
+func (p Progress) Percent() float64 {
+	if p.Total < 0 {
+		return -1
+	}
+	if p.Total == 0 {
+		if p.Done {
+			return 100
+		}
+		return 0
+	}
+	return float64(p.Sent) * 100 / float64(p.Total)
+}
+
+// FormatBytes returns n in a human readable form, like "1.5 GB"
+func FormatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
+func (p Progress) String() string {
+	s := FormatBytes(float64(p.Sent))
+	if p.Total >= 0 {
+		s = fmt.Sprintf("%5.1f%% %s of %s", p.Percent(), s, FormatBytes(float64(p.Total)))
+	}
+	if p.TotalParts > 0 {
+		s += fmt.Sprintf(", %d/%d parts", p.Parts, p.TotalParts)
+	}
+	s += fmt.Sprintf(", %s/s", FormatBytes(p.Throughput))
+	if p.Done {
+		return s + fmt.Sprintf(", done in %s", p.Elapsed.Round(time.Second))
+	}
+	if p.ETA > 0 {
+		s += fmt.Sprintf(", ETA %s", p.ETA.Round(time.Second))
+	}
+	return s
+}
+
+type sample struct {
+	at   time.Time
+	sent int64
+}
+
+// progressTracker counts the parts sent for one upload
+type progressTracker struct {
+	mu       sync.Mutex
+	fn       ProgressFunc
+	now      func() time.Time
+	start    time.Time
+	progress Progress
+	samples  []sample
+}
+
+func newProgressTracker(fn ProgressFunc, total int64, parts int) *progressTracker {
+	t := &progressTracker{fn: fn, now: time.Now}
+	t.progress.Total = total
+	t.progress.TotalParts = parts
+	return t
+}
+
+// begin reports the start of the upload, sent bytes in parts are already done
+func (t *progressTracker) begin(sent int64, parts int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.start = t.now()
+	t.progress.Sent = sent
+	t.progress.Parts = parts
+	t.samples = []sample{{at: t.start, sent: sent}}
+	t.report(t.start)
+}
+
+// add reports a part of n bytes is sent
+func (t *progressTracker) add(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Sent += n
+	t.progress.Parts++
+	t.report(t.now())
+}
+
+// done reports the upload is complete
+func (t *progressTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Done = true
+	if t.progress.Total < 0 {
+		t.progress.Total = t.progress.Sent
+	}
+	t.report(t.now())
+}
+
+func (t *progressTracker) report(now time.Time) {
+	p := &t.progress
+	p.Elapsed = now.Sub(t.start)
+	t.samples = append(t.samples, sample{at: now, sent: p.Sent})
+	for len(t.samples) > 2 && now.Sub(t.samples[1].at) >= throughputWindow {
+		t.samples = t.samples[1:]
+	}
+	first := t.samples[0]
+	if d := now.Sub(first.at).Seconds(); d > 0 {
+		p.Throughput = float64(p.Sent-first.sent) / d
+	}
+	p.ETA = 0
+	if !p.Done && p.Throughput > 0 && p.Total > p.Sent {
+		p.ETA = time.Duration(float64(p.Total-p.Sent) / p.Throughput * float64(time.Second))
+	}
+	t.fn(*p)
+}
+
+type trackerKey struct{}
+
+func withTracker(ctx context.Context, t *progressTracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// progressHandler counts the parts of uploads that have a tracker in their
+// context as they complete
+var progressHandler = request.NamedHandler{
+	Name: "synq.ProgressHandler",
+	Fn: func(r *request.Request) {
+		if r.Error != nil || r.Operation == nil || r.HTTPRequest == nil {
+			return
+		}
+		if r.Operation.Name != "UploadPart" && r.Operation.Name != "PutObject" {
+			return
+		}
+		if t, ok := r.Context().Value(trackerKey{}).(*progressTracker); ok {
+			t.add(r.HTTPRequest.ContentLength)
+		}
+	},
+}
+
+// readerSize returns the bytes left in r, -1 if that is not known
+func readerSize(r io.Reader) int64 {
+	if f, ok := r.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return -1
+		}
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - pos
+	}
+	if s, ok := r.(io.Seeker); ok {
+		pos, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err = s.Seek(pos, io.SeekStart); err != nil {
+			return -1
+		}
+		return end - pos
+	}
+	return -1
+}
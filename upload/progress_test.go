@@ -0,0 +1,162 @@
+package upload
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTracker(t *testing.T) {
+	assert := require.New(t)
+	updates := []Progress{}
+	tracker := newProgressTracker(func(p Progress) { updates = append(updates, p) }, 100, 4)
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	tracker.begin(25, 1)
+	now = now.Add(5 * time.Second)
+	tracker.add(25)
+	now = now.Add(5 * time.Second)
+	tracker.add(25)
+	// the throughput only counts the last 10 seconds
+	now = now.Add(10 * time.Second)
+	tracker.add(20)
+	tracker.done()
+	assert.Len(updates, 5)
+	assert.Equal(Progress{Sent: 25, Total: 100, Parts: 1, TotalParts: 4}, updates[0])
+	assert.Equal(Progress{Sent: 50, Total: 100, Parts: 2, TotalParts: 4, Throughput: 5, ETA: 10 * time.Second, Elapsed: 5 * time.Second}, updates[1])
+	assert.Equal(float64(75), updates[2].Percent())
+	assert.Equal(float64(2), updates[3].Throughput)
+	assert.Equal(2500*time.Millisecond, updates[3].ETA)
+	assert.True(updates[4].Done)
+	assert.Equal(time.Duration(0), updates[4].ETA)
+	assert.Equal(20*time.Second, updates[4].Elapsed)
+}
+
+func TestProgressString(t *testing.T) {
+	assert := require.New(t)
+	p := Progress{Sent: 3 * 1024 * 1024 * 1024, Total: 40 * 1024 * 1024 * 1024, Parts: 600, TotalParts: 8192, Throughput: 12.5 * 1024 * 1024, ETA: 3001500 * time.Millisecond}
+	assert.Equal("  7.5% 3.0 GB of 40.0 GB, 600/8192 parts, 12.5 MB/s, ETA 50m2s", p.String())
+	p = Progress{Sent: 512, Total: -1}
+	assert.Equal(float64(-1), p.Percent())
+	assert.Equal("512 B, 0 B/s", p.String())
+	p = Progress{Sent: 0, Total: 0, Done: true, Elapsed: 1200 * time.Millisecond}
+	assert.Equal(float64(100), p.Percent())
+	assert.Equal("100.0% 0 B of 0 B, 0 B/s, done in 1s", p.String())
+	assert.Equal("1.0 KB", FormatBytes(1024))
+	assert.Equal("2048.0 TB", FormatBytes(2*1024*1024*1024*1024*1024))
+}
+
+func TestProgressChan(t *testing.T) {
+	assert := require.New(t)
+	ch := make(chan Progress, 1)
+	fn := ProgressChan(ch)
+	fn(Progress{Sent: 1})
+	// dropped, the channel is full
+	fn(Progress{Sent: 2})
+	assert.Equal(int64(1), (<-ch).Sent)
+	go fn(Progress{Sent: 3, Done: true})
+	assert.Equal(int64(3), (<-ch).Sent)
+}
+
+func TestReaderSize(t *testing.T) {
+	assert := require.New(t)
+	r := bytes.NewReader([]byte("0123456789"))
+	r.Seek(4, 0)
+	assert.Equal(int64(6), readerSize(r))
+	assert.Equal(int64(6), int64(r.Len()))
+	assert.Equal(int64(-1), readerSize(ioutil.NopCloser(strings.NewReader("abc"))))
+	f, _ := ioutil.TempFile("", "size")
+	defer os.Remove(f.Name())
+	f.Write([]byte("abcdef"))
+	f.Seek(2, 0)
+	assert.Equal(int64(4), readerSize(f))
+	f.Close()
+	assert.Equal(int64(-1), readerSize(f))
+}
+
+func TestUploadProgress(t *testing.T) {
+	assert := require.New(t)
+	size := int(2*s3manager.MinUploadPartSize) + 100
+	s3, au, fileName, _, cleanup := setupResumable(t, size)
+	defer cleanup()
+	updates := []Progress{}
+	au.Progress = func(p Progress) { updates = append(updates, p) }
+	f, err := os.Open(fileName)
+	assert.Nil(err)
+	defer f.Close()
+	_, err = au.Upload(f)
+	assert.Nil(err)
+	assert.Equal(3, s3.call("part"))
+	assert.Len(updates, 5)
+	assert.Equal(Progress{Total: int64(size), TotalParts: 3}, updates[0])
+	last := updates[4]
+	assert.True(last.Done)
+	assert.Equal(int64(size), last.Sent)
+	assert.Equal(3, last.Parts)
+
+	// small files are sent in one request
+	updates = updates[:0]
+	_, err = au.Upload(bytes.NewReader([]byte("small")))
+	assert.Nil(err)
+	assert.Equal(1, s3.call("put"))
+	assert.Len(updates, 3)
+	assert.Equal(Progress{Total: 5, TotalParts: 1}, updates[0])
+	assert.Equal(int64(5), updates[2].Sent)
+	assert.Equal(100.0, updates[2].Percent())
+
+	// without a known size
+	updates = updates[:0]
+	_, err = au.Upload(ioutil.NopCloser(strings.NewReader("small")))
+	assert.Nil(err)
+	assert.Equal(int64(-1), updates[0].Total)
+	assert.Equal(int64(5), updates[len(updates)-1].Total)
+}
+
+func TestResumableProgress(t *testing.T) {
+	assert := require.New(t)
+	size := int(2*s3manager.MinUploadPartSize) + 100
+	s3, au, fileName, _, cleanup := setupResumable(t, size)
+	defer cleanup()
+	updates := []Progress{}
+	au.Progress = func(p Progress) { updates = append(updates, p) }
+	s3.failPart = 3
+	_, err := au.UploadFileResumable(fileName, "")
+	assert.NotNil(err)
+	assert.Len(updates, 3)
+	assert.False(updates[2].Done)
+
+	updates = updates[:0]
+	s3.failPart = 0
+	_, err = au.UploadFileResumable(fileName, "")
+	assert.Nil(err)
+	assert.Len(updates, 3)
+	// the parts that were sent before count as done
+	assert.Equal(Progress{Sent: 2 * s3manager.MinUploadPartSize, Total: int64(size), Parts: 2, TotalParts: 3}, updates[0])
+	assert.Equal(int64(size), updates[1].Sent)
+	assert.True(updates[2].Done)
+
+	// a part that changed since it was sent does not count, and is only
+	// counted once when it is sent again
+	content, _ := ioutil.ReadFile(fileName)
+	info, _ := os.Stat(fileName)
+	s3.failPart = 3
+	au.UploadFileResumable(fileName, "")
+	content[s3manager.MinUploadPartSize+10]++
+	assert.Nil(ioutil.WriteFile(fileName, content, 0644))
+	assert.Nil(os.Chtimes(fileName, time.Now(), info.ModTime()))
+	updates = updates[:0]
+	s3.failPart = 0
+	_, err = au.UploadFileResumable(fileName, "")
+	assert.Nil(err)
+	assert.Equal(Progress{Sent: s3manager.MinUploadPartSize, Total: int64(size), Parts: 1, TotalParts: 3}, updates[0])
+	last := updates[len(updates)-1]
+	assert.True(last.Done)
+	assert.Equal(int64(size), last.Sent)
+	assert.Equal(3, last.Parts)
+}
@@ -0,0 +1,399 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SYNQfm/helpers/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+const (
+	// CHECKPOINT_EXT is added to the file name for the default checkpoint file
+	CHECKPOINT_EXT = ".synq-upload"
+	// the bytes read from the start and end of a file for its fingerprint
+	fingerprintSample = 1024 * 1024
+)
+
+// ResumableUploadF is implemented by uploaders that can continue an
+// interrupted upload
+type ResumableUploadF interface {
+	UploadFileResumable(fileName, checkpointFile string) (*s3manager.UploadOutput, error)
+	UploadFileResumableCtx(ctx context.Context, fileName, checkpointFile string) (*s3manager.UploadOutput, error)
+}
+
+// Fingerprint identifies the content of a file without reading all of it
+type Fingerprint struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// Sample is the sha256 of the first and last MB of the file
+	Sample string `json:"sample"`
+}
+
+// FingerprintFile returns the fingerprint of f
+func FingerprintFile(f *os.File) (fp Fingerprint, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return fp, err
+	}
+	fp.Size = info.Size()
+	fp.ModTime = info.ModTime().UTC()
+	h := sha256.New()
+	if _, err = io.Copy(h, io.NewSectionReader(f, 0, fingerprintSample)); err != nil {
+		return fp, err
+	}
+	if fp.Size > fingerprintSample {
+		end := fp.Size - fingerprintSample
+		if end < fingerprintSample {
+			end = fingerprintSample
+		}
+		if _, err = io.Copy(h, io.NewSectionReader(f, end, fp.Size-end)); err != nil {
+			return fp, err
+		}
+	}
+	fp.Sample = hex.EncodeToString(h.Sum(nil))
+	return fp, nil
+}
+
+// Part is an uploaded part of a multipart upload
+type Part struct {
+	Number int64  `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// Checkpoint is the state of a resumable upload, it is saved after every part
+type Checkpoint struct {
+	Bucket   string      `json:"bucket"`
+	Key      string      `json:"key"`
+	UploadId string      `json:"upload_id"`
+	PartSize int64       `json:"part_size"`
+	File     Fingerprint `json:"file"`
+	Parts    []Part      `json:"parts"`
+}
+
+// LoadCheckpoint reads the checkpoint saved in fileName, a missing file
+// returns an empty checkpoint
+func LoadCheckpoint(fileName string) (c Checkpoint, err error) {
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return c, common.NewError("invalid checkpoint '%s' : %s", fileName, err.Error())
+	}
+	return c, nil
+}
+
+// Save writes the checkpoint to fileName, replacing it in one step so a crash
+// never leaves a partial checkpoint behind
+func (c Checkpoint) Save(fileName string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
+// matches returns true if the checkpoint is for uploading file to bucket/key,
+// one without a part size (edited or from an older version) never matches
+func (c Checkpoint) matches(bucket, key string, file Fingerprint) bool {
+	return c.UploadId != "" && c.PartSize > 0 && c.Bucket == bucket && c.Key == key &&
+		c.File.Size == file.Size && c.File.ModTime.Equal(file.ModTime) && c.File.Sample == file.Sample
+}
+
+// partSize returns the part size to use for a file of size, parts are never
+// smaller than S3 allows and there are never more than S3 allows
+func partSize(size, preferred int64) int64 {
+	if preferred < s3manager.MinUploadPartSize {
+		preferred = s3manager.MinUploadPartSize
+	}
+	if min := (size + s3manager.MaxUploadParts - 1) / s3manager.MaxUploadParts; min > preferred {
+		return min
+	}
+	return preferred
+}
+
+// size returns the size of part n of a file of fileSize
+func (c Checkpoint) size(n, fileSize int64) int64 {
+	if offset := (n - 1) * c.PartSize; offset+c.PartSize > fileSize {
+		return fileSize - offset
+	}
+	return c.PartSize
+}
+
+func partCount(size, partSize int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + partSize - 1) / partSize
+}
+
+// serverParts returns the parts S3 has for the checkpoint's upload, false if
+// the upload no longer exists
+func (a *AwsUpload) serverParts(ctx context.Context, c Checkpoint) (map[int64]*s3.Part, bool, error) {
+	parts := map[int64]*s3.Part{}
+	input := &s3.ListPartsInput{Bucket: &c.Bucket, Key: &c.Key, UploadId: &c.UploadId}
+	err := a.Uploader.S3.ListPartsPagesWithContext(ctx, input, func(out *s3.ListPartsOutput, last bool) bool {
+		for _, p := range out.Parts {
+			parts[aws.Int64Value(p.PartNumber)] = p
+		}
+		return true
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchUpload {
+		return nil, false, nil
+	}
+	return parts, err == nil, err
+}
+
+// resume returns the checkpoint to continue from, with only the parts S3 has
+// (with the same ETag and size), or a new upload if that is not possible
+func (a *AwsUpload) resume(ctx context.Context, c Checkpoint, bucket, key string, file Fingerprint) (Checkpoint, error) {
+	if c.matches(bucket, key, file) {
+		server, ok, err := a.serverParts(ctx, c)
+		if err != nil {
+			return c, err
+		}
+		if ok {
+			parts := []Part{}
+			for _, p := range c.Parts {
+				sp := server[p.Number]
+				if sp == nil || aws.StringValue(sp.ETag) != p.ETag {
+					continue
+				}
+				if aws.Int64Value(sp.Size) == c.size(p.Number, file.Size) {
+					parts = append(parts, p)
+				}
+			}
+			c.Parts = parts
+			return c, nil
+		}
+	}
+	acl := a.Acl()
+	contentType := a.ContentType()
+	out, err := a.Uploader.S3.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		ACL:         &acl,
+		Bucket:      &bucket,
+		ContentType: &contentType,
+		Key:         &key,
+	})
+	if err != nil {
+		return c, err
+	}
+	return Checkpoint{
+		Bucket:   bucket,
+		Key:      key,
+		UploadId: aws.StringValue(out.UploadId),
+		PartSize: partSize(file.Size, a.Uploader.PartSize),
+		File:     file,
+	}, nil
+}
+
+func (a *AwsUpload) UploadFileResumable(fileName, checkpointFile string) (*s3manager.UploadOutput, error) {
+	return a.UploadFileResumableCtx(context.Background(), fileName, checkpointFile)
+}
+
+// UploadFileResumableCtx uploads fileName as a multipart upload, saving the
+// upload id and the finished parts to checkpointFile (fileName +
+// CHECKPOINT_EXT if it is "") after every part. If the checkpoint is for the
+// same file and key, the parts S3 still has are not uploaded again. The
+// checkpoint is removed when the upload completes, and the upload fails if
+// the ETag S3 returns does not match the file's checksum (see LastChecksum).
+func (a *AwsUpload) UploadFileResumableCtx(ctx context.Context, fileName, checkpointFile string) (*s3manager.UploadOutput, error) {
+	if checkpointFile == "" {
+		checkpointFile = fileName + CHECKPOINT_EXT
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	bucket, err := a.GetBucket()
+	if err != nil {
+		return nil, err
+	}
+	key := a.Key()
+	file, err := FingerprintFile(f)
+	if err != nil {
+		return nil, err
+	}
+	c, err := LoadCheckpoint(checkpointFile)
+	if err != nil {
+		return nil, err
+	}
+	if c, err = a.resume(ctx, c, bucket, key, file); err != nil {
+		return nil, err
+	}
+	if err = c.Save(checkpointFile); err != nil {
+		return nil, err
+	}
+	done := map[int64]string{}
+	for _, p := range c.Parts {
+		done[p.Number] = p.ETag
+	}
+	// parts that no longer match the file are sent again, so they do not
+	// count as sent
+	for n, etag := range done {
+		sum, err := ChecksumReader(io.NewSectionReader(f, (n-1)*c.PartSize, c.size(n, file.Size)), 0)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Trim(etag, `"`) != sum.MD5 {
+			delete(done, n)
+		}
+	}
+	count := partCount(file.Size, c.PartSize)
+	var tracker *progressTracker
+	if a.Progress != nil {
+		sent := int64(0)
+		for n := range done {
+			sent += c.size(n, file.Size)
+		}
+		tracker = newProgressTracker(a.Progress, file.Size, int(count))
+		ctx = withTracker(ctx, tracker)
+		tracker.begin(sent, len(done))
+	}
+	// every part is read to checksum the file, the parts that are missing (or
+	// that no longer match the file) are then sent with their MD5, up to
+	// Concurrency at once. After an error no more parts are started, but the
+	// ones being sent finish so the checkpoint keeps them.
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+	workers := make(chan struct{}, a.Parts.concurrency(c.PartSize, false))
+	sum := newChecksummer(c.PartSize)
+	for n := int64(1); n <= count && !failed() && ctx.Err() == nil; n++ {
+		offset := (n - 1) * c.PartSize
+		size := c.size(n, file.Size)
+		if _, err = io.Copy(sum, io.NewSectionReader(f, offset, size)); err != nil {
+			fail(err)
+			break
+		}
+		sum.endPart()
+		partMD5, contentMD5 := sum.lastPart()
+		mu.Lock()
+		etag, ok := done[n]
+		mu.Unlock()
+		if ok && strings.Trim(etag, `"`) == partMD5 {
+			continue
+		}
+		workers <- struct{}{}
+		if failed() {
+			<-workers
+			break
+		}
+		wg.Add(1)
+		go func(n, offset, size int64, contentMD5 string) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			out, err := a.Uploader.S3.UploadPartWithContext(ctx, &s3.UploadPartInput{
+				Body:          io.NewSectionReader(f, offset, size),
+				Bucket:        &bucket,
+				ContentLength: aws.Int64(size),
+				ContentMD5:    aws.String(contentMD5),
+				Key:           &key,
+				PartNumber:    aws.Int64(n),
+				UploadId:      &c.UploadId,
+			})
+			if err != nil {
+				fail(err)
+				return
+			}
+			mu.Lock()
+			done[n] = aws.StringValue(out.ETag)
+			c.Parts = c.Parts[:0]
+			for number, etag := range done {
+				c.Parts = append(c.Parts, Part{Number: number, ETag: etag})
+			}
+			err = c.Save(checkpointFile)
+			mu.Unlock()
+			if err != nil {
+				fail(err)
+			}
+		}(n, offset, size, contentMD5)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(c.Parts, func(i, j int) bool {
+		return c.Parts[i].Number < c.Parts[j].Number
+	})
+	completed := []*s3.CompletedPart{}
+	for _, p := range c.Parts {
+		completed = append(completed, &s3.CompletedPart{ETag: aws.String(p.ETag), PartNumber: aws.Int64(p.Number)})
+	}
+	var result Checksum
+	out, err := a.Uploader.S3.CompleteMultipartUploadWithContext(withETag(ctx, &result), &s3.CompleteMultipartUploadInput{
+		Bucket:          &bucket,
+		Key:             &key,
+		UploadId:        &c.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(checkpointFile)
+	a.checksum = sum.checksum()
+	a.checksum.ETag = aws.StringValue(out.ETag)
+	a.checksum.Encrypted = result.Encrypted || aws.StringValue(out.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms
+	if err = a.checksum.Verify(); err != nil {
+		return nil, err
+	}
+	if tracker != nil {
+		tracker.done()
+	}
+	return &s3manager.UploadOutput{
+		Location:  aws.StringValue(out.Location),
+		VersionID: out.VersionId,
+		UploadID:  c.UploadId,
+	}, nil
+}
@@ -0,0 +1,330 @@
+package upload
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePart struct {
+	etag string
+	size int
+}
+
+// fakeS3 implements the multipart upload calls, and the signature server
+type fakeS3 struct {
+	mu      sync.Mutex
+	uploads map[string]map[int]fakePart
+	calls   map[string]int
+	// failPart is a part number that is refused
+	failPart int
+	data     map[string][]byte
+	// etag replaces the ETag of completed uploads if it is set
+	etag string
+	// headers are added to the response of completed uploads
+	headers map[string]string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{uploads: map[string]map[int]fakePart{}, calls: map[string]int{}, data: map[string][]byte{}}
+}
+
+func (s *fakeS3) call(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[name]
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	id := q.Get("uploadId")
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.URL.Path == "/sig" {
+		s.calls["sign"]++
+		w.Write([]byte(`{"authorization":"sig123","date":"20180223T002913Z"}`))
+		return
+	}
+	_, isCreate := q["uploads"]
+	if r.Method == "PUT" {
+		// S3 refuses a body that does not match its Content-MD5
+		sum := md5.Sum(body)
+		if header := r.Header.Get("Content-MD5"); header == "" {
+			s.calls["nomd5"]++
+		} else if header != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<Error><Code>BadDigest</Code><Message>The Content-MD5 you specified did not match what we received.</Message></Error>`))
+			return
+		}
+	}
+	if r.Method == "PUT" && id == "" {
+		s.calls["put"]++
+		s.setHeaders(w)
+		w.Header().Set("ETag", s.etagOr(fmt.Sprintf(`"%x"`, md5.Sum(body))))
+		return
+	}
+	parts := s.uploads[id]
+	if !isCreate && parts == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchUpload</Code><Message>The specified upload does not exist.</Message></Error>`))
+		return
+	}
+	switch {
+	case r.Method == "POST" && isCreate:
+		s.calls["create"]++
+		id = fmt.Sprintf("upload-%d", s.calls["create"])
+		s.uploads[id] = map[int]fakePart{}
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>synq-abucket</Bucket><Key>abc</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, id)
+	case r.Method == "PUT":
+		s.calls["part"]++
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		if n == s.failPart {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+			return
+		}
+		etag := fmt.Sprintf(`"%x"`, md5.Sum(body))
+		parts[n] = fakePart{etag: etag, size: len(body)}
+		s.data[fmt.Sprintf("%s/%d", id, n)] = body
+		w.Header().Set("ETag", etag)
+	case r.Method == "GET":
+		s.calls["list"]++
+		fmt.Fprintf(w, `<ListPartsResult><Bucket>synq-abucket</Bucket><Key>abc</Key><UploadId>%s</UploadId><IsTruncated>false</IsTruncated>`, id)
+		for n, p := range parts {
+			fmt.Fprintf(w, `<Part><PartNumber>%d</PartNumber><ETag>%s</ETag><Size>%d</Size></Part>`, n, p.etag, p.size)
+		}
+		w.Write([]byte(`</ListPartsResult>`))
+	case r.Method == "POST":
+		s.calls["complete"]++
+		// the ETag of a multipart upload is the MD5 of the MD5s of its parts
+		numbers := []int{}
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		sums := []byte{}
+		for _, n := range numbers {
+			sum := md5.Sum(s.data[fmt.Sprintf("%s/%d", id, n)])
+			sums = append(sums, sum[:]...)
+		}
+		etag := s.etagOr(fmt.Sprintf(`"%x-%d"`, md5.Sum(sums), len(numbers)))
+		s.setHeaders(w)
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Location>https://synq-abucket.s3.amazonaws.com/abc</Location><Bucket>synq-abucket</Bucket><Key>abc</Key><ETag>%s</ETag></CompleteMultipartUploadResult>`, etag)
+	}
+}
+
+func (s *fakeS3) etagOr(etag string) string {
+	if s.etag != "" {
+		return s.etag
+	}
+	return etag
+}
+
+func (s *fakeS3) setHeaders(w http.ResponseWriter) {
+	for k, v := range s.headers {
+		w.Header().Set(k, v)
+	}
+}
+
+// uploaded returns the content of the parts of upload id
+func (s *fakeS3) uploaded(id string) (content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	numbers := []int{}
+	for n := range s.uploads[id] {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		content = append(content, s.data[fmt.Sprintf("%s/%d", id, n)]...)
+	}
+	return content
+}
+
+func setupResumable(t *testing.T, size int) (*fakeS3, *AwsUpload, string, []byte, func()) {
+	s3 := newFakeS3()
+	server := httptest.NewServer(s3)
+	dir, err := ioutil.TempDir("", "resume")
+	require.Nil(t, err)
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	fileName := filepath.Join(dir, "movie.mp4")
+	require.Nil(t, ioutil.WriteFile(fileName, content, 0644))
+	params := UploadParameters{Key: "abc", Acl: "private", ContentType: "video/mp4", SignatureUrl: server.URL + "/sig", Action: server.URL}
+	u, err := NewAwsUpload(params, UploadOptions{Endpoint: server.URL})
+	require.Nil(t, err)
+	return s3, u.(*AwsUpload), fileName, content, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUploadFileResumable(t *testing.T) {
+	assert := require.New(t)
+	size := int(2*s3manager.MinUploadPartSize) + 100
+	s3, au, fileName, content, cleanup := setupResumable(t, size)
+	defer cleanup()
+	checkpoint := fileName + CHECKPOINT_EXT
+
+	// the upload dies at the last part
+	s3.failPart = 3
+	_, err := au.UploadFileResumable(fileName, "")
+	assert.NotNil(err)
+	c, err := LoadCheckpoint(checkpoint)
+	assert.Nil(err)
+	assert.Equal("upload-1", c.UploadId)
+	assert.Equal("synq-abucket", c.Bucket)
+	assert.Equal("abc", c.Key)
+	assert.Equal(s3manager.MinUploadPartSize, c.PartSize)
+	assert.Equal(int64(size), c.File.Size)
+	assert.Len(c.Parts, 2)
+	assert.Equal(3, s3.call("part"))
+	// every request is signed by the signature server
+	assert.Equal(4, s3.call("sign"))
+
+	// only the missing part is sent again
+	s3.failPart = 0
+	out, err := au.UploadFileResumable(fileName, "")
+	assert.Nil(err)
+	assert.Equal("upload-1", out.UploadID)
+	assert.Equal("https://synq-abucket.s3.amazonaws.com/abc", out.Location)
+	assert.Equal(1, s3.call("create"))
+	assert.Equal(1, s3.call("list"))
+	assert.Equal(4, s3.call("part"))
+	assert.Equal(1, s3.call("complete"))
+	assert.Equal(content, s3.uploaded("upload-1"))
+	_, err = os.Stat(checkpoint)
+	assert.True(os.IsNotExist(err))
+	// every part is sent with its MD5, and the whole file is checksummed
+	assert.Equal(0, s3.call("nomd5"))
+	sum := au.LastChecksum()
+	assert.Equal(int64(size), sum.Size)
+	assert.Equal(fmt.Sprintf("%x", md5.Sum(content)), sum.MD5)
+	assert.Len(sum.Parts, 3)
+	assert.Equal(MultipartETag(sum.Parts), strings.Trim(sum.ETag, `"`))
+}
+
+func TestUploadFileResumableVerify(t *testing.T) {
+	assert := require.New(t)
+	size := int(s3manager.MinUploadPartSize) + 100
+	s3, au, fileName, content, cleanup := setupResumable(t, size)
+	defer cleanup()
+	checkpoint := filepath.Join(filepath.Dir(fileName), "state.json")
+
+	s3.failPart = 2
+	_, err := au.UploadFileResumable(fileName, checkpoint)
+	assert.NotNil(err)
+	// S3 lost the part, so it is uploaded again
+	s3.mu.Lock()
+	delete(s3.uploads["upload-1"], 1)
+	s3.mu.Unlock()
+	s3.failPart = 0
+	_, err = au.UploadFileResumable(fileName, checkpoint)
+	assert.Nil(err)
+	assert.Equal(1, s3.call("create"))
+	assert.Equal(4, s3.call("part"))
+	assert.Equal(content, s3.uploaded("upload-1"))
+
+	// the upload is gone, so a new one is started
+	s3.failPart = 2
+	au.UploadFileResumable(fileName, checkpoint)
+	s3.mu.Lock()
+	delete(s3.uploads, "upload-2")
+	s3.mu.Unlock()
+	s3.failPart = 0
+	out, err := au.UploadFileResumable(fileName, checkpoint)
+	assert.Nil(err)
+	assert.Equal("upload-3", out.UploadID)
+	assert.Equal(content, s3.uploaded("upload-3"))
+
+	// the file changed, so the checkpoint is not used
+	s3.failPart = 2
+	au.UploadFileResumable(fileName, checkpoint)
+	content[0]++
+	assert.Nil(ioutil.WriteFile(fileName, content, 0644))
+	s3.failPart = 0
+	out, err = au.UploadFileResumable(fileName, checkpoint)
+	assert.Nil(err)
+	assert.Equal("upload-5", out.UploadID)
+	assert.Equal(content, s3.uploaded("upload-5"))
+
+	// a checkpoint without a part size is not used
+	s3.failPart = 2
+	au.UploadFileResumable(fileName, checkpoint)
+	c, err := LoadCheckpoint(checkpoint)
+	assert.Nil(err)
+	c.PartSize = 0
+	assert.Nil(c.Save(checkpoint))
+	s3.failPart = 0
+	out, err = au.UploadFileResumable(fileName, checkpoint)
+	assert.Nil(err)
+	assert.Equal("upload-7", out.UploadID)
+	assert.Equal(content, s3.uploaded("upload-7"))
+}
+
+func TestCheckpoint(t *testing.T) {
+	assert := require.New(t)
+	dir, _ := ioutil.TempDir("", "checkpoint")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "c.json")
+	c, err := LoadCheckpoint(fileName)
+	assert.Nil(err)
+	assert.Equal(Checkpoint{}, c)
+	c = Checkpoint{UploadId: "a", Parts: []Part{{Number: 1, ETag: `"x"`}}}
+	assert.Nil(c.Save(fileName))
+	loaded, err := LoadCheckpoint(fileName)
+	assert.Nil(err)
+	assert.Equal(c, loaded)
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(files, 1)
+	ioutil.WriteFile(fileName, []byte("{"), 0644)
+	_, err = LoadCheckpoint(fileName)
+	assert.Contains(err.Error(), "invalid checkpoint")
+}
+
+func TestPartSize(t *testing.T) {
+	assert := require.New(t)
+	assert.Equal(s3manager.MinUploadPartSize, partSize(100, 0))
+	assert.Equal(int64(10*1024*1024), partSize(100, 10*1024*1024))
+	// 100 GB does not fit in 10000 parts of 5 MB
+	assert.Equal(int64(10737419), partSize(100*1024*1024*1024, 0))
+	assert.Equal(int64(1), partCount(0, 5))
+	assert.Equal(int64(3), partCount(11, 5))
+}
+
+func TestUploadFileResumableConcurrency(t *testing.T) {
+	assert := require.New(t)
+	size := int(2*s3manager.MinUploadPartSize) + 100
+	s3, au, fileName, content, cleanup := setupResumable(t, size)
+	defer cleanup()
+	// one part at a time, so no part is started after the failed one
+	au.Parts.Concurrency = 1
+	s3.failPart = 2
+	_, err := au.UploadFileResumable(fileName, "")
+	assert.NotNil(err)
+	assert.Equal(2, s3.call("part"))
+	c, _ := LoadCheckpoint(fileName + CHECKPOINT_EXT)
+	assert.Len(c.Parts, 1)
+	s3.failPart = 0
+	au.Parts.Concurrency = 3
+	_, err = au.UploadFileResumable(fileName, "")
+	assert.Nil(err)
+	assert.Equal(4, s3.call("part"))
+	assert.Equal(content, s3.uploaded("upload-1"))
+}
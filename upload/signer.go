@@ -0,0 +1,113 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+const (
+	DEFAULT_SIGN_ATTEMPTS   = 3
+	DEFAULT_SIGN_BACKOFF_MS = 500
+	SIGN_MAX_BACKOFF_MS     = 10000 // 10 seconds
+)
+
+// SignerError is returned when the signature server does not sign an S3
+// request, the request is never sent to S3
+type SignerError struct {
+	Url string
+	// StatusCode and Body are the signature server's response, StatusCode is
+	// 0 if there was none and Err is set instead
+	StatusCode int
+	Body       []byte
+	Err        error
+	// Attempts is how many times the signature server was called
+	Attempts int
+}
+
+func (e *SignerError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("could not sign the upload at %s : %s", e.Url, e.Err.Error())
+	}
+	return fmt.Sprintf("signature server %s returned %d : %s", e.Url, e.StatusCode, string(e.Body))
+}
+
+func (e *SignerError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary returns true if signing may work when it is tried again
+func (e *SignerError) Temporary() bool {
+	if e.Err != nil {
+		if errors.Is(e.Err, context.Canceled) || errors.Is(e.Err, context.DeadlineExceeded) {
+			return false
+		}
+		if errors.Is(e.Err, io.EOF) || errors.Is(e.Err, io.ErrUnexpectedEOF) {
+			return true
+		}
+		if errors.Is(e.Err, syscall.ECONNRESET) || errors.Is(e.Err, syscall.ECONNREFUSED) {
+			return true
+		}
+		var netErr net.Error
+		return errors.As(e.Err, &netErr) && netErr.Timeout()
+	}
+	switch e.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// AsSignerError returns the SignerError err was caused by, if any, looking
+// through the errors the aws sdk wraps it in
+func AsSignerError(err error) (*SignerError, bool) {
+	for err != nil {
+		if e, ok := err.(*SignerError); ok {
+			return e, true
+		}
+		if awsErr, ok := err.(awserr.Error); ok {
+			err = awsErr.OrigErr()
+			continue
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil, false
+}
+
+// signBackoff returns the delay after attempt n (1 based), doubling from base
+func signBackoff(base time.Duration, n int) time.Duration {
+	max := time.Duration(SIGN_MAX_BACKOFF_MS) * time.Millisecond
+	delay := base
+	for i := 1; i < n && delay < max; i++ {
+		delay = delay * 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+func (a *AwsUpload) signAttempts() int {
+	if a.SignAttempts <= 0 {
+		return DEFAULT_SIGN_ATTEMPTS
+	}
+	return a.SignAttempts
+}
+
+func (a *AwsUpload) signBackoff() time.Duration {
+	if a.SignBackoff <= 0 {
+		return time.Duration(DEFAULT_SIGN_BACKOFF_MS) * time.Millisecond
+	}
+	return a.SignBackoff
+}
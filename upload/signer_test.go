@@ -0,0 +1,182 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenSigner fails the first failures calls with status, then signs
+type brokenSigner struct {
+	mu       sync.Mutex
+	status   int
+	body     string
+	failures int
+	calls    int
+}
+
+func (s *brokenSigner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.failures != 0 {
+		s.failures--
+		w.WriteHeader(s.status)
+		w.Write([]byte(s.body))
+		return
+	}
+	w.Write([]byte(`{"authorization":"sig123","date":"20180223T002913Z"}`))
+}
+
+func (s *brokenSigner) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func setupSigner(t *testing.T, signer *brokenSigner) (*AwsUpload, func()) {
+	server := httptest.NewServer(signer)
+	params := UploadParameters{SignatureUrl: server.URL + "/sig", Action: "https://synqfm.s3.amazonaws.com"}
+	u, err := NewAwsUpload(params, UploadOptions{SignBackoff: time.Millisecond})
+	require.Nil(t, err)
+	return u.(*AwsUpload), server.Close
+}
+
+func TestSignerErrorTemporary(t *testing.T) {
+	assert := require.New(t)
+	for status, temporary := range map[int]bool{
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusGatewayTimeout:      true,
+		http.StatusForbidden:           false,
+		http.StatusNotFound:            false,
+	} {
+		assert.Equal(temporary, (&SignerError{StatusCode: status}).Temporary(), status)
+	}
+	assert.True((&SignerError{Err: io.ErrUnexpectedEOF}).Temporary())
+	assert.True((&SignerError{Err: syscall.ECONNREFUSED}).Temporary())
+	assert.False((&SignerError{Err: context.Canceled}).Temporary())
+	assert.False((&SignerError{Err: errors.New("bad url")}).Temporary())
+	err := &SignerError{Url: "http://sig", StatusCode: 403, Body: []byte("denied")}
+	assert.Equal("signature server http://sig returned 403 : denied", err.Error())
+}
+
+func TestAsSignerError(t *testing.T) {
+	assert := require.New(t)
+	signErr := &SignerError{StatusCode: 403}
+	_, ok := AsSignerError(nil)
+	assert.False(ok)
+	_, ok = AsSignerError(errors.New("other"))
+	assert.False(ok)
+	// the multipart uploader wraps the errors of its parts
+	wrapped := awserr.New("MultipartUpload", "upload multipart failed", signErr)
+	found, ok := AsSignerError(wrapped)
+	assert.True(ok)
+	assert.Equal(signErr, found)
+}
+
+func TestSignerRetry(t *testing.T) {
+	assert := require.New(t)
+	signer := &brokenSigner{status: http.StatusServiceUnavailable, failures: 2}
+	au, cleanup := setupSigner(t, signer)
+	defer cleanup()
+	sig, err := au.ServerSignV2(createTestAwsReq())
+	assert.Nil(err)
+	assert.Equal("sig123", sig)
+	assert.Equal(3, signer.count())
+
+	// it gives up after SignAttempts
+	signer.failures = 5
+	_, err = au.ServerSignV2(createTestAwsReq())
+	signErr, ok := err.(*SignerError)
+	assert.True(ok)
+	assert.Equal(http.StatusServiceUnavailable, signErr.StatusCode)
+	assert.Equal(DEFAULT_SIGN_ATTEMPTS, signErr.Attempts)
+	assert.Equal(6, signer.count())
+
+	// permanent failures are not retried, and keep the response
+	signer.failures = 1
+	signer.status = http.StatusForbidden
+	signer.body = `{"message":"invalid upload token"}`
+	_, err = au.ServerSignV2(createTestAwsReq())
+	signErr, ok = err.(*SignerError)
+	assert.True(ok)
+	assert.Equal(http.StatusForbidden, signErr.StatusCode)
+	assert.Equal(signer.body, string(signErr.Body))
+	assert.Equal(1, signErr.Attempts)
+	assert.Equal(7, signer.count())
+
+	// a response without an authorization is an error too
+	signer.failures = 1
+	signer.status = http.StatusOK
+	signer.body = `{}`
+	_, err = au.ServerSignV2(createTestAwsReq())
+	signErr, ok = err.(*SignerError)
+	assert.True(ok)
+	assert.Contains(signErr.Error(), "no authorization")
+}
+
+func TestSignerRetryCtx(t *testing.T) {
+	assert := require.New(t)
+	signer := &brokenSigner{status: http.StatusServiceUnavailable, failures: 5}
+	au, cleanup := setupSigner(t, signer)
+	defer cleanup()
+	au.SignBackoff = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := au.V4SigCtx(ctx, V4Request{})
+	assert.NotNil(err)
+	assert.True(time.Since(start) < 10*time.Second)
+	assert.Equal(1, signer.count())
+}
+
+func TestUploadSignerError(t *testing.T) {
+	assert := require.New(t)
+	s3, au, fileName, _, cleanup := setupResumable(t, 100)
+	defer cleanup()
+	// the signature server is the fake s3, so break it through the client
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/sig" {
+			return &http.Response{
+				StatusCode: http.StatusForbidden,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("expired"))),
+				Request:    r,
+			}, nil
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	u, err := NewAwsUpload(au.UploadParams, UploadOptions{Client: client, Endpoint: au.UploadParams.Action})
+	assert.Nil(err)
+	au = u.(*AwsUpload)
+	_, err = au.Upload(bytes.NewReader([]byte("small")))
+	signErr, ok := AsSignerError(err)
+	assert.True(ok)
+	assert.Equal(http.StatusForbidden, signErr.StatusCode)
+	assert.Equal("expired", string(signErr.Body))
+	// nothing unsigned reaches S3
+	assert.Equal(0, s3.call("put"))
+	_, err = au.UploadFileResumable(fileName, "")
+	_, ok = AsSignerError(err)
+	assert.True(ok)
+	assert.Equal(0, s3.call("create"))
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}